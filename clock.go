@@ -0,0 +1,109 @@
+package rv64
+
+import (
+	"time"
+)
+
+// ClockFrequency is the frequency of the time CSR in Hz.
+const ClockFrequency uint64 = 10_000_000
+
+// Clock is the source of time of the guest. It drives the time CSR and the time related syscalls.
+type Clock interface {
+	// Now returns the wall-clock time seen by the guest.
+	Now(*CPU) time.Time
+	// Since returns the time passed since the hart was started. It never goes backwards.
+	Since(*CPU) time.Duration
+}
+
+// ClockTicks converts a duration to the value of the time CSR.
+func ClockTicks(d time.Duration) uint64 {
+	return uint64(d) / (uint64(time.Second) / ClockFrequency)
+}
+
+// clockSample is the number of readings of ClockReal between two readings of the host clock.
+const clockSample = 1024
+
+// clockSampler is implemented by clocks that read the host clock only once in a while, see ClockReal. Sample makes the
+// next reading up to date.
+type clockSampler interface {
+	Sample()
+}
+
+// clockSkip is implemented by clocks that do not follow the host and can jump forward, see ClockCycle.Skip.
+type clockSkip interface {
+	Skip(*CPU, time.Duration)
+}
+
+// ClockReal follows the wall-clock time of the host. The time is read on every instruction, but the host clock is slow
+// to read: Since samples it once every clockSample readings and returns the last sample in between.
+type ClockReal struct {
+	Start time.Time
+	last  time.Duration
+	left  int
+}
+
+func (c *ClockReal) Now(*CPU) time.Time {
+	return time.Now()
+}
+
+func (c *ClockReal) Since(*CPU) time.Duration {
+	if c.left == 0 {
+		c.last = time.Since(c.Start)
+		c.left = clockSample
+	}
+	c.left--
+	return c.last
+}
+
+// Sample makes the next call of Since read the host clock.
+func (c *ClockReal) Sample() {
+	c.left = 0
+}
+
+func NewClockReal() *ClockReal {
+	return &ClockReal{Start: time.Now()}
+}
+
+// ClockCycle derives the time from the cycle counter of the hart, as if it were running at Frequency Hz. The guest
+// sees exactly the same time on every run, which makes executions reproducible. A zero Frequency is ClockFrequency.
+type ClockCycle struct {
+	Epoch     time.Time
+	Frequency uint64
+}
+
+// frequency returns the frequency of the clock in Hz.
+func (c *ClockCycle) frequency() uint64 {
+	if c.Frequency == 0 {
+		return ClockFrequency
+	}
+	return c.Frequency
+}
+
+func (c *ClockCycle) Now(cpu *CPU) time.Time {
+	return c.Epoch.Add(c.Since(cpu))
+}
+
+func (c *ClockCycle) Since(cpu *CPU) time.Duration {
+	f := c.frequency()
+	n := cpu.GetCSR().Get(CSRcycle)
+	s := n / f
+	r := n % f
+	return time.Duration(s)*time.Second + time.Duration(r*uint64(time.Second)/f)
+}
+
+// Skip moves the clock forward to d, by advancing the cycle counter. The hart skips the cycles it would spend waiting
+// for an interrupt.
+func (c *ClockCycle) Skip(cpu *CPU, d time.Duration) {
+	f := c.frequency()
+	s := uint64(d / time.Second)
+	r := uint64(d % time.Second)
+	n := s*f + (r*f+uint64(time.Second)-1)/uint64(time.Second)
+	if n > cpu.GetCSR().Get(CSRcycle) {
+		cpu.GetCSR().Set(CSRcycle, n)
+	}
+}
+
+// NewClockCycle returns a clock that starts at epoch. At the default frequency the time CSR equals the cycle CSR.
+func NewClockCycle(epoch time.Time) *ClockCycle {
+	return &ClockCycle{Epoch: epoch, Frequency: ClockFrequency}
+}
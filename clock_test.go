@@ -0,0 +1,82 @@
+package rv64
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestClockCycle(t *testing.T) {
+	c := NewCPU()
+	c.SetCSR(NewCSRStandard())
+	c.GetCSR().Set(CSRcycle, 25_000_000)
+	// A zero frequency is the default one, the time CSR then equals the cycle CSR.
+	k := &ClockCycle{Epoch: time.Unix(1000, 0)}
+	if d := k.Since(c); d != 2500*time.Millisecond || ClockTicks(d) != 25_000_000 {
+		t.Fatal(d)
+	}
+	if n := k.Now(c); !n.Equal(time.Unix(1002, 500_000_000)) {
+		t.Fatal(n)
+	}
+	k.Skip(c, 3*time.Second+1)
+	if n := c.GetCSR().Get(CSRcycle); n != 30_000_001 {
+		t.Fatal(n)
+	}
+	// The clock never goes backwards.
+	k.Skip(c, time.Second)
+	if n := c.GetCSR().Get(CSRcycle); n != 30_000_001 {
+		t.Fatal(n)
+	}
+	k = &ClockCycle{Frequency: 1000}
+	if d := k.Since(c); d != 30_000_001*time.Millisecond {
+		t.Fatal(d)
+	}
+}
+
+func TestClockReal(t *testing.T) {
+	k := NewClockReal()
+	d := k.Since(nil)
+	time.Sleep(time.Millisecond)
+	// The host clock is sampled, it is only read again after clockSample readings or when asked to.
+	if e := k.Since(nil); e != d {
+		t.Fatal(d, e)
+	}
+	k.Sample()
+	if e := k.Since(nil); e < d+time.Millisecond {
+		t.Fatal(d, e)
+	}
+}
+
+func TestSystemStandardClock(t *testing.T) {
+	// The cycle clock gives the same time on every run, whatever the time of the host.
+	run := func() []byte {
+		c := testCPU(nil)
+		c.SetClock(NewClockCycle(time.Unix(1000, 0)))
+		c.GetCSR().Set(CSRcycle, 25_000_000)
+		s := NewSystemStandard()
+		if r := testSyscall(t, c, s, SyscallClockGet, LinuxClockMonotonic, 0x1000); r != 0 {
+			t.Fatal(int64(r))
+		}
+		if r := testSyscall(t, c, s, SyscallClockGet, LinuxClockRealtime, 0x1010); r != 0 {
+			t.Fatal(int64(r))
+		}
+		if r := testSyscall(t, c, s, SyscallTimeOfDay, 0x1020, 0); r != 0 {
+			t.Fatal(int64(r))
+		}
+		if r := testSyscall(t, c, s, SyscallClockGet, 42, 0x1030); r != SyscallError(ErrnoEINVAL) {
+			t.Fatal(int64(r))
+		}
+		b, _ := c.GetMemory().GetByte(0x1000, 0x30)
+		return b
+	}
+	want := []byte{
+		2, 0, 0, 0, 0, 0, 0, 0, 0x00, 0x65, 0xcd, 0x1d, 0, 0, 0, 0, // 2.5 s
+		0xea, 3, 0, 0, 0, 0, 0, 0, 0x00, 0x65, 0xcd, 0x1d, 0, 0, 0, 0, // 1002.5 s
+		0xea, 3, 0, 0, 0, 0, 0, 0, 0x20, 0xa1, 0x07, 0x00, 0, 0, 0, 0, // 1002 s 500000 us
+	}
+	for i := 0; i < 2; i++ {
+		if b := run(); !bytes.Equal(b, want) {
+			t.Fatalf("% x", b)
+		}
+	}
+}
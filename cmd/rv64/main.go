@@ -1,18 +1,54 @@
 package main
 
 import (
-	"debug/elf"
+	"bytes"
 	"flag"
+	"io"
 	"log"
+	"math/rand"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/mohanson/rv64"
 )
 
 var (
-	flDebug = flag.Bool("d", false, "Debug")
+	flDebug      = flag.Bool("d", false, "Debug")
+	flRoot       = flag.String("root", "", "Jail the guest file system in this host directory, the guest may write to it. By default the guest reads the whole host file system and its changes stay in memory")
+	flClock      = flag.String("clock", "real", "Clock source of the guest: real, or cycle for reproducible runs")
+	flStrace     = flag.Bool("strace", false, "Trace syscalls to stderr")
+	flStraceJSON = flag.Bool("strace-json", false, "Trace syscalls to stderr as JSON lines")
+	flSysroot    = flag.String("sysroot", "/", "Load the program interpreter of dynamically linked programs from this host directory")
+	flMemory     = flag.Uint64("memory", 1024, "Maximum resident memory of the guest in MiB")
+	flHostEnv    = flag.Bool("host-env", false, "Pass the environment of the host to the guest")
+	flEnv        = envList{}
+	flBare       = flag.Bool("bare", false, "Run a bare-metal program: all memory is RAM, devices are attached and no process is set up")
+	flUART       = flag.Uint64("uart", 0x10000000, "Base address of the 16550 UART of bare-metal programs, 0 for none")
+	flCLINT      = flag.Uint64("clint", 0x2000000, "Base address of the CLINT timer of bare-metal programs, 0 for none")
+	flPLIC       = flag.Uint64("plic", 0xc000000, "Base address of the PLIC interrupt controller of bare-metal programs, 0 for none")
+	flFinisher   = flag.Uint64("finisher", 0x100000, "Base address of the test finisher bare-metal programs exit through, 0 for none")
 )
 
+const (
+	// Top of the stack, the end of the user address space of Sv39 less a guard page, like Linux.
+	cStackTop = 0x3ffffff000
+	// Space reserved for the stack. The heap and memory mappings can never grow into it.
+	cStackSize = 8 * 1024 * 1024
+	// Load address of position independent executables, two thirds of the user address space like Linux.
+	cDynBase = 0x2aaaaaa000
+)
+
+// envList collects the repeated -env flags.
+type envList []string
+
+func (e *envList) String() string     { return strings.Join(*e, " ") }
+func (e *envList) Set(s string) error { *e = append(*e, s); return nil }
+
+func init() {
+	flag.Var(&flEnv, "env", "Set an environment variable of the guest, as KEY=VALUE. May be repeated")
+}
+
 func prog() []string {
 	i := 0
 	for ; i < len(os.Args); i++ {
@@ -30,89 +66,136 @@ func main() {
 		rv64.LogLevel = 1
 	}
 	cpu := rv64.NewCPU()
-	cpu.SetFasten(rv64.NewLinear(4 * 1024 * 1024))
-	cpu.SetSystem(rv64.NewSystemStandard())
 	cpu.SetCSR(rv64.NewCSRStandard())
+	ram := rv64.NewPaged(*flMemory * 1024 * 1024)
+	if *flBare {
+		// The console of the firmware is the terminal, like the UART of the virt board of QEMU.
+		bus := rv64.NewBus(ram)
+		var plic *rv64.DevicePLIC
+		if *flPLIC != 0 {
+			// The sources of the virt board of QEMU.
+			plic = rv64.NewDevicePLIC(cpu, 95)
+			if err := bus.Attach(*flPLIC, rv64.PLICSize, plic); err != nil {
+				log.Panicln(err)
+			}
+		}
+		if *flUART != 0 {
+			uart := rv64.NewDeviceUART(os.Stdin, os.Stdout)
+			if plic != nil {
+				uart.IRQ = plic.IRQ(10)
+			}
+			if err := bus.Attach(*flUART, rv64.UARTSize, uart); err != nil {
+				log.Panicln(err)
+			}
+			cpu.AddTicker(uart)
+		}
+		if *flCLINT != 0 {
+			clint := rv64.NewDeviceCLINT(cpu)
+			if err := bus.Attach(*flCLINT, rv64.CLINTSize, clint); err != nil {
+				log.Panicln(err)
+			}
+			cpu.AddTicker(clint)
+		}
+		if *flFinisher != 0 {
+			if err := bus.Attach(*flFinisher, rv64.FinisherSize, rv64.NewDeviceFinisher(cpu)); err != nil {
+				log.Panicln(err)
+			}
+		}
+		cpu.SetFasten(bus)
+	} else {
+		// The guest is a user process, the system emulates the kernel.
+		cpu.SetPrivilege(rv64.PrivilegeU)
+		cpu.SetSignals(true)
+		// Like Linux, let the process read cycle, time and instret.
+		cpu.GetCSR().Set(rv64.CSRmcounteren, 7)
+		cpu.GetCSR().Set(rv64.CSRscounteren, 7)
+		cpu.SetFasten(rv64.NewProtected(ram))
+		if err := cpu.GetMemory().Protect(cStackTop-cStackSize, cStackSize, rv64.PermR|rv64.PermW); err != nil {
+			log.Panicln(err)
+		}
+	}
+	sys := rv64.NewSystemStandard()
+	// The shared libraries are opened by the interpreter, so they must be found in the sysroot too. The changes of the
+	// guest stay in memory.
+	switch {
+	case *flRoot != "":
+		sys.FS = rv64.NewVFSHost(*flRoot)
+		sys.Cwd = "/"
+	case *flSysroot != "/":
+		sys.FS = rv64.NewVFSOverlay(&rv64.VFSHost{Root: *flSysroot, ReadOnly: true}, nil)
+		sys.Cwd = "/"
+	}
+	// Environment calls of bare-metal programs trap to the firmware, there is no kernel to emulate.
+	if !*flBare {
+		cpu.SetSystem(sys)
+		if *flStrace || *flStraceJSON {
+			cpu.SetSystem(&rv64.SystemTrace{System: sys, Writer: os.Stderr, JSON: *flStraceJSON})
+		}
+	}
+	switch *flClock {
+	case "real":
+		cpu.SetClock(rv64.NewClockReal())
+	case "cycle":
+		cpu.SetClock(rv64.NewClockCycle(time.Unix(0, 0)))
+		// Reproducible runs must not see random bytes from the host.
+		sys.Random = rand.New(rand.NewSource(0))
+	default:
+		log.Panicln("unknown clock", *flClock)
+	}
 
-	f, err := elf.Open(args[0])
+	f, err := os.Open(args[0])
 	if err != nil {
 		log.Panicln(err)
 	}
 	defer f.Close()
-	for _, p := range f.Progs {
-		// Specifies a loadable segment, described by p_filesz and p_memsz. The bytes from the file are mapped to the
-		// beginning of the memory segment. If the segment's memory size (p_memsz) is larger than the file size
-		// (p_filesz), the extra bytes are defined to hold the value 0 and to follow the segment's initialized area.
-		// The file size can not be larger than the memory size. Loadable segment entries in the program header table
-		// appear in ascending order, sorted on the p_vaddr member.
-		if p.ProgHeader.Type == elf.PT_LOAD {
-			mem := make([]byte, p.Memsz)
-			p.ReadAt(mem[0:p.Filesz], 0)
-			cpu.GetMemory().SetByte(p.Vaddr, mem)
-		}
+	img, err := rv64.LoadELF(cpu, f, rv64.ELFOptions{Bias: cDynBase})
+	if err != nil {
+		log.Panicln(err)
 	}
-	cpu.SetPC(f.Entry)
-	cpu.SetRegister(rv64.Rsp, cpu.GetMemory().Len())
-
-	// Command line parameters, distribution of environment variables on the stack:
-	//
-	// | envs[1]     | SP Base
-	// | envs[0]     |
-	// | argv[1]     |
-	// | argv[0]     |
-	// | 0           |
-	// | envs[1].ptr |
-	// | envs[0].ptr |
-	// | 0           |
-	// | argv[1].ptr |
-	// | argv[0].ptr |
-	// | argc        |
-	argList := args
-	envList := []string{}
-	envPtrs := []uint64{}
-	argPtrs := []uint64{}
-
-	// Stack pointer must be aligned to 16-byte boundary.
-	rLength := func() uint64 {
-		var r uint64 = 0
-		r += 8
-		r += 8 * uint64(len(argList))
-		r += 8
-		r += 8 * uint64(len(envList))
-		r += 8
-		for _, e := range argList {
-			r += uint64(len(e)) + 1
-		}
-		for _, e := range envList {
-			r += uint64(len(e)) + 1
-		}
-		return r
-	}()
-	spAddress := cpu.GetRegister(rv64.Rsp) - rLength
-	spAddressAligned := spAddress & (^uint64(15))
-	spAlignedByteNum := spAddress - spAddressAligned
-	cpu.SetRegister(rv64.Rsp, cpu.GetRegister(rv64.Rsp)-spAlignedByteNum)
-
-	for i := len(envList) - 1; i >= 0; i-- {
-		cpu.PushString(envList[i])
-		envPtrs = append(envPtrs, cpu.GetRegister(rv64.Rsp))
+	// The program break starts after the highest loadable segment.
+	sys.Heap = rv64.NewHeap(img.Brk, cStackTop-cStackSize)
+	if *flBare {
+		// The firmware sets up its own stack.
+		os.Exit(int(cpu.Run()))
 	}
-	for i := len(argList) - 1; i >= 0; i-- {
-		cpu.PushString(argList[i])
-		argPtrs = append(argPtrs, cpu.GetRegister(rv64.Rsp))
+	cpu.SetRegister(rv64.Rsp, cStackTop)
+
+	env := []string{}
+	if *flHostEnv {
+		env = append(env, os.Environ()...)
 	}
-	cpu.PushUint64(0)
-	for i := 0; i < len(envPtrs); i++ {
-		cpu.PushUint64(envPtrs[i])
+	env = append(env, flEnv...)
+	proc := rv64.NewProcess(args, env)
+	if *flClock == "cycle" {
+		// Reproducible runs must not see random bytes from the host.
+		proc.Random = [16]byte{}
 	}
-	cpu.PushUint64(0)
-	for i := 0; i < len(argPtrs); i++ {
-		cpu.PushUint64(argPtrs[i])
+	proc.Auxv = append(proc.Auxv, img.Auxv()...)
+	if img.Interp != "" {
+		// The interpreter is mapped like a shared library and starts first. It finds the program with AT_PHDR and
+		// AT_ENTRY, and itself with AT_BASE.
+		fi, err := rv64.NewVFSHost(*flSysroot).Open(img.Interp, os.O_RDONLY, 0)
+		if err != nil {
+			log.Panicln(err)
+		}
+		defer fi.Close()
+		r, ok := fi.(io.ReaderAt)
+		if !ok {
+			// The file can only be read in sequence, it is read whole.
+			b, err := io.ReadAll(fi)
+			if err != nil {
+				log.Panicln(img.Interp, err)
+			}
+			r = bytes.NewReader(b)
+		}
+		interp, err := rv64.LoadELF(cpu, r, rv64.ELFOptions{Heap: sys.Heap})
+		if err != nil {
+			log.Panicln(img.Interp, err)
+		}
+		proc.Auxv = append(proc.Auxv, rv64.Auxv{Type: rv64.LinuxAuxvBase, Value: interp.Bias})
 	}
-	cpu.PushUint64(uint64(len(argList)))
-
-	if cpu.GetRegister(rv64.Rsp)%16 != 0 {
-		rv64.Panicln("unreachable")
+	if err := proc.Push(cpu); err != nil {
+		log.Panicln(err)
 	}
 
 	os.Exit(int(cpu.Run()))
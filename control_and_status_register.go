@@ -12,12 +12,65 @@ package rv64
 // 0xC80  Read-only  cycleh   Upper 32 bits of cycle, RV32I only.
 // 0xC81  Read-only  timeh    Upper 32 bits of time, RV32I only.
 // 0xC82  Read-only  instreth Upper 32 bits of instret, RV32I only.
+//
+// The supervisor and machine CSRs of CSRStandard.
+//
+// Number        Privilege  Name                Description
+// 0x100         Read/write sstatus             Supervisor view of mstatus.
+// 0x104         Read/write sie                 Supervisor view of mie, the interrupts delegated in mideleg.
+// 0x105         Read/write stvec               Supervisor trap handler base address.
+// 0x106         Read/write scounteren          Supervisor counter enable.
+// 0x10A         Read/write senvcfg             Supervisor environment configuration, FIOM only.
+// 0x140-0x143   Read/write sscratch ... stval  Supervisor trap handling.
+// 0x144         Read/write sip                 Supervisor view of mip, SSIP is writable.
+// 0x180         Read/write satp                Supervisor address translation and protection.
+// 0xF11-0xF15   Read-only  mvendorid ...       Machine information registers, all zero.
+// 0x300         Read/write mstatus             Machine status register.
+// 0x301         Read/write misa                ISA and extensions, writes are ignored.
+// 0x302-0x306   Read/write medeleg ... mcounteren Machine trap setup.
+// 0x30A         Read/write menvcfg             Machine environment configuration, FIOM only.
+// 0x320         Read/write mcountinhibit       Machine counter-inhibit register, hardwired to zero.
+// 0x323-0x33F   Read/write mhpmevent3 ...      Machine performance-monitoring event selectors, hardwired to zero.
+// 0x340-0x344   Read/write mscratch ... mip    Machine trap handling.
+// 0x3A0, 0x3A2  Read/write pmpcfg0, pmpcfg2    Physical memory protection configuration.
+// 0x3B0-0x3BF   Read/write pmpaddr0 ...        Physical memory protection addresses.
+// 0xB00, 0xB02  Read/write mcycle, minstret    Machine views of cycle and instret.
+// 0xB03-0xB1F   Read/write mhpmcounter3 ...    Machine performance-monitoring counters, hardwired to zero.
+// 0xC03-0xC1F   Read-only  hpmcounter3 ...     Performance-monitoring counters, hardwired to zero.
 
 type CSR interface {
 	Get(uint64) uint64
 	Set(uint64, uint64)
 }
 
+// Optional methods of a CSR, the Zicsr instructions use them when they are implemented. Exists reports whether a CSR
+// is implemented, accessing another one is an illegal instruction. Write is the write of an instruction, which can
+// not change the bits driven by the hardware with Set.
+type (
+	csrExists interface {
+		Exists(uint64) bool
+	}
+	csrWrite interface {
+		Write(uint64, uint64)
+	}
+)
+
+// MISA is the value of misa: RV64 with the extensions A, C, D, F, I, M, S and U.
+const MISA uint64 = 2<<62 | 1<<0 | 1<<2 | 1<<3 | 1<<5 | 1<<8 | 1<<12 | 1<<18 | 1<<20
+
+// Writable bits of the CSRs of CSRStandard. All exceptions but the environment call from M-mode can be delegated.
+// Instructions can only write the supervisor bits of mip, the others are driven by devices. Of the environment
+// configuration, only FIOM is implemented.
+const (
+	csrMStatusWritable = MStatusSIE | MStatusMIE | MStatusSPIE | MStatusMPIE | MStatusSPP | MStatusMPP | MStatusFS |
+		MStatusMPRV | MStatusSUM | MStatusMXR | MStatusTVM | MStatusTW | MStatusTSR
+	csrMedelegWritable = 0xb3ff
+	csrMidelegWritable = MIPSSIP | MIPSTIP | MIPSEIP
+	csrInterrupts      = MIPSSIP | MIPMSIP | MIPSTIP | MIPMTIP | MIPSEIP | MIPMEIP
+	csrMipWritable     = MIPSSIP | MIPSTIP | MIPSEIP
+	csrEnvcfgWritable  = 1
+)
+
 type CSRStandard struct {
 	m [0x1000]uint64
 }
@@ -28,6 +81,20 @@ func (c *CSRStandard) Get(i uint64) uint64 {
 		return c.m[CSRfcsr] & 0x1f
 	case i == CSRfrm:
 		return c.m[CSRfcsr] & 0xe0 >> 5
+	case i == CSRmstatus:
+		return c.status()
+	case i == CSRsstatus:
+		return c.status() & SStatusMask
+	case i == CSRsie:
+		return c.m[CSRmie] & c.m[CSRmideleg]
+	case i == CSRsip:
+		return c.m[CSRmip] & c.m[CSRmideleg]
+	case i == CSRmisa:
+		return MISA
+	case i == CSRmcycle:
+		return c.m[CSRcycle]
+	case i == CSRminstret:
+		return c.m[CSRinstret]
 	case i == i:
 		return c.m[i]
 	}
@@ -44,11 +111,124 @@ func (c *CSRStandard) Set(i uint64, u uint64) {
 	case i == CSRfrm:
 		c.m[i] = u & 0x07
 		c.m[CSRfcsr] = c.m[CSRfcsr]&0xffffffffffffff1f | ((u & 0x07) << 5)
+	case i == CSRmstatus:
+		// MPP can not hold the reserved privilege level 2, the write leaves it unchanged.
+		if u&MStatusMPP == 2<<11 {
+			u = u&^MStatusMPP | c.m[i]&MStatusMPP
+		}
+		c.m[i] = u & csrMStatusWritable
+	case i == CSRsstatus:
+		c.m[CSRmstatus] = c.m[CSRmstatus]&^SStatusMask | u&SStatusMask&csrMStatusWritable
+	case i == CSRmedeleg:
+		c.m[i] = u & csrMedelegWritable
+	case i == CSRmideleg:
+		c.m[i] = u & csrMidelegWritable
+	case i == CSRmie:
+		c.m[i] = u & csrInterrupts
+	case i == CSRmip:
+		c.m[i] = u & csrInterrupts
+	case i == CSRsie:
+		c.m[CSRmie] = c.m[CSRmie]&^c.m[CSRmideleg] | u&c.m[CSRmideleg]
+	case i == CSRsip:
+		// Only the supervisor software interrupt can be raised by software.
+		mask := c.m[CSRmideleg] & MIPSSIP
+		c.m[CSRmip] = c.m[CSRmip]&^mask | u&mask
+	case i == CSRsatp:
+		// Only Sv39 and Sv48 are supported, a write of another mode has no effect.
+		if m := u >> 60; m != SATPModeBare && m != SATPModeSv39 && m != SATPModeSv48 {
+			return
+		}
+		c.m[i] = u
+	case i == CSRmepc || i == CSRsepc:
+		c.m[i] = u &^ 1
+	case i == CSRmtvec || i == CSRstvec:
+		// The modes above vectored are reserved, only direct and vectored can be set.
+		c.m[i] = u &^ 2
+	case i == CSRmcounteren || i == CSRscounteren:
+		c.m[i] = u & 0xffffffff
+	case i == CSRmenvcfg || i == CSRsenvcfg:
+		c.m[i] = u & csrEnvcfgWritable
+	case i == CSRmcycle:
+		c.m[CSRcycle] = u
+	case i == CSRminstret:
+		c.m[CSRinstret] = u
+	case i == CSRmisa, i >= CSRmvendorid && i <= CSRmconfigptr, i == CSRmcountinhibit:
+		// Hardwired.
+	case i >= CSRmhpmevent3 && i < CSRmhpmevent3+29, i >= CSRmhpmcounter3 && i < CSRmhpmcounter3+29:
+		// There are no performance-monitoring events, the counters are hardwired to zero.
+	case i == CSRpmpcfg0 || i == CSRpmpcfg2:
+		// Locked entries keep their configuration. W without R is reserved, W is cleared then.
+		r := uint64(0)
+		for j := uint64(0); j < 64; j += 8 {
+			e := u >> j & 0xff &^ 0x60
+			if c.m[i]>>j&PMPL != 0 {
+				e = c.m[i] >> j & 0xff
+			} else if e&(PMPR|PMPW) == PMPW {
+				e &^= PMPW
+			}
+			r |= e << j
+		}
+		c.m[i] = r
+	case i >= CSRpmpaddr0 && i < CSRpmpaddr0+pmpEntries:
+		// The address of a locked entry can not change, neither can the one below a locked entry of type TOR.
+		n := i - CSRpmpaddr0
+		if pmpConfig(c, n)&PMPL != 0 || n+1 < pmpEntries && pmpConfig(c, n+1)&(PMPL|PMPA) == PMPL|PMPTOR {
+			return
+		}
+		c.m[i] = u & (1<<54 - 1)
 	case i == i:
 		c.m[i] = u
 	}
 }
 
+// Write writes the CSR i as an instruction does. Only the bits of mip in csrMipWritable change.
+func (c *CSRStandard) Write(i uint64, u uint64) {
+	if i == CSRmip {
+		u = c.m[i]&^csrMipWritable | u&csrMipWritable
+	}
+	c.Set(i, u)
+}
+
+// Exists reports whether the CSR i is implemented.
+func (c *CSRStandard) Exists(i uint64) bool {
+	switch {
+	case i >= CSRfflags && i <= CSRfcsr:
+	case i >= CSRcycle && i < CSRhpmcounter3+29:
+	case i >= CSRmcycle && i < CSRmhpmcounter3+29:
+		return i != CSRmcycle+1
+	case i >= CSRmvendorid && i <= CSRmconfigptr:
+	case i >= CSRmstatus && i <= CSRmcounteren:
+	case i >= CSRmcountinhibit && i < CSRmhpmevent3+29:
+		return i != CSRmcountinhibit+1 && i != CSRmcountinhibit+2
+	case i >= CSRmscratch && i <= CSRmip:
+	case i >= CSRsscratch && i <= CSRsip:
+	case i >= CSRpmpaddr0 && i < CSRpmpaddr0+pmpEntries:
+	case i == CSRsstatus, i == CSRsie, i == CSRstvec, i == CSRscounteren, i == CSRsenvcfg, i == CSRsatp:
+	case i == CSRmenvcfg, i == CSRpmpcfg0, i == CSRpmpcfg2:
+	default:
+		return false
+	}
+	return true
+}
+
+// status returns mstatus. Both U-mode and S-mode are 64-bit, and SD summarizes the dirty state of FS and XS.
+func (c *CSRStandard) status() uint64 {
+	r := c.m[CSRmstatus] | 2<<32 | 2<<34
+	if r&MStatusFS == MStatusFS || r&MStatusXS == MStatusXS {
+		r |= MStatusSD
+	}
+	return r
+}
+
+// CSRPrivilege returns the lowest privilege level that can access the CSR i, encoded in bits 9:8 of its number.
+func CSRPrivilege(i uint64) uint64 {
+	return i >> 8 & 3
+}
+
+// NewCSRStandard returns the CSRs of a hart whose floating-point unit is on, mstatus.FS is Initial. The zero value has
+// it off, like a hart out of reset.
 func NewCSRStandard() CSR {
-	return &CSRStandard{}
+	c := &CSRStandard{}
+	c.m[CSRmstatus] = 1 << 13
+	return c
 }
@@ -0,0 +1,57 @@
+package rv64
+
+import (
+	"testing"
+)
+
+func TestCSRStandard(t *testing.T) {
+	c := NewCSRStandard()
+	for _, e := range []struct {
+		csr  uint64
+		set  uint64
+		want uint64
+	}{
+		{CSRmisa, 0, MISA},
+		{CSRmhartid, 1, 0},
+		{CSRmedeleg, ^uint64(0), 0xb3ff},
+		{CSRmideleg, ^uint64(0), MIPSSIP | MIPSTIP | MIPSEIP},
+		{CSRmtvec, 0x1003, 0x1001},
+		{CSRmstatus, MStatusXS | MStatusUXL | MStatusMIE, MStatusMIE | 2<<32 | 2<<34},
+		{CSRmcycle, 42, 42},
+	} {
+		c.Set(e.csr, e.set)
+		if r := c.Get(e.csr); r != e.want {
+			t.Fatalf("%#x: %#x", e.csr, r)
+		}
+	}
+	if c.Get(CSRcycle) != 42 {
+		t.Fatal(c.Get(CSRcycle))
+	}
+	// Instructions can not clear the interrupts driven by devices.
+	c.Set(CSRmip, MIPMTIP)
+	c.(*CSRStandard).Write(CSRmip, MIPSSIP)
+	if r := c.Get(CSRmip); r != MIPMTIP|MIPSSIP {
+		t.Fatalf("%#x", r)
+	}
+}
+
+func TestCPUCSRIllegal(t *testing.T) {
+	c := testCPU(map[uint64]uint32{
+		0x1000: 0xc0006573, // csrrsi a0, cycle, 0
+		0x1004: 0xc0051073, // csrw cycle, a0
+		0x1008: 0x7c002573, // csrr a0, 0x7c0
+		0x2000: 0x34302573, // csrr a0, mtval
+		0x2004: 0x05d00893, // li a7, 93
+		0x2008: 0x00000073, // ecall
+	})
+	c.GetCSR().Set(CSRmtvec, 0x2000)
+	c.SetPC(0x1000)
+	if c.Run(); c.GetCSR().Get(CSRmtval) != 0xc0051073 || c.GetCSR().Get(CSRmepc) != 0x1004 {
+		t.Fatalf("%#x", c.GetCSR().Get(CSRmtval))
+	}
+	c.SetStatus(0)
+	c.SetPC(0x1008)
+	if c.Run(); c.GetCSR().Get(CSRmtval) != 0x7c002573 {
+		t.Fatalf("%#x", c.GetCSR().Get(CSRmtval))
+	}
+}
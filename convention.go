@@ -79,12 +79,109 @@ const (
 )
 
 const (
-	CSRfflags  = 0x001 // Floating-Point Accrued Exceptions.
-	CSRfrm     = 0x002 // Floating-Point Dynamic Rounding Mode.
-	CSRfcsr    = 0x003 // Floating-Point Control and Status Register (frm + fflags).
-	CSRcycle   = 0xc00 // Cycle counter for RDCYCLE instruction.
-	CSRtime    = 0xc01 // Timer for RDTIME instruction.
-	CSRinstret = 0xc02 // Instructions-retired counter for RDINSTRET instruction.
+	CSRfflags        = 0x001 // Floating-Point Accrued Exceptions.
+	CSRfrm           = 0x002 // Floating-Point Dynamic Rounding Mode.
+	CSRfcsr          = 0x003 // Floating-Point Control and Status Register (frm + fflags).
+	CSRcycle         = 0xc00 // Cycle counter for RDCYCLE instruction.
+	CSRtime          = 0xc01 // Timer for RDTIME instruction.
+	CSRinstret       = 0xc02 // Instructions-retired counter for RDINSTRET instruction.
+	CSRhpmcounter3   = 0xc03 // Performance-monitoring counter, up to 31 at 0xc1f.
+	CSRsstatus       = 0x100 // Supervisor status register.
+	CSRsie           = 0x104 // Supervisor interrupt-enable register.
+	CSRstvec         = 0x105 // Supervisor trap handler base address.
+	CSRscounteren    = 0x106 // Supervisor counter enable.
+	CSRsenvcfg       = 0x10a // Supervisor environment configuration register.
+	CSRsscratch      = 0x140 // Scratch register for supervisor trap handlers.
+	CSRsepc          = 0x141 // Supervisor exception program counter.
+	CSRscause        = 0x142 // Supervisor trap cause.
+	CSRstval         = 0x143 // Supervisor bad address or instruction.
+	CSRsip           = 0x144 // Supervisor interrupt pending.
+	CSRsatp          = 0x180 // Supervisor address translation and protection.
+	CSRmvendorid     = 0xf11 // Vendor ID.
+	CSRmarchid       = 0xf12 // Architecture ID.
+	CSRmimpid        = 0xf13 // Implementation ID.
+	CSRmhartid       = 0xf14 // Hardware thread ID.
+	CSRmconfigptr    = 0xf15 // Pointer to configuration data structure.
+	CSRmstatus       = 0x300 // Machine status register.
+	CSRmisa          = 0x301 // ISA and extensions.
+	CSRmedeleg       = 0x302 // Machine exception delegation register.
+	CSRmideleg       = 0x303 // Machine interrupt delegation register.
+	CSRmie           = 0x304 // Machine interrupt-enable register.
+	CSRmtvec         = 0x305 // Machine trap-handler base address.
+	CSRmcounteren    = 0x306 // Machine counter enable.
+	CSRmenvcfg       = 0x30a // Machine environment configuration register.
+	CSRmcountinhibit = 0x320 // Machine counter-inhibit register.
+	CSRmhpmevent3    = 0x323 // Machine performance-monitoring event selector, up to 31 at 0x33f.
+	CSRmscratch      = 0x340 // Scratch register for machine trap handlers.
+	CSRmepc          = 0x341 // Machine exception program counter.
+	CSRmcause        = 0x342 // Machine trap cause.
+	CSRmtval         = 0x343 // Machine bad address or instruction.
+	CSRmip           = 0x344 // Machine interrupt pending.
+	CSRpmpcfg0       = 0x3a0 // Physical memory protection configuration of entries 0 to 7.
+	CSRpmpcfg2       = 0x3a2 // Physical memory protection configuration of entries 8 to 15.
+	CSRpmpaddr0      = 0x3b0 // Physical memory protection address of entry 0, up to 15 at 0x3bf.
+	CSRmcycle        = 0xb00 // Machine cycle counter.
+	CSRminstret      = 0xb02 // Machine instructions-retired counter.
+	CSRmhpmcounter3  = 0xb03 // Machine performance-monitoring counter, up to 31 at 0xb1f.
+)
+
+// Exception codes of mcause and scause. Interrupts have the same codes as their bits in mip, with the highest bit of
+// the cause set.
+const (
+	ExceptionInstructionAddressMisaligned uint64 = 0
+	ExceptionInstructionAccessFault       uint64 = 1
+	ExceptionIllegalInstruction           uint64 = 2
+	ExceptionBreakpoint                   uint64 = 3
+	ExceptionLoadAddressMisaligned        uint64 = 4
+	ExceptionLoadAccessFault              uint64 = 5
+	ExceptionStoreAddressMisaligned       uint64 = 6
+	ExceptionStoreAccessFault             uint64 = 7
+	ExceptionEcallU                       uint64 = 8
+	ExceptionEcallS                       uint64 = 9
+	ExceptionEcallM                       uint64 = 11
+	ExceptionInstructionPageFault         uint64 = 12
+	ExceptionLoadPageFault                uint64 = 13
+	ExceptionStorePageFault               uint64 = 15
+	ExceptionInterrupt                    uint64 = 1 << 63
+)
+
+// Privilege levels.
+const (
+	PrivilegeU uint64 = 0
+	PrivilegeS uint64 = 1
+	PrivilegeM uint64 = 3
+)
+
+// Fields of the mstatus register. The fields in SStatusMask are also the sstatus register.
+const (
+	MStatusSIE  uint64 = 1 << 1
+	MStatusMIE  uint64 = 1 << 3
+	MStatusSPIE uint64 = 1 << 5
+	MStatusMPIE uint64 = 1 << 7
+	MStatusSPP  uint64 = 1 << 8
+	MStatusMPP  uint64 = 3 << 11
+	MStatusFS   uint64 = 3 << 13
+	MStatusXS   uint64 = 3 << 15
+	MStatusMPRV uint64 = 1 << 17
+	MStatusSUM  uint64 = 1 << 18
+	MStatusMXR  uint64 = 1 << 19
+	MStatusTVM  uint64 = 1 << 20
+	MStatusTW   uint64 = 1 << 21
+	MStatusTSR  uint64 = 1 << 22
+	MStatusUXL  uint64 = 3 << 32
+	MStatusSXL  uint64 = 3 << 34
+	MStatusSD   uint64 = 1 << 63
+	SStatusMask        = MStatusSIE | MStatusSPIE | MStatusSPP | MStatusFS | MStatusXS | MStatusSUM | MStatusMXR | MStatusUXL | MStatusSD
+)
+
+// Bits of the mip and mie registers.
+const (
+	MIPSSIP uint64 = 1 << 1  // Supervisor software interrupt
+	MIPMSIP uint64 = 1 << 3  // Machine software interrupt
+	MIPSTIP uint64 = 1 << 5  // Supervisor timer interrupt
+	MIPMTIP uint64 = 1 << 7  // Machine timer interrupt
+	MIPSEIP uint64 = 1 << 9  // Supervisor external interrupt
+	MIPMEIP uint64 = 1 << 11 // Machine external interrupt
 )
 
 const (
@@ -137,10 +234,25 @@ const (
 var (
 	ErrAbnormalEcall              = errors.New("Abnormal ecall")
 	ErrAbnormalInstruction        = errors.New("Abnormal instruction")
+	ErrBreakpoint                 = errors.New("Breakpoint")
+	ErrDeviceOverlap              = errors.New("Device overlaps another device")
+	ErrELFClass                   = errors.New("ELF is not 64-bit")
+	ErrELFData                    = errors.New("ELF is not little-endian")
+	ErrELFMachine                 = errors.New("ELF is not RISC-V")
+	ErrELFSegment                 = errors.New("Malformed ELF segment")
+	ErrELFType                    = errors.New("ELF is not an executable")
+	ErrEnvironmentCall            = errors.New("Environment call")
 	ErrMisalignedInstructionFetch = errors.New("Misaligned instruction fetch")
 	ErrOutOfMemory                = errors.New("Out of memory")
 	ErrReservedInstruction        = errors.New("Reserved instruction")
 	ErrHint                       = errors.New("Hint")
+	ErrInstructionAccessFault     = errors.New("Instruction access fault")
+	ErrInstructionPageFault       = errors.New("Instruction page fault")
+	ErrLoadAccessFault            = errors.New("Load access fault")
+	ErrLoadPageFault              = errors.New("Load page fault")
+	ErrStoreAccessFault           = errors.New("Store access fault")
+	ErrStorePageFault             = errors.New("Store page fault")
+	ErrTrapHandler                = errors.New("No trap handler")
 )
 
 var (
@@ -4,37 +4,115 @@ import (
 	"encoding/binary"
 	"fmt"
 	"math"
+	"time"
 )
 
 type CPU struct {
-	fasten Fasten
-	system System
-	csr    CSR
-	reg0   [32]uint64
-	reg1   [32]uint64
-	pc     uint64
-	lraddr uint64
-	status uint64
+	fasten  Fasten
+	system  System
+	clock   Clock
+	csr     CSR
+	reg0    [32]uint64
+	reg1    [32]uint64
+	pc      uint64
+	lraddr  uint64
+	status  uint64
+	priv    uint64
+	wait    bool
+	halt    bool
+	code    uint8
+	signals bool
+	mmu     *mmu
+	ticker  []DeviceTicker
 }
 
+func (c *CPU) GetClock() Clock  { return c.clock }
+func (c *CPU) SetClock(k Clock) { c.clock = k }
+
 func (c *CPU) GetCSR() CSR    { return c.csr }
 func (c *CPU) SetCSR(csr CSR) { c.csr = csr }
 
 func (c *CPU) GetLoadReservation() uint64  { return c.lraddr }
 func (c *CPU) SetLoadReservation(a uint64) { c.lraddr = a }
 
-func (c *CPU) GetMemory() *Memory { return &Memory{Fasten: c.fasten} }
+// GetMemory returns the memory seen by the hart. Addresses are virtual when address translation is enabled in satp,
+// see mmu.
+func (c *CPU) GetMemory() *Memory {
+	if c.csr == nil || c.csr.Get(CSRsatp)>>60 == SATPModeBare {
+		return &Memory{Fasten: c.physical(false)}
+	}
+	if c.mmu == nil {
+		c.mmu = &mmu{cpu: c, tlb: map[uint64]mmuEntry{}}
+	}
+	return &Memory{Fasten: c.mmu}
+}
 func (c *CPU) SetFasten(f Fasten) { c.fasten = f }
 
+// physical returns the physical memory of the hart, checked by the PMP once one of its entries is enabled. Page table
+// accesses are checked in S-mode, set walk for them.
+func (c *CPU) physical(walk bool) Fasten {
+	if c.csr == nil || (c.csr.Get(CSRpmpcfg0)|c.csr.Get(CSRpmpcfg2))&pmpEnabled == 0 {
+		return c.fasten
+	}
+	return &pmp{cpu: c, walk: walk}
+}
+
 func (c *CPU) GetPC() uint64  { return c.pc }
 func (c *CPU) SetPC(i uint64) { c.pc = i }
 
-func (c *CPU) GetStatus() uint64  { return c.status }
-func (c *CPU) SetStatus(i uint64) { c.status = i }
+func (c *CPU) GetPrivilege() uint64  { return c.priv }
+func (c *CPU) SetPrivilege(p uint64) { c.priv = p }
+
+// privilege returns the effective privilege level of an access with permission perm. Loads and stores in M-mode use
+// the privilege level in mstatus.MPP when mstatus.MPRV is set, instruction fetches always use the current one.
+func (c *CPU) privilege(perm uint8) uint64 {
+	p := c.GetPrivilege()
+	if s := c.GetCSR().Get(CSRmstatus); perm != PermX && p == PrivilegeM && s&MStatusMPRV != 0 {
+		p = s & MStatusMPP >> 11
+	}
+	return p
+}
+
+func (c *CPU) GetStatus() uint64 { return c.status }
+func (c *CPU) SetStatus(i uint64) {
+	c.status = i
+	c.halt = false
+}
+
+// Halt stops the hart with the exit status code, which Run returns instead of the exit code of the system.
+func (c *CPU) Halt(code uint8) {
+	c.SetStatus(1)
+	c.halt = true
+	c.code = code
+}
+
+// Code returns the exit status of a stopped hart.
+func (c *CPU) Code() uint8 {
+	if c.halt || c.GetSystem() == nil {
+		return c.code
+	}
+	return c.GetSystem().Code()
+}
+
+// SetSignals makes the exceptions stop the hart like a process killed by a signal, instead of trapping. It is meant for
+// a user process whose kernel is emulated by a System, there is no firmware or kernel code to handle the traps.
+func (c *CPU) SetSignals(b bool) { c.signals = b }
 
 func (c *CPU) GetSystem() System  { return c.system }
 func (c *CPU) SetSystem(s System) { c.system = s }
 
+// AddTicker makes Run call t after every instruction.
+func (c *CPU) AddTicker(t DeviceTicker) { c.ticker = append(c.ticker, t) }
+
+// SetPending raises or lowers the interrupt pending bits mask of mip, see MIPMTIP.
+func (c *CPU) SetPending(mask uint64, level bool) {
+	if level {
+		c.csr.Set(CSRmip, c.csr.Get(CSRmip)|mask)
+	} else {
+		c.csr.Set(CSRmip, c.csr.Get(CSRmip)&^mask)
+	}
+}
+
 func (c *CPU) SetRegister(i uint64, u uint64) {
 	if i == Rzero {
 		return
@@ -69,9 +147,6 @@ func (c *CPU) SetFloatFlag(flag uint64, b int) {
 		c.csr.Set(CSRfcsr, c.csr.Get(CSRfcsr)|flag)
 	}
 }
-func (c *CPU) ClrFloatFlag() {
-	c.csr.Set(CSRfcsr, c.csr.Get(CSRfcsr)&0xffffffffffffffe0)
-}
 
 func (c *CPU) PushString(s string) {
 	b := append([]byte(s), 0x00)
@@ -100,5 +175,8 @@ func (c *CPU) LogF(n uint64) string {
 }
 
 func NewCPU() *CPU {
-	return &CPU{}
+	return &CPU{
+		clock: NewClockCycle(time.Unix(0, 0)),
+		priv:  PrivilegeM,
+	}
 }
@@ -465,12 +465,16 @@ func (_ *isaI) fence(c *CPU, _ uint64) (uint64, error) {
 
 func (_ *isaI) ecall(c *CPU, _ uint64) (uint64, error) {
 	Debugln(fmt.Sprintf("%#08x % 10s", c.GetPC(), "ecall"))
+	// Without a system emulating the environment, the call traps to the code running in a higher privilege.
+	if c.GetSystem() == nil {
+		return 0, ErrEnvironmentCall
+	}
 	return c.GetSystem().HandleCall(c)
 }
 
 func (_ *isaI) ebreak(c *CPU, _ uint64) (uint64, error) {
 	Debugln(fmt.Sprintf("%#08x % 10s", c.GetPC(), "ebreak"))
-	return 1, nil
+	return 0, ErrBreakpoint
 }
 
 func (_ *isaI) addiw(c *CPU, i uint64) (uint64, error) {
@@ -575,12 +579,15 @@ type isaZicsr struct{}
 func (_ *isaZicsr) csrrw(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, csr := IType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s csr: ----(%#016x)", c.GetPC(), "csrrw", c.LogI(rd), c.LogI(rs1), csr))
+	if !c.csrAccessible(csr, true) {
+		return 0, ErrAbnormalInstruction
+	}
 	a := c.GetRegister(rs1)
 	b := c.GetCSR().Get(csr)
 	if rd != Rzero {
 		c.SetRegister(rd, b)
 	}
-	c.GetCSR().Set(csr, a)
+	c.writeCSR(csr, a)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -588,11 +595,14 @@ func (_ *isaZicsr) csrrw(c *CPU, i uint64) (uint64, error) {
 func (_ *isaZicsr) csrrs(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, csr := IType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s csr: ----(%#016x)", c.GetPC(), "csrrs", c.LogI(rd), c.LogI(rs1), csr))
+	if !c.csrAccessible(csr, rs1 != Rzero) {
+		return 0, ErrAbnormalInstruction
+	}
 	a := c.GetRegister(rs1)
 	b := c.GetCSR().Get(csr)
 	c.SetRegister(rd, b)
 	if rs1 != Rzero {
-		c.GetCSR().Set(csr, b|a)
+		c.writeCSR(csr, b|a)
 	}
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
@@ -601,11 +611,14 @@ func (_ *isaZicsr) csrrs(c *CPU, i uint64) (uint64, error) {
 func (_ *isaZicsr) csrrc(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, csr := IType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s csr: ----(%#016x)", c.GetPC(), "csrrc", c.LogI(rd), c.LogI(rs1), csr))
+	if !c.csrAccessible(csr, rs1 != Rzero) {
+		return 0, ErrAbnormalInstruction
+	}
 	a := c.GetRegister(rs1)
 	b := c.GetCSR().Get(csr)
 	c.SetRegister(rd, b)
 	if rs1 != Rzero {
-		c.GetCSR().Set(csr, b&^a)
+		c.writeCSR(csr, b&^a)
 	}
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
@@ -614,11 +627,14 @@ func (_ *isaZicsr) csrrc(c *CPU, i uint64) (uint64, error) {
 func (_ *isaZicsr) csrrwi(c *CPU, i uint64) (uint64, error) {
 	rd, imm, csr := IType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s imm: ----(%#016x) csr: ----(%#016x)", c.GetPC(), "csrrwi", c.LogI(rd), imm, csr))
+	if !c.csrAccessible(csr, true) {
+		return 0, ErrAbnormalInstruction
+	}
 	b := c.GetCSR().Get(csr)
 	if rd != Rzero {
 		c.SetRegister(rd, b)
 	}
-	c.GetCSR().Set(csr, imm)
+	c.writeCSR(csr, imm)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -626,10 +642,13 @@ func (_ *isaZicsr) csrrwi(c *CPU, i uint64) (uint64, error) {
 func (_ *isaZicsr) csrrsi(c *CPU, i uint64) (uint64, error) {
 	rd, imm, csr := IType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s imm: ----(%#016x) csr: ----(%#016x)", c.GetPC(), "csrrsi", c.LogI(rd), imm, csr))
+	if !c.csrAccessible(csr, imm != 0) {
+		return 0, ErrAbnormalInstruction
+	}
 	b := c.GetCSR().Get(csr)
 	c.SetRegister(rd, b)
-	if csr != 0x00 {
-		c.GetCSR().Set(csr, b|imm)
+	if imm != 0 {
+		c.writeCSR(csr, b|imm)
 	}
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
@@ -638,10 +657,13 @@ func (_ *isaZicsr) csrrsi(c *CPU, i uint64) (uint64, error) {
 func (_ *isaZicsr) csrrci(c *CPU, i uint64) (uint64, error) {
 	rd, imm, csr := IType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s imm: ----(%#016x) csr: ----(%#016x)", c.GetPC(), "csrrci", c.LogI(rd), imm, csr))
+	if !c.csrAccessible(csr, imm != 0) {
+		return 0, ErrAbnormalInstruction
+	}
 	b := c.GetCSR().Get(csr)
 	c.SetRegister(rd, b)
-	if csr != 0x00 {
-		c.GetCSR().Set(csr, b&^imm)
+	if imm != 0 {
+		c.writeCSR(csr, b&^imm)
 	}
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
@@ -1217,15 +1239,14 @@ func (_ *isaF) fsw(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fmadds(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2, rs3 := R4Type(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s rs3: %s", c.GetPC(), "fmadd.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2), c.LogF(rs3)))
-	c.ClrFloatFlag()
-	a := c.GetRegisterFloatAsFloat32(rs1)
-	b := c.GetRegisterFloatAsFloat32(rs2)
-	d := c.GetRegisterFloatAsFloat32(rs3)
-	r := a*b + d
-	c.SetRegisterFloatAsFloat32(rd, r)
-	if r-d != a*b || r-a*b != d || (r-d)/a != b || (r-d)/b != a {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	a, b, d := c.getFloat(SoftFloat32, rs1), c.getFloat(SoftFloat32, rs2), c.getFloat(SoftFloat32, rs3)
+	r, flags := SoftFloat32.MulAdd(a, b, d, rm)
+	c.setFloat(SoftFloat32, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1233,15 +1254,14 @@ func (_ *isaF) fmadds(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fmsubs(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2, rs3 := R4Type(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s rs3: %s", c.GetPC(), "fmsub.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2), c.LogF(rs3)))
-	c.ClrFloatFlag()
-	a := c.GetRegisterFloatAsFloat32(rs1)
-	b := c.GetRegisterFloatAsFloat32(rs2)
-	d := c.GetRegisterFloatAsFloat32(rs3)
-	r := a*b - d
-	c.SetRegisterFloatAsFloat32(rd, r)
-	if r+d != a*b || a*b-r != d || (r+d)/a != b || (r+d)/b != a {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	a, b, d := c.getFloat(SoftFloat32, rs1), c.getFloat(SoftFloat32, rs2), c.getFloat(SoftFloat32, rs3)
+	r, flags := SoftFloat32.MulAdd(a, b, SoftFloat32.neg(d), rm)
+	c.setFloat(SoftFloat32, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1249,15 +1269,15 @@ func (_ *isaF) fmsubs(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fnmsubs(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2, rs3 := R4Type(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s rs3: %s", c.GetPC(), "fnmsub.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2), c.LogF(rs3)))
-	c.ClrFloatFlag()
-	a := c.GetRegisterFloatAsFloat32(rs1)
-	b := c.GetRegisterFloatAsFloat32(rs2)
-	d := c.GetRegisterFloatAsFloat32(rs3)
-	r := a*b - d
-	c.SetRegisterFloatAsFloat32(rd, -r)
-	if r+d != a*b || a*b-r != d || (r+d)/a != b || (r+d)/b != a {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	a, b, d := c.getFloat(SoftFloat32, rs1), c.getFloat(SoftFloat32, rs2), c.getFloat(SoftFloat32, rs3)
+	// -(a × b) + d
+	r, flags := SoftFloat32.MulAdd(SoftFloat32.neg(a), b, d, rm)
+	c.setFloat(SoftFloat32, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1265,15 +1285,15 @@ func (_ *isaF) fnmsubs(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fnmadds(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2, rs3 := R4Type(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s rs3: %s", c.GetPC(), "fnmadd.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2), c.LogF(rs3)))
-	c.ClrFloatFlag()
-	a := c.GetRegisterFloatAsFloat32(rs1)
-	b := c.GetRegisterFloatAsFloat32(rs2)
-	d := c.GetRegisterFloatAsFloat32(rs3)
-	r := a*b + d
-	c.SetRegisterFloatAsFloat32(rd, -r)
-	if r-d != a*b || r-a*b != d || (r-d)/a != b || (r-d)/b != a {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	a, b, d := c.getFloat(SoftFloat32, rs1), c.getFloat(SoftFloat32, rs2), c.getFloat(SoftFloat32, rs3)
+	// -(a × b) - d
+	r, flags := SoftFloat32.MulAdd(SoftFloat32.neg(a), b, SoftFloat32.neg(d), rm)
+	c.setFloat(SoftFloat32, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1281,14 +1301,13 @@ func (_ *isaF) fnmadds(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fadds(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fadd.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat32(rs1)
-	b := c.GetRegisterFloatAsFloat32(rs2)
-	c.ClrFloatFlag()
-	d := a + b
-	c.SetRegisterFloatAsFloat32(rd, d)
-	if d-a != b || d-b != a {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat32.Add(c.getFloat(SoftFloat32, rs1), c.getFloat(SoftFloat32, rs2), rm)
+	c.setFloat(SoftFloat32, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1296,20 +1315,13 @@ func (_ *isaF) fadds(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fsubs(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fsub.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat32(rs1)
-	b := c.GetRegisterFloatAsFloat32(rs2)
-	c.ClrFloatFlag()
-	if (math.Signbit(float64(a)) == math.Signbit(float64(b))) && math.IsInf(float64(a), 0) && math.IsInf(float64(b), 0) {
-		c.SetRegisterFloat(rd, 0xffffffff00000000|uint64(NaN32))
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	d := a - b
-	c.SetRegisterFloatAsFloat32(rd, d)
-	if a-d != b || b+d != a {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat32.Sub(c.getFloat(SoftFloat32, rs1), c.getFloat(SoftFloat32, rs2), rm)
+	c.setFloat(SoftFloat32, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1317,14 +1329,13 @@ func (_ *isaF) fsubs(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fmuls(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fmul.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat32(rs1)
-	b := c.GetRegisterFloatAsFloat32(rs2)
-	c.ClrFloatFlag()
-	d := a * b
-	c.SetRegisterFloatAsFloat32(rd, d)
-	if d/a != b || d/b != a || float64(a)*float64(b) != float64(d) {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat32.Mul(c.getFloat(SoftFloat32, rs1), c.getFloat(SoftFloat32, rs2), rm)
+	c.setFloat(SoftFloat32, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1332,20 +1343,13 @@ func (_ *isaF) fmuls(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fdivs(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fdiv.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat32(rs1)
-	b := c.GetRegisterFloatAsFloat32(rs2)
-	c.ClrFloatFlag()
-	if b == 0 {
-		c.SetRegisterFloat(rd, 0xffffffff00000000|uint64(NaN32))
-		c.SetFloatFlag(FFlagsDZ, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	d := a / b
-	c.SetRegisterFloatAsFloat32(rd, d)
-	if a/d != b || b*d != a || float64(b)*float64(d) != float64(a) {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat32.Div(c.getFloat(SoftFloat32, rs1), c.getFloat(SoftFloat32, rs2), rm)
+	c.setFloat(SoftFloat32, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1353,19 +1357,13 @@ func (_ *isaF) fdivs(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fsqrts(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fsqrt.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat32(rs1)
-	c.ClrFloatFlag()
-	if a < 0 {
-		c.SetRegisterFloat(rd, 0xffffffff00000000|uint64(NaN32))
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	d := float32(math.Sqrt(float64(a)))
-	c.SetRegisterFloatAsFloat32(rd, d)
-	if a/d != d || d*d != a || float64(d)*float64(d) != float64(a) {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat32.Sqrt(c.getFloat(SoftFloat32, rs1), rm)
+	c.setFloat(SoftFloat32, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1415,35 +1413,9 @@ func (_ *isaF) fsgnjxs(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fmins(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fmin.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat32(rs1)
-	b := c.GetRegisterFloatAsFloat32(rs2)
-	c.ClrFloatFlag()
-	if math.IsNaN(float64(a)) && math.IsNaN(float64(b)) {
-		c.SetRegisterFloat(rd, 0xffffffff00000000|uint64(NaN32))
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if math.IsNaN(float64(a)) {
-		c.SetRegisterFloatAsFloat32(rd, b)
-		if IsSNaN32(a) {
-			c.SetFloatFlag(FFlagsNV, 1)
-		}
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if math.IsNaN(float64(b)) {
-		c.SetRegisterFloatAsFloat32(rd, a)
-		if IsSNaN32(b) {
-			c.SetFloatFlag(FFlagsNV, 1)
-		}
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if (math.Signbit(float64(a)) && !math.Signbit(float64(b))) || a < b {
-		c.SetRegisterFloatAsFloat32(rd, a)
-	} else {
-		c.SetRegisterFloatAsFloat32(rd, b)
-	}
+	r, flags := SoftFloat32.Min(c.getFloat(SoftFloat32, rs1), c.getFloat(SoftFloat32, rs2))
+	c.setFloat(SoftFloat32, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1451,35 +1423,9 @@ func (_ *isaF) fmins(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fmaxs(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fmax.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat32(rs1)
-	b := c.GetRegisterFloatAsFloat32(rs2)
-	c.ClrFloatFlag()
-	if math.IsNaN(float64(a)) && math.IsNaN(float64(b)) {
-		c.SetRegisterFloat(rd, 0xffffffff00000000|uint64(NaN32))
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if math.IsNaN(float64(a)) {
-		c.SetRegisterFloatAsFloat32(rd, b)
-		if IsSNaN32(a) {
-			c.SetFloatFlag(FFlagsNV, 1)
-		}
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if math.IsNaN(float64(b)) {
-		c.SetRegisterFloatAsFloat32(rd, a)
-		if IsSNaN32(b) {
-			c.SetFloatFlag(FFlagsNV, 1)
-		}
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if (!math.Signbit(float64(a)) && math.Signbit(float64(b))) || a > b {
-		c.SetRegisterFloatAsFloat32(rd, a)
-	} else {
-		c.SetRegisterFloatAsFloat32(rd, b)
-	}
+	r, flags := SoftFloat32.Max(c.getFloat(SoftFloat32, rs1), c.getFloat(SoftFloat32, rs2))
+	c.setFloat(SoftFloat32, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1487,28 +1433,13 @@ func (_ *isaF) fmaxs(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fcvtws(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.w.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	d := c.GetRegisterFloatAsFloat32(rs1)
-	if math.IsNaN(float64(d)) {
-		c.SetRegister(rd, 0x7fffffff)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if d > float32(math.MaxInt32) {
-		c.SetRegister(rd, SignExtend(0x7fffffff, 31))
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if d < float32(math.MinInt32) {
-		c.SetRegister(rd, SignExtend(0x80000000, 31))
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	c.SetRegister(rd, SignExtend(uint64(int32(d)), 31))
-	if math.Ceil(float64(d)) != float64(d) {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat32.ToInt(c.getFloat(SoftFloat32, rs1), 32, true, rm)
+	c.SetRegister(rd, SignExtend(r, 31))
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1516,28 +1447,13 @@ func (_ *isaF) fcvtws(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fcvtwus(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.wu.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	d := c.GetRegisterFloatAsFloat32(rs1)
-	if math.IsNaN(float64(d)) {
-		c.SetRegister(rd, 0xffffffffffffffff)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if d > float32(math.MaxUint32) {
-		c.SetRegister(rd, SignExtend(0xffffffff, 31))
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if d <= float32(-1) {
-		c.SetRegister(rd, SignExtend(0x00000000, 31))
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	c.SetRegister(rd, SignExtend(uint64(uint32(d)), 31))
-	if math.Ceil(float64(d)) != float64(d) {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat32.ToInt(c.getFloat(SoftFloat32, rs1), 32, false, rm)
+	c.SetRegister(rd, SignExtend(r, 31))
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1553,19 +1469,13 @@ func (_ *isaF) fmvxw(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) feqs(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "feq.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat32(rs1)
-	b := c.GetRegisterFloatAsFloat32(rs2)
-	var cond bool
-	if IsSNaN32(a) || IsSNaN32(b) {
-		c.SetFloatFlag(FFlagsNV, 1)
-	} else {
-		cond = a == b
-	}
+	cond, flags := SoftFloat32.Eq(c.getFloat(SoftFloat32, rs1), c.getFloat(SoftFloat32, rs2))
 	if cond {
 		c.SetRegister(rd, 1)
 	} else {
 		c.SetRegister(rd, 0)
 	}
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1573,19 +1483,13 @@ func (_ *isaF) feqs(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) flts(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "flt.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat32(rs1)
-	b := c.GetRegisterFloatAsFloat32(rs2)
-	var cond bool
-	if math.IsNaN(float64(a)) || math.IsNaN(float64(b)) {
-		c.SetFloatFlag(FFlagsNV, 1)
-	} else {
-		cond = a < b
-	}
+	cond, flags := SoftFloat32.Lt(c.getFloat(SoftFloat32, rs1), c.getFloat(SoftFloat32, rs2))
 	if cond {
 		c.SetRegister(rd, 1)
 	} else {
 		c.SetRegister(rd, 0)
 	}
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1593,19 +1497,13 @@ func (_ *isaF) flts(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fles(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fle.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat32(rs1)
-	b := c.GetRegisterFloatAsFloat32(rs2)
-	var cond bool
-	if math.IsNaN(float64(a)) || math.IsNaN(float64(b)) {
-		c.SetFloatFlag(FFlagsNV, 1)
-	} else {
-		cond = a <= b
-	}
+	cond, flags := SoftFloat32.Le(c.getFloat(SoftFloat32, rs1), c.getFloat(SoftFloat32, rs2))
 	if cond {
 		c.SetRegister(rd, 1)
 	} else {
 		c.SetRegister(rd, 0)
 	}
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1622,7 +1520,13 @@ func (_ *isaF) fclasss(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fcvtsw(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.s.w", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	c.SetRegisterFloatAsFloat32(rd, float32(int32(c.GetRegister(rs1))))
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
+	}
+	r, flags := SoftFloat32.FromInt(SignExtend(c.GetRegister(rs1), 31), true, rm)
+	c.setFloat(SoftFloat32, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1630,7 +1534,13 @@ func (_ *isaF) fcvtsw(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fcvtswu(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.s.wu", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	c.SetRegisterFloatAsFloat32(rd, float32(uint32(c.GetRegister(rs1))))
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
+	}
+	r, flags := SoftFloat32.FromInt(c.GetRegister(rs1)&0xffffffff, false, rm)
+	c.setFloat(SoftFloat32, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1646,28 +1556,13 @@ func (_ *isaF) fmvwx(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fcvtls(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.l.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	d := c.GetRegisterFloatAsFloat32(rs1)
-	if math.IsNaN(float64(d)) {
-		c.SetRegister(rd, 0x7fffffffffffffff)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if d > float32(math.MaxInt64) {
-		c.SetRegister(rd, 0x7fffffffffffffff)
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if d < float32(math.MinInt64) {
-		c.SetRegister(rd, 0x8000000000000000)
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	c.SetRegister(rd, uint64(int64(d)))
-	if math.Ceil(float64(d)) != float64(d) {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat32.ToInt(c.getFloat(SoftFloat32, rs1), 64, true, rm)
+	c.SetRegister(rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1675,28 +1570,13 @@ func (_ *isaF) fcvtls(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fcvtlus(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.lu.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	d := c.GetRegisterFloatAsFloat32(rs1)
-	if math.IsNaN(float64(d)) {
-		c.SetRegister(rd, 0xffffffffffffffff)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if d > float32(math.MaxUint64) {
-		c.SetRegister(rd, 0xffffffffffffffff)
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if d <= float32(-1) {
-		c.SetRegister(rd, 0x0000000000000000)
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	c.SetRegister(rd, uint64(d))
-	if math.Ceil(float64(d)) != float64(d) {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat32.ToInt(c.getFloat(SoftFloat32, rs1), 64, false, rm)
+	c.SetRegister(rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1704,7 +1584,13 @@ func (_ *isaF) fcvtlus(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fcvtsl(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.s.l", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	c.SetRegisterFloatAsFloat32(rd, float32(int64(c.GetRegister(rs1))))
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
+	}
+	r, flags := SoftFloat32.FromInt(c.GetRegister(rs1), true, rm)
+	c.setFloat(SoftFloat32, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1712,7 +1598,13 @@ func (_ *isaF) fcvtsl(c *CPU, i uint64) (uint64, error) {
 func (_ *isaF) fcvtslu(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.s.lu", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	c.SetRegisterFloatAsFloat32(rd, float32(uint64(c.GetRegister(rs1))))
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
+	}
+	r, flags := SoftFloat32.FromInt(c.GetRegister(rs1), false, rm)
+	c.setFloat(SoftFloat32, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1748,15 +1640,14 @@ func (_ *isaD) fsd(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fmaddd(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2, rs3 := R4Type(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s rs3: %s", c.GetPC(), "fmadd.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2), c.LogF(rs3)))
-	c.ClrFloatFlag()
-	a := c.GetRegisterFloatAsFloat64(rs1)
-	b := c.GetRegisterFloatAsFloat64(rs2)
-	d := c.GetRegisterFloatAsFloat64(rs3)
-	r := a*b + d
-	c.SetRegisterFloatAsFloat64(rd, r)
-	if r-d != a*b || r-a*b != d || (r-d)/a != b || (r-d)/b != a {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	a, b, d := c.getFloat(SoftFloat64, rs1), c.getFloat(SoftFloat64, rs2), c.getFloat(SoftFloat64, rs3)
+	r, flags := SoftFloat64.MulAdd(a, b, d, rm)
+	c.setFloat(SoftFloat64, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1764,15 +1655,14 @@ func (_ *isaD) fmaddd(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fmsubd(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2, rs3 := R4Type(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s rs3: %s", c.GetPC(), "fmsub.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2), c.LogF(rs3)))
-	c.ClrFloatFlag()
-	a := c.GetRegisterFloatAsFloat64(rs1)
-	b := c.GetRegisterFloatAsFloat64(rs2)
-	d := c.GetRegisterFloatAsFloat64(rs3)
-	r := a*b - d
-	c.SetRegisterFloatAsFloat64(rd, r)
-	if r+d != a*b || a*b-r != d || (r+d)/a != b || (r+d)/b != a {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	a, b, d := c.getFloat(SoftFloat64, rs1), c.getFloat(SoftFloat64, rs2), c.getFloat(SoftFloat64, rs3)
+	r, flags := SoftFloat64.MulAdd(a, b, SoftFloat64.neg(d), rm)
+	c.setFloat(SoftFloat64, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1780,15 +1670,15 @@ func (_ *isaD) fmsubd(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fnmsubd(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2, rs3 := R4Type(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s rs3: %s", c.GetPC(), "fnmsub.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2), c.LogF(rs3)))
-	c.ClrFloatFlag()
-	a := c.GetRegisterFloatAsFloat64(rs1)
-	b := c.GetRegisterFloatAsFloat64(rs2)
-	d := c.GetRegisterFloatAsFloat64(rs3)
-	r := a*b - d
-	c.SetRegisterFloatAsFloat64(rd, -r)
-	if r+d != a*b || a*b-r != d || (r+d)/a != b || (r+d)/b != a {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	a, b, d := c.getFloat(SoftFloat64, rs1), c.getFloat(SoftFloat64, rs2), c.getFloat(SoftFloat64, rs3)
+	// -(a × b) + d
+	r, flags := SoftFloat64.MulAdd(SoftFloat64.neg(a), b, d, rm)
+	c.setFloat(SoftFloat64, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1796,15 +1686,15 @@ func (_ *isaD) fnmsubd(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fnmaddd(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2, rs3 := R4Type(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s rs3: %s", c.GetPC(), "fnmadd.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2), c.LogF(rs3)))
-	c.ClrFloatFlag()
-	a := c.GetRegisterFloatAsFloat64(rs1)
-	b := c.GetRegisterFloatAsFloat64(rs2)
-	d := c.GetRegisterFloatAsFloat64(rs3)
-	r := a*b + d
-	c.SetRegisterFloatAsFloat64(rd, -r)
-	if r-d != a*b || r-a*b != d || (r-d)/a != b || (r-d)/b != a {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	a, b, d := c.getFloat(SoftFloat64, rs1), c.getFloat(SoftFloat64, rs2), c.getFloat(SoftFloat64, rs3)
+	// -(a × b) - d
+	r, flags := SoftFloat64.MulAdd(SoftFloat64.neg(a), b, SoftFloat64.neg(d), rm)
+	c.setFloat(SoftFloat64, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1812,13 +1702,13 @@ func (_ *isaD) fnmaddd(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) faddd(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fadd.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat64(rs1)
-	b := c.GetRegisterFloatAsFloat64(rs2)
-	c.ClrFloatFlag()
-	c.SetRegisterFloatAsFloat64(rd, a+b)
-	if big.NewFloat(0).Add(big.NewFloat(a), big.NewFloat(b)).Acc() != big.Exact {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat64.Add(c.getFloat(SoftFloat64, rs1), c.getFloat(SoftFloat64, rs2), rm)
+	c.setFloat(SoftFloat64, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1826,19 +1716,13 @@ func (_ *isaD) faddd(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fsubd(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fsub.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat64(rs1)
-	b := c.GetRegisterFloatAsFloat64(rs2)
-	c.ClrFloatFlag()
-	if (math.Signbit(a) == math.Signbit(b)) && math.IsInf(a, 0) && math.IsInf(b, 0) {
-		c.SetRegisterFloat(rd, NaN64)
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	c.SetRegisterFloatAsFloat64(rd, a-b)
-	if big.NewFloat(0).Sub(big.NewFloat(a), big.NewFloat(b)).Acc() != big.Exact {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat64.Sub(c.getFloat(SoftFloat64, rs1), c.getFloat(SoftFloat64, rs2), rm)
+	c.setFloat(SoftFloat64, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1846,13 +1730,13 @@ func (_ *isaD) fsubd(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fmuld(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fmul.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat64(rs1)
-	b := c.GetRegisterFloatAsFloat64(rs2)
-	c.ClrFloatFlag()
-	c.SetRegisterFloatAsFloat64(rd, a*b)
-	if big.NewFloat(0).Add(big.NewFloat(a), big.NewFloat(b)).Acc() != big.Exact {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat64.Mul(c.getFloat(SoftFloat64, rs1), c.getFloat(SoftFloat64, rs2), rm)
+	c.setFloat(SoftFloat64, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1860,19 +1744,13 @@ func (_ *isaD) fmuld(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fdivd(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fdiv.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat64(rs1)
-	b := c.GetRegisterFloatAsFloat64(rs2)
-	c.ClrFloatFlag()
-	if b == 0 {
-		c.SetRegisterFloat(rd, NaN64)
-		c.SetFloatFlag(FFlagsDZ, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	c.SetRegisterFloatAsFloat64(rd, a/b)
-	if big.NewFloat(0).Quo(big.NewFloat(a), big.NewFloat(b)).Acc() != big.Exact {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat64.Div(c.getFloat(SoftFloat64, rs1), c.getFloat(SoftFloat64, rs2), rm)
+	c.setFloat(SoftFloat64, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1880,19 +1758,13 @@ func (_ *isaD) fdivd(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fsqrtd(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fsqrt.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat64(rs1)
-	c.ClrFloatFlag()
-	if a < 0 {
-		c.SetRegisterFloat(rd, NaN64)
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	c.SetRegisterFloatAsFloat64(rd, math.Sqrt(a))
-	d := big.NewFloat(0).Sqrt(big.NewFloat(a))
-	if big.NewFloat(0).Mul(d, d).Acc() != big.Exact {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat64.Sqrt(c.getFloat(SoftFloat64, rs1), rm)
+	c.setFloat(SoftFloat64, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1942,35 +1814,9 @@ func (_ *isaD) fsgnjxd(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fmind(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fmin.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat64(rs1)
-	b := c.GetRegisterFloatAsFloat64(rs2)
-	c.ClrFloatFlag()
-	if math.IsNaN(a) && math.IsNaN(b) {
-		c.SetRegisterFloat(rd, NaN64)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if math.IsNaN(a) {
-		c.SetRegisterFloatAsFloat64(rd, b)
-		if IsSNaN64(a) {
-			c.SetFloatFlag(FFlagsNV, 1)
-		}
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if math.IsNaN(b) {
-		c.SetRegisterFloatAsFloat64(rd, a)
-		if IsSNaN64(b) {
-			c.SetFloatFlag(FFlagsNV, 1)
-		}
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if (math.Signbit(a) && !math.Signbit(b)) || a < b {
-		c.SetRegisterFloatAsFloat64(rd, a)
-	} else {
-		c.SetRegisterFloatAsFloat64(rd, b)
-	}
+	r, flags := SoftFloat64.Min(c.getFloat(SoftFloat64, rs1), c.getFloat(SoftFloat64, rs2))
+	c.setFloat(SoftFloat64, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -1978,35 +1824,9 @@ func (_ *isaD) fmind(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fmaxd(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fmax.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat64(rs1)
-	b := c.GetRegisterFloatAsFloat64(rs2)
-	c.ClrFloatFlag()
-	if math.IsNaN(a) && math.IsNaN(b) {
-		c.SetRegisterFloat(rd, NaN64)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if math.IsNaN(a) {
-		c.SetRegisterFloatAsFloat64(rd, b)
-		if IsSNaN64(a) {
-			c.SetFloatFlag(FFlagsNV, 1)
-		}
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if math.IsNaN(b) {
-		c.SetRegisterFloatAsFloat64(rd, a)
-		if IsSNaN64(b) {
-			c.SetFloatFlag(FFlagsNV, 1)
-		}
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if (!math.Signbit(a) && math.Signbit(b)) || a > b {
-		c.SetRegisterFloatAsFloat64(rd, a)
-	} else {
-		c.SetRegisterFloatAsFloat64(rd, b)
-	}
+	r, flags := SoftFloat64.Max(c.getFloat(SoftFloat64, rs1), c.getFloat(SoftFloat64, rs2))
+	c.setFloat(SoftFloat64, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -2014,12 +1834,13 @@ func (_ *isaD) fmaxd(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fcvtsd(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.s.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	d := c.GetRegisterFloatAsFloat64(rs1)
-	if math.IsNaN(d) {
-		c.SetRegisterFloat(rd, 0xffffffff00000000|uint64(NaN32))
-	} else {
-		c.SetRegisterFloatAsFloat32(rd, float32(d))
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat64.Convert(c.getFloat(SoftFloat64, rs1), SoftFloat32, rm)
+	c.setFloat(SoftFloat32, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -2027,12 +1848,13 @@ func (_ *isaD) fcvtsd(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fcvtds(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.d.s", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	d := c.GetRegisterFloatAsFloat32(rs1)
-	if math.IsNaN(float64(d)) {
-		c.SetRegisterFloat(rd, NaN64)
-	} else {
-		c.SetRegisterFloatAsFloat64(rd, float64(d))
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat32.Convert(c.getFloat(SoftFloat32, rs1), SoftFloat64, rm)
+	c.setFloat(SoftFloat64, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -2040,19 +1862,13 @@ func (_ *isaD) fcvtds(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) feqd(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "feq.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat64(rs1)
-	b := c.GetRegisterFloatAsFloat64(rs2)
-	var cond bool
-	if IsSNaN64(a) || IsSNaN64(b) {
-		c.SetFloatFlag(FFlagsNV, 1)
-	} else {
-		cond = a == b
-	}
+	cond, flags := SoftFloat64.Eq(c.getFloat(SoftFloat64, rs1), c.getFloat(SoftFloat64, rs2))
 	if cond {
 		c.SetRegister(rd, 1)
 	} else {
 		c.SetRegister(rd, 0)
 	}
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -2060,19 +1876,13 @@ func (_ *isaD) feqd(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fltd(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "flt.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat64(rs1)
-	b := c.GetRegisterFloatAsFloat64(rs2)
-	var cond bool
-	if math.IsNaN(a) || math.IsNaN(b) {
-		c.SetFloatFlag(FFlagsNV, 1)
-	} else {
-		cond = a < b
-	}
+	cond, flags := SoftFloat64.Lt(c.getFloat(SoftFloat64, rs1), c.getFloat(SoftFloat64, rs2))
 	if cond {
 		c.SetRegister(rd, 1)
 	} else {
 		c.SetRegister(rd, 0)
 	}
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -2080,19 +1890,13 @@ func (_ *isaD) fltd(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fled(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fle.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	a := c.GetRegisterFloatAsFloat64(rs1)
-	b := c.GetRegisterFloatAsFloat64(rs2)
-	var cond bool
-	if math.IsNaN(a) || math.IsNaN(b) {
-		c.SetFloatFlag(FFlagsNV, 1)
-	} else {
-		cond = a <= b
-	}
+	cond, flags := SoftFloat64.Le(c.getFloat(SoftFloat64, rs1), c.getFloat(SoftFloat64, rs2))
 	if cond {
 		c.SetRegister(rd, 1)
 	} else {
 		c.SetRegister(rd, 0)
 	}
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -2109,28 +1913,13 @@ func (_ *isaD) fclassd(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fcvtwd(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.w.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	d := c.GetRegisterFloatAsFloat64(rs1)
-	if math.IsNaN(d) {
-		c.SetRegister(rd, 0x7fffffff)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if d > float64(math.MaxInt32) {
-		c.SetRegister(rd, SignExtend(0x7fffffff, 31))
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if d < float64(math.MinInt32) {
-		c.SetRegister(rd, SignExtend(0x80000000, 31))
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	c.SetRegister(rd, SignExtend(uint64(int32(d)), 31))
-	if math.Ceil(d) != d {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat64.ToInt(c.getFloat(SoftFloat64, rs1), 32, true, rm)
+	c.SetRegister(rd, SignExtend(r, 31))
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -2138,28 +1927,13 @@ func (_ *isaD) fcvtwd(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fcvtwud(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.wu.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	d := c.GetRegisterFloatAsFloat64(rs1)
-	if math.IsNaN(d) {
-		c.SetRegister(rd, 0xffffffffffffffff)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if d > float64(math.MaxUint32) {
-		c.SetRegister(rd, SignExtend(0xffffffff, 31))
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if d <= float64(-1) {
-		c.SetRegister(rd, SignExtend(0x00000000, 31))
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	c.SetRegister(rd, SignExtend(uint64(uint32(d)), 31))
-	if math.Ceil(d) != d {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat64.ToInt(c.getFloat(SoftFloat64, rs1), 32, false, rm)
+	c.SetRegister(rd, SignExtend(r, 31))
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -2167,7 +1941,13 @@ func (_ *isaD) fcvtwud(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fcvtdw(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.d.w", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	c.SetRegisterFloatAsFloat64(rd, float64(int32(c.GetRegister(rs1))))
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
+	}
+	r, flags := SoftFloat64.FromInt(SignExtend(c.GetRegister(rs1), 31), true, rm)
+	c.setFloat(SoftFloat64, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -2175,7 +1955,13 @@ func (_ *isaD) fcvtdw(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fcvtdwu(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.d.wu", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	c.SetRegisterFloatAsFloat64(rd, float64(uint32(c.GetRegister(rs1))))
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
+	}
+	r, flags := SoftFloat64.FromInt(c.GetRegister(rs1)&0xffffffff, false, rm)
+	c.setFloat(SoftFloat64, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -2183,28 +1969,13 @@ func (_ *isaD) fcvtdwu(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fcvtld(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.l.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	d := c.GetRegisterFloatAsFloat64(rs1)
-	if math.IsNaN(d) {
-		c.SetRegister(rd, 0x7fffffffffffffff)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if d > float64(math.MaxInt64) {
-		c.SetRegister(rd, 0x7fffffffffffffff)
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if d < float64(math.MinInt64) {
-		c.SetRegister(rd, 0x8000000000000000)
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	c.SetRegister(rd, uint64(int64(d)))
-	if math.Ceil(d) != d {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat64.ToInt(c.getFloat(SoftFloat64, rs1), 64, true, rm)
+	c.SetRegister(rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -2212,28 +1983,13 @@ func (_ *isaD) fcvtld(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fcvtlud(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.lu.d", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	d := c.GetRegisterFloatAsFloat64(rs1)
-	if math.IsNaN(d) {
-		c.SetRegister(rd, 0xffffffffffffffff)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if d > float64(math.MaxUint64) {
-		c.SetRegister(rd, 0xffffffffffffffff)
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	if d <= float64(-1) {
-		c.SetRegister(rd, 0x0000000000000000)
-		c.SetFloatFlag(FFlagsNV, 1)
-		c.SetPC(c.GetPC() + 4)
-		return 1, nil
-	}
-	c.SetRegister(rd, uint64(d))
-	if math.Ceil(d) != d {
-		c.SetFloatFlag(FFlagsNX, 1)
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
 	}
+	r, flags := SoftFloat64.ToInt(c.getFloat(SoftFloat64, rs1), 64, false, rm)
+	c.SetRegister(rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -2249,7 +2005,13 @@ func (_ *isaD) fmvxd(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fcvtdl(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.d.l", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	c.SetRegisterFloatAsFloat64(rd, float64(int64(c.GetRegister(rs1))))
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
+	}
+	r, flags := SoftFloat64.FromInt(c.GetRegister(rs1), true, rm)
+	c.setFloat(SoftFloat64, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -2257,7 +2019,13 @@ func (_ *isaD) fcvtdl(c *CPU, i uint64) (uint64, error) {
 func (_ *isaD) fcvtdlu(c *CPU, i uint64) (uint64, error) {
 	rd, rs1, rs2 := RType(i)
 	Debugln(fmt.Sprintf("%#08x % 10s  rd: %s rs1: %s rs2: %s", c.GetPC(), "fcvt.d.lu", c.LogF(rd), c.LogF(rs1), c.LogF(rs2)))
-	c.SetRegisterFloatAsFloat64(rd, float64(uint64(c.GetRegister(rs1))))
+	rm, err := c.rounding(i)
+	if err != nil {
+		return 0, err
+	}
+	r, flags := SoftFloat64.FromInt(c.GetRegister(rs1), false, rm)
+	c.setFloat(SoftFloat64, rd, r)
+	c.SetFloatFlag(flags, 1)
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
@@ -2713,7 +2481,7 @@ func (_ *isaC) mv(c *CPU, i uint64) (uint64, error) {
 
 func (_ *isaC) ebreak(c *CPU, i uint64) (uint64, error) {
 	Debugln(fmt.Sprintf("%#08x % 10s", c.GetPC(), "c.ebreak"))
-	return 1, nil
+	return 0, ErrBreakpoint
 }
 
 func (_ *isaC) jalr(c *CPU, i uint64) (uint64, error) {
@@ -2787,38 +2555,84 @@ func (_ *isaC) sdsp(c *CPU, i uint64) (uint64, error) {
 
 type isaPrivileged struct{}
 
+// The N extension is not supported, there are no user-level traps.
 func (_ *isaPrivileged) uret(c *CPU, _ uint64) (uint64, error) {
 	Debugln(fmt.Sprintf("%#08x % 10s", c.GetPC(), "uret"))
-	c.SetPC(c.GetPC() + 4)
-	return 1, nil
+	return 0, ErrAbnormalInstruction
 }
 
 func (_ *isaPrivileged) sret(c *CPU, _ uint64) (uint64, error) {
 	Debugln(fmt.Sprintf("%#08x % 10s", c.GetPC(), "sret"))
-	c.SetPC(c.GetPC() + 4)
+	s := c.GetCSR().Get(CSRmstatus)
+	if c.GetPrivilege() < PrivilegeS || c.GetPrivilege() == PrivilegeS && s&MStatusTSR != 0 {
+		return 0, ErrAbnormalInstruction
+	}
+	c.SetPrivilege(s & MStatusSPP >> 8)
+	s &^= MStatusSIE | MStatusSPP | MStatusMPRV
+	if s&MStatusSPIE != 0 {
+		s |= MStatusSIE
+	}
+	c.GetCSR().Set(CSRmstatus, s|MStatusSPIE)
+	c.SetPC(c.GetCSR().Get(CSRsepc))
 	return 1, nil
 }
 
+// The hret instruction was removed from the privileged architecture.
 func (_ *isaPrivileged) hret(c *CPU, _ uint64) (uint64, error) {
 	Debugln(fmt.Sprintf("%#08x % 10s", c.GetPC(), "hret"))
-	c.SetPC(c.GetPC() + 4)
-	return 1, nil
+	return 0, ErrAbnormalInstruction
 }
 
 func (_ *isaPrivileged) mret(c *CPU, _ uint64) (uint64, error) {
 	Debugln(fmt.Sprintf("%#08x % 10s", c.GetPC(), "mret"))
-	c.SetPC(c.GetPC() + 4)
+	if c.GetPrivilege() < PrivilegeM {
+		return 0, ErrAbnormalInstruction
+	}
+	s := c.GetCSR().Get(CSRmstatus)
+	mpp := s & MStatusMPP >> 11
+	c.SetPrivilege(mpp)
+	s &^= MStatusMIE | MStatusMPP
+	if s&MStatusMPIE != 0 {
+		s |= MStatusMIE
+	}
+	if mpp != PrivilegeM {
+		s &^= MStatusMPRV
+	}
+	c.GetCSR().Set(CSRmstatus, s|MStatusMPIE)
+	c.SetPC(c.GetCSR().Get(CSRmepc))
 	return 1, nil
 }
 
 func (_ *isaPrivileged) wfi(c *CPU, _ uint64) (uint64, error) {
 	Debugln(fmt.Sprintf("%#08x % 10s", c.GetPC(), "wfi"))
+	if c.GetPrivilege() == PrivilegeU || c.GetPrivilege() == PrivilegeS && c.GetCSR().Get(CSRmstatus)&MStatusTW != 0 {
+		return 0, ErrAbnormalInstruction
+	}
+	// The hart sleeps before the next instruction, see CPU.Run.
+	c.wait = true
+	c.SetPC(c.GetPC() + 4)
+	return 1, nil
+}
+
+func (_ *isaPrivileged) sfencevma(c *CPU, i uint64) (uint64, error) {
+	_, rs1, rs2 := RType(i)
+	Debugln(fmt.Sprintf("%#08x % 10s rs1: %s rs2: %s", c.GetPC(), "sfence.vma", c.LogI(rs1), c.LogI(rs2)))
+	if c.GetPrivilege() == PrivilegeU || c.GetPrivilege() == PrivilegeS && c.GetCSR().Get(CSRmstatus)&MStatusTVM != 0 {
+		return 0, ErrAbnormalInstruction
+	}
+	// Address spaces are not told apart, the translations of all of them are flushed.
+	if c.mmu != nil {
+		c.mmu.flush(c.GetRegister(rs1), rs1 == Rzero)
+	}
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
 
 func (_ *isaPrivileged) sfencevm(c *CPU, _ uint64) (uint64, error) {
 	Debugln(fmt.Sprintf("%#08x % 10s", c.GetPC(), "sfencevm"))
+	if c.GetPrivilege() == PrivilegeU || c.GetPrivilege() == PrivilegeS && c.GetCSR().Get(CSRmstatus)&MStatusTVM != 0 {
+		return 0, ErrAbnormalInstruction
+	}
 	c.SetPC(c.GetPC() + 4)
 	return 1, nil
 }
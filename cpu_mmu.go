@@ -0,0 +1,347 @@
+package rv64
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Modes of the satp register.
+const (
+	SATPModeBare uint64 = 0
+	SATPModeSv39 uint64 = 8
+	SATPModeSv48 uint64 = 9
+)
+
+// Bits of a page table entry.
+const (
+	PTEV uint64 = 1 << 0 // Valid
+	PTER uint64 = 1 << 1 // Readable
+	PTEW uint64 = 1 << 2 // Writable
+	PTEX uint64 = 1 << 3 // Executable
+	PTEU uint64 = 1 << 4 // Accessible to U-mode
+	PTEG uint64 = 1 << 5 // Global
+	PTEA uint64 = 1 << 6 // Accessed
+	PTED uint64 = 1 << 7 // Dirty
+)
+
+// mmuTLBSize is the number of pages the TLB holds. It is emptied when it is full.
+const mmuTLBSize = 4096
+
+// mmuEntry is a 4 KiB page in the TLB. Superpages are cached page by page, mask is the offset mask of the leaf page
+// the entry belongs to.
+type mmuEntry struct {
+	pa   uint64
+	pte  uint64
+	mask uint64
+}
+
+// mmu is the memory of a hart as seen through address translation. Accesses are translated in the effective privilege
+// level, see CPU.privilege. There is no translation in M-mode.
+//
+// Translations are cached in a TLB, which is flushed by SFENCE.VMA and when satp changes. The page table entries are
+// cached with their permission bits, the permissions are checked on every access.
+type mmu struct {
+	cpu   *CPU
+	satp  uint64
+	tlb   map[uint64]mmuEntry
+	super bool // The TLB holds pieces of a superpage
+}
+
+// flush drops the translations of the page holding va from the TLB, or all of them if all is set. The page may be a
+// superpage, then all its pieces are dropped.
+func (m *mmu) flush(va uint64, all bool) {
+	if all {
+		m.tlb = map[uint64]mmuEntry{}
+		m.super = false
+		return
+	}
+	delete(m.tlb, va>>12)
+	if !m.super {
+		return
+	}
+	for k, e := range m.tlb {
+		if k<<12&^e.mask == va&^e.mask {
+			delete(m.tlb, k)
+		}
+	}
+}
+
+// fault returns the fault of an access with permission perm at a, a page fault if page is set or an access fault.
+func fault(a uint64, perm uint8, page bool) error {
+	var err error
+	switch {
+	case perm == PermX && page:
+		err = ErrInstructionPageFault
+	case perm == PermX:
+		err = ErrInstructionAccessFault
+	case perm == PermW && page:
+		err = ErrStorePageFault
+	case perm == PermW:
+		err = ErrStoreAccessFault
+	case page:
+		err = ErrLoadPageFault
+	default:
+		err = ErrLoadAccessFault
+	}
+	return &AccessFault{Err: err, Addr: a}
+}
+
+// allowed reports whether the leaf entry pte permits an access with permission perm in privilege p.
+func (m *mmu) allowed(pte uint64, perm uint8, p uint64) bool {
+	s := m.cpu.GetCSR().Get(CSRmstatus)
+	switch {
+	case p == PrivilegeU && pte&PTEU == 0:
+		return false
+	case p == PrivilegeS && pte&PTEU != 0 && (perm == PermX || s&MStatusSUM == 0):
+		return false
+	}
+	switch perm {
+	case PermX:
+		return pte&PTEX != 0
+	case PermW:
+		return pte&PTEW != 0
+	}
+	return pte&PTER != 0 || s&MStatusMXR != 0 && pte&PTEX != 0
+}
+
+// translate returns the physical address of va for an access with permission perm.
+func (m *mmu) translate(va uint64, perm uint8) (uint64, error) {
+	satp := m.cpu.GetCSR().Get(CSRsatp)
+	p := m.cpu.privilege(perm)
+	if satp>>60 == SATPModeBare || p == PrivilegeM {
+		return va, nil
+	}
+	if satp != m.satp {
+		m.satp = satp
+		m.flush(0, true)
+	}
+	e, ok := m.tlb[va>>12]
+	// A store to a clean page walks the table again to set the dirty bit.
+	if !ok || perm == PermW && e.pte&PTED == 0 {
+		var err error
+		if e, err = m.walk(va, perm, satp); err != nil {
+			return 0, err
+		}
+		if len(m.tlb) >= mmuTLBSize {
+			m.flush(0, true)
+		}
+		m.tlb[va>>12] = e
+		m.super = m.super || e.mask != 0xfff
+	}
+	if !m.allowed(e.pte, perm, p) {
+		return 0, fault(va, perm, true)
+	}
+	return e.pa | va&0xfff, nil
+}
+
+// walk finds the leaf entry of va in the page table of satp. It sets the accessed bit of the entry, and the dirty bit
+// for a store, if the access is permitted.
+func (m *mmu) walk(va uint64, perm uint8, satp uint64) (mmuEntry, error) {
+	levels := 3
+	if satp>>60 == SATPModeSv48 {
+		levels = 4
+	}
+	// The bits above the virtual address must all be copies of its highest bit.
+	bits := uint(12 + 9*levels)
+	if hi := int64(va) >> (bits - 1); hi != 0 && hi != -1 {
+		return mmuEntry{}, fault(va, perm, true)
+	}
+	mem := &Memory{Fasten: m.cpu.physical(true)}
+	a := (satp & (1<<44 - 1)) << 12
+	for i := levels - 1; i >= 0; i-- {
+		addr := a + (va>>(12+9*uint(i))&0x1ff)*8
+		pte, err := mem.GetUint64(addr)
+		if err != nil {
+			return mmuEntry{}, fault(va, perm, false)
+		}
+		if pte&PTEV == 0 || pte&(PTER|PTEW) == PTEW {
+			return mmuEntry{}, fault(va, perm, true)
+		}
+		ppn := pte >> 10 & (1<<44 - 1)
+		if pte&(PTER|PTEX) == 0 {
+			// The A, D and U bits are reserved in a pointer to the next level.
+			if pte&(PTEA|PTED|PTEU) != 0 {
+				return mmuEntry{}, fault(va, perm, true)
+			}
+			a = ppn << 12
+			continue
+		}
+		// A superpage must be aligned to its size.
+		mask := uint64(1)<<(12+9*uint(i)) - 1
+		if ppn<<12&mask != 0 {
+			return mmuEntry{}, fault(va, perm, true)
+		}
+		if !m.allowed(pte, perm, m.cpu.privilege(perm)) {
+			return mmuEntry{}, fault(va, perm, true)
+		}
+		n := pte | PTEA
+		if perm == PermW {
+			n |= PTED
+		}
+		if n != pte {
+			if err := mem.SetUint64(addr, n); err != nil {
+				return mmuEntry{}, fault(va, perm, false)
+			}
+		}
+		return mmuEntry{pa: (ppn<<12 | va&mask) &^ 0xfff, pte: n, mask: mask}, nil
+	}
+	return mmuEntry{}, fault(va, perm, true)
+}
+
+// physical returns the error of an access to physical memory with the address of the virtual one.
+func (m *mmu) physical(err error, va uint64, pa uint64) error {
+	var f *AccessFault
+	if errors.As(err, &f) {
+		return &AccessFault{Err: f.Err, Addr: f.Addr - pa + va}
+	}
+	return err
+}
+
+func (m *mmu) Get(a uint64) (byte, error) {
+	pa, err := m.translate(a, PermR)
+	if err != nil {
+		return 0, err
+	}
+	v, err := m.cpu.physical(false).Get(pa)
+	return v, m.physical(err, a, pa)
+}
+
+func (m *mmu) Set(a uint64, v byte) error {
+	pa, err := m.translate(a, PermW)
+	if err != nil {
+		return err
+	}
+	return m.physical(m.cpu.physical(false).Set(pa, v), a, pa)
+}
+
+func (m *mmu) Len() uint64 {
+	return ^uint64(0)
+}
+
+// pages calls f for each part of [a, a+len(b)) inside a page, with its physical address.
+func (m *mmu) pages(a uint64, b []byte, perm uint8, f func(pa uint64, b []byte) error) error {
+	for len(b) != 0 {
+		n := 0x1000 - a&0xfff
+		if n > uint64(len(b)) {
+			n = uint64(len(b))
+		}
+		pa, err := m.translate(a, perm)
+		if err != nil {
+			return err
+		}
+		if err := f(pa, b[:n]); err != nil {
+			return m.physical(err, a, pa)
+		}
+		a += n
+		b = b[n:]
+	}
+	return nil
+}
+
+func (m *mmu) GetBytes(a uint64, b []byte) error {
+	mem := &Memory{Fasten: m.cpu.physical(false)}
+	return m.pages(a, b, PermR, mem.get)
+}
+
+func (m *mmu) SetBytes(a uint64, b []byte) error {
+	mem := &Memory{Fasten: m.cpu.physical(false)}
+	return m.pages(a, b, PermW, mem.SetByte)
+}
+
+// Fetch reads the bytes of an instruction.
+func (m *mmu) Fetch(a uint64, b []byte) error {
+	phys := m.cpu.physical(false)
+	fetch := (&Memory{Fasten: phys}).get
+	if f, ok := phys.(fastenFetch); ok {
+		fetch = f.Fetch
+	}
+	return m.pages(a, b, PermX, fetch)
+}
+
+// word translates an access of n bytes at a that does not cross a page. It returns ok false if it does, then the
+// access is done byte by byte.
+func (m *mmu) word(a uint64, n uint64, perm uint8) (uint64, bool, error) {
+	if a&0xfff > 0x1000-n {
+		return 0, false, nil
+	}
+	pa, err := m.translate(a, perm)
+	return pa, true, err
+}
+
+func (m *mmu) GetUint16(a uint64) (uint16, error) {
+	pa, ok, err := m.word(a, 2, PermR)
+	if err != nil || !ok {
+		b := make([]byte, 2)
+		if err == nil {
+			err = m.GetBytes(a, b)
+		}
+		return binary.LittleEndian.Uint16(b), err
+	}
+	v, err := (&Memory{Fasten: m.cpu.physical(false)}).GetUint16(pa)
+	return v, m.physical(err, a, pa)
+}
+
+func (m *mmu) GetUint32(a uint64) (uint32, error) {
+	pa, ok, err := m.word(a, 4, PermR)
+	if err != nil || !ok {
+		b := make([]byte, 4)
+		if err == nil {
+			err = m.GetBytes(a, b)
+		}
+		return binary.LittleEndian.Uint32(b), err
+	}
+	v, err := (&Memory{Fasten: m.cpu.physical(false)}).GetUint32(pa)
+	return v, m.physical(err, a, pa)
+}
+
+func (m *mmu) GetUint64(a uint64) (uint64, error) {
+	pa, ok, err := m.word(a, 8, PermR)
+	if err != nil || !ok {
+		b := make([]byte, 8)
+		if err == nil {
+			err = m.GetBytes(a, b)
+		}
+		return binary.LittleEndian.Uint64(b), err
+	}
+	v, err := (&Memory{Fasten: m.cpu.physical(false)}).GetUint64(pa)
+	return v, m.physical(err, a, pa)
+}
+
+func (m *mmu) SetUint16(a uint64, n uint16) error {
+	pa, ok, err := m.word(a, 2, PermW)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		b := make([]byte, 2)
+		binary.LittleEndian.PutUint16(b, n)
+		return m.SetBytes(a, b)
+	}
+	return m.physical((&Memory{Fasten: m.cpu.physical(false)}).SetUint16(pa, n), a, pa)
+}
+
+func (m *mmu) SetUint32(a uint64, n uint32) error {
+	pa, ok, err := m.word(a, 4, PermW)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, n)
+		return m.SetBytes(a, b)
+	}
+	return m.physical((&Memory{Fasten: m.cpu.physical(false)}).SetUint32(pa, n), a, pa)
+}
+
+func (m *mmu) SetUint64(a uint64, n uint64) error {
+	pa, ok, err := m.word(a, 8, PermW)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, n)
+		return m.SetBytes(a, b)
+	}
+	return m.physical((&Memory{Fasten: m.cpu.physical(false)}).SetUint64(pa, n), a, pa)
+}
@@ -0,0 +1,192 @@
+package rv64
+
+import (
+	"errors"
+	"testing"
+)
+
+// testMMU returns a hart in S-mode with the root page table of mode at 0x4000, and its 64 KiB of physical memory.
+func testMMU(mode uint64) (*CPU, *Memory) {
+	c := NewCPU()
+	f := NewLinear(0x10000)
+	c.SetFasten(f)
+	c.SetCSR(NewCSRStandard())
+	c.GetCSR().Set(CSRsatp, mode<<60|0x4)
+	c.SetPrivilege(PrivilegeS)
+	return c, &Memory{Fasten: f}
+}
+
+// testMMUFault fails unless err is the fault want at a.
+func testMMUFault(t *testing.T, err error, want error, a uint64) {
+	t.Helper()
+	var f *AccessFault
+	if !errors.As(err, &f) || f.Err != want || f.Addr != a {
+		t.Fatal(err)
+	}
+}
+
+// testMMUStep executes the instruction at pc.
+func testMMUStep(t *testing.T, c *CPU, pc uint64) {
+	t.Helper()
+	c.SetPC(pc)
+	data, err := c.PipelineInstructionFetch()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.PipelineExecute(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCPUMMU(t *testing.T) {
+	c, mem := testMMU(SATPModeSv39)
+	// Sv39 tables at 0x4000, 0x5000 and 0x6000 map the pages 0x200000 and 0x201000 to 0x8000 and 0x9000, and the
+	// code page 0x202000 to 0xa000.
+	mem.SetUint64(0x4000, 0x5000>>2|PTEV)
+	mem.SetUint64(0x5008, 0x6000>>2|PTEV)
+	mem.SetUint64(0x6000, 0x8000>>2|PTER|PTEW|PTEV)
+	mem.SetUint64(0x6008, 0x9000>>2|PTEU|PTER|PTEW|PTEV)
+	mem.SetUint64(0x6010, 0xa000>>2|PTEX|PTEV)
+	mem.SetUint32(0xa000, 0x12050073) // sfence.vma a0
+
+	if err := c.GetMemory().SetUint64(0x200008, 42); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := mem.GetUint64(0x8008); v != 42 {
+		t.Fatal(v)
+	}
+	if v, _ := mem.GetUint64(0x6000); v&(PTEA|PTED) != PTEA|PTED {
+		t.Fatalf("%#x", v)
+	}
+
+	// A user page is not accessible to S-mode unless mstatus.SUM is set.
+	_, err := c.GetMemory().GetUint64(0x201000)
+	testMMUFault(t, err, ErrLoadPageFault, 0x201000)
+	c.GetCSR().Set(CSRmtvec, 0x1000)
+	if c.exception(err, nil) != nil || c.GetCSR().Get(CSRmcause) != ExceptionLoadPageFault || c.GetCSR().Get(CSRmtval) != 0x201000 {
+		t.Fatal(c.GetCSR().Get(CSRmcause))
+	}
+	c.SetPrivilege(PrivilegeS)
+	c.GetCSR().Set(CSRmstatus, MStatusSUM)
+	if _, err := c.GetMemory().GetUint64(0x201000); err != nil {
+		t.Fatal(err)
+	}
+
+	// Stale translations are used until they are flushed.
+	mem.SetUint64(0x6000, 0x9000>>2|PTEA|PTED|PTER|PTEW|PTEV)
+	if v, _ := c.GetMemory().GetUint64(0x200008); v != 42 {
+		t.Fatal(v)
+	}
+	c.SetRegister(Ra0, 0x201000)
+	testMMUStep(t, c, 0x202000)
+	if v, _ := c.GetMemory().GetUint64(0x200008); v != 42 {
+		t.Fatal(v)
+	}
+	c.SetRegister(Ra0, 0x200000)
+	testMMUStep(t, c, 0x202000)
+	if v, _ := c.GetMemory().GetUint64(0x200008); v != 0 {
+		t.Fatal(v)
+	}
+}
+
+func TestCPUMMUSv48(t *testing.T) {
+	c, mem := testMMU(SATPModeSv48)
+	// Sv48 tables at 0x4000, 0x5000, 0x6000 and 0x7000 map the page 0x200000 to 0x8000. The second entry of the root
+	// table maps the same tables at 0x8000000000, above the addresses of Sv39.
+	mem.SetUint64(0x4000, 0x5000>>2|PTEV)
+	mem.SetUint64(0x4008, 0x5000>>2|PTEV)
+	mem.SetUint64(0x5000, 0x6000>>2|PTEV)
+	mem.SetUint64(0x6008, 0x7000>>2|PTEV)
+	mem.SetUint64(0x7000, 0x8000>>2|PTER|PTEW|PTEV)
+	mem.SetUint64(0x8008, 42)
+
+	// The A, D and U bits of an entry which is not a leaf are reserved.
+	for _, b := range []uint64{PTEA, PTED, PTEU} {
+		mem.SetUint64(0x6008, 0x7000>>2|b|PTEV)
+		_, err := c.GetMemory().GetUint64(0x200008)
+		testMMUFault(t, err, ErrLoadPageFault, 0x200008)
+	}
+	mem.SetUint64(0x6008, 0x7000>>2|PTEV)
+
+	for _, a := range []uint64{0x200008, 0x8000200008} {
+		if v, err := c.GetMemory().GetUint64(a); err != nil || v != 42 {
+			t.Fatal(a, v, err)
+		}
+	}
+	// Bit 47 is the highest bit of an Sv48 address, the bits above it must be copies of it.
+	_, err := c.GetMemory().GetUint64(0x800000200008)
+	testMMUFault(t, err, ErrLoadPageFault, 0x800000200008)
+	// An Sv39 address is 39 bits wide.
+	c.GetCSR().Set(CSRsatp, SATPModeSv39<<60|0x4)
+	_, err = c.GetMemory().GetUint64(0x8000200008)
+	testMMUFault(t, err, ErrLoadPageFault, 0x8000200008)
+}
+
+func TestCPUMMUSuperpage(t *testing.T) {
+	c, mem := testMMU(SATPModeSv39)
+	// A 2 MiB page at 0x200000 maps to 0. The one at 0x400000 maps to 0x8000, which is not aligned to its size.
+	mem.SetUint64(0x4000, 0x5000>>2|PTEV)
+	mem.SetUint64(0x5008, PTEX|PTER|PTEW|PTEV)
+	mem.SetUint64(0x5010, 0x8000>>2|PTER|PTEW|PTEV)
+	mem.SetUint32(0x1000, 0x12050073) // sfence.vma a0
+
+	if err := c.GetMemory().SetUint64(0x209008, 42); err != nil {
+		t.Fatal(err)
+	}
+	if v, _ := mem.GetUint64(0x9008); v != 42 {
+		t.Fatal(v)
+	}
+	if v, _ := mem.GetUint64(0x5008); v&(PTEA|PTED) != PTEA|PTED {
+		t.Fatalf("%#x", v)
+	}
+	_, err := c.GetMemory().GetUint64(0x400008)
+	testMMUFault(t, err, ErrLoadPageFault, 0x400008)
+	testMMUFault(t, c.GetMemory().SetUint64(0x400008, 42), ErrStorePageFault, 0x400008)
+
+	// Flushing any address of a superpage flushes all of it.
+	mem.SetUint64(0x5008, PTEA|PTED|PTEX|PTER|PTEV)
+	if err := c.GetMemory().SetUint64(0x209008, 43); err != nil {
+		t.Fatal(err)
+	}
+	c.SetRegister(Ra0, 0x200000)
+	testMMUStep(t, c, 0x201000)
+	testMMUFault(t, c.GetMemory().SetUint64(0x209008, 44), ErrStorePageFault, 0x209008)
+}
+
+func TestCPUMMUPermission(t *testing.T) {
+	c, mem := testMMU(SATPModeSv39)
+	// The page 0x200000 is execute only, 0x201000 is not executable and 0x202000 belongs to U-mode.
+	mem.SetUint64(0x4000, 0x5000>>2|PTEV)
+	mem.SetUint64(0x5008, 0x6000>>2|PTEV)
+	mem.SetUint64(0x6000, 0x8000>>2|PTEX|PTEV)
+	mem.SetUint64(0x6008, 0x9000>>2|PTER|PTEW|PTEV)
+	mem.SetUint64(0x6010, 0xa000>>2|PTEU|PTER|PTEX|PTEV)
+	mem.SetUint32(0x8000, 0x00000013) // nop
+	mem.SetUint32(0x9000, 0x00000013)
+	mem.SetUint32(0xa000, 0x00000013)
+
+	// An execute only page is readable when mstatus.MXR is set.
+	testMMUStep(t, c, 0x200000)
+	_, err := c.GetMemory().GetUint32(0x200000)
+	testMMUFault(t, err, ErrLoadPageFault, 0x200000)
+	c.GetCSR().Set(CSRmstatus, MStatusMXR)
+	if v, err := c.GetMemory().GetUint32(0x200000); err != nil || v != 0x13 {
+		t.Fatal(v, err)
+	}
+	testMMUFault(t, c.GetMemory().SetUint32(0x200000, 0), ErrStorePageFault, 0x200000)
+
+	c.SetPC(0x201000)
+	_, err = c.PipelineInstructionFetch()
+	testMMUFault(t, err, ErrInstructionPageFault, 0x201000)
+
+	// S-mode never executes from a user page, even when mstatus.SUM is set.
+	c.GetCSR().Set(CSRmstatus, MStatusSUM)
+	if _, err := c.GetMemory().GetUint32(0x202000); err != nil {
+		t.Fatal(err)
+	}
+	c.SetPC(0x202000)
+	_, err = c.PipelineInstructionFetch()
+	testMMUFault(t, err, ErrInstructionPageFault, 0x202000)
+	c.SetPrivilege(PrivilegeU)
+	testMMUStep(t, c, 0x202000)
+}
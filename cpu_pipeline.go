@@ -16,23 +16,63 @@ package rv64
 // |     |     |     |     | IF  | ID  | EX  | MEM | WB  |
 
 func (c *CPU) PipelineInstructionFetch() ([]byte, error) {
-	a, err := c.GetMemory().GetByte(c.GetPC(), 2)
+	a, err := c.GetMemory().Fetch(c.GetPC(), 2)
 	if err != nil {
 		return nil, err
 	}
 	b := InstructionLengthEncoding(a)
-	r, err := c.GetMemory().GetByte(c.GetPC(), uint64(b))
+	if b == 2 {
+		return a, nil
+	}
+	r, err := c.GetMemory().Fetch(c.GetPC(), uint64(b))
 	if err != nil {
 		return nil, err
 	}
 	return r, nil
 }
 
+// pipelineFloat reports whether the instruction i of n bytes belongs to the F or D extension, and whether it is a
+// store, which leaves the floating-point state unchanged.
+func pipelineFloat(i uint64, n int) (bool, bool) {
+	if n == 2 {
+		switch InstructionPart(i, 0, 1)<<3 | InstructionPart(i, 13, 15) {
+		case 0b00_001, 0b10_001:
+			return true, false
+		case 0b00_101, 0b10_101:
+			return true, true
+		}
+		return false, false
+	}
+	switch InstructionPart(i, 0, 6) {
+	case 0b0000111, 0b1000011, 0b1000111, 0b1001011, 0b1001111, 0b1010011:
+		return true, false
+	case 0b0100111:
+		return true, true
+	}
+	return false, false
+}
+
+// floatDirty sets mstatus.FS to Dirty, the floating-point state has changed and must be saved by a context switch.
+func (c *CPU) floatDirty() {
+	if s := c.GetCSR().Get(CSRmstatus); s&MStatusFS != MStatusFS {
+		c.GetCSR().Set(CSRmstatus, s|MStatusFS)
+	}
+}
+
 func (c *CPU) PipelineExecute(data []byte) (uint64, error) {
 	var i uint64 = 0
 	for j := len(data) - 1; j >= 0; j-- {
 		i += uint64(data[j]) << (8 * j)
 	}
+	// The F and D extensions are unavailable while mstatus.FS is Off.
+	if ok, store := pipelineFloat(i, len(data)); ok {
+		if c.GetCSR().Get(CSRmstatus)&MStatusFS == 0 {
+			return 0, ErrAbnormalInstruction
+		}
+		if !store {
+			c.floatDirty()
+		}
+	}
 	switch len(data) {
 	case 2:
 		opcode := InstructionPart(i, 0, 1)
@@ -287,6 +327,9 @@ func (c *CPU) PipelineExecute(data []byte) (uint64, error) {
 		case 0b1110011:
 			switch funct3 {
 			case 0b000:
+				if funct7 == 0b0001001 && InstructionPart(i, 7, 11) == Rzero {
+					return aluPrivileged.sfencevma(c, i)
+				}
 				switch InstructionPart(i, 20, 31) {
 				case 0b000000000000:
 					return aluI.ecall(c, i)
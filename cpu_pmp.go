@@ -0,0 +1,179 @@
+package rv64
+
+import (
+	"math/bits"
+)
+
+// Fields of a pmpcfg entry, one byte of pmpcfg0 or pmpcfg2. PMPA selects how the entry matches addresses.
+const (
+	PMPR     uint64 = 1 << 0 // Readable
+	PMPW     uint64 = 1 << 1 // Writable
+	PMPX     uint64 = 1 << 2 // Executable
+	PMPA     uint64 = 3 << 3 // Address matching
+	PMPOff   uint64 = 0 << 3 // Matches nothing
+	PMPTOR   uint64 = 1 << 3 // Top of range, from the address of the previous entry up to its own
+	PMPNA4   uint64 = 2 << 3 // Naturally aligned 4 bytes
+	PMPNAPOT uint64 = 3 << 3 // Naturally aligned power of two, the size is encoded in the trailing ones of the address
+	PMPL     uint64 = 1 << 7 // Locked, also applies to M-mode
+)
+
+// pmpEntries is the number of PMP entries.
+const pmpEntries = 16
+
+// pmpEnabled has the address matching bits of the 8 entries of a pmpcfg register.
+const pmpEnabled uint64 = 0x1818181818181818
+
+// pmpConfig returns the configuration of the PMP entry n.
+func pmpConfig(csr CSR, n uint64) uint64 {
+	return csr.Get(CSRpmpcfg0+n/8*2) >> (n % 8 * 8) & 0xff
+}
+
+// pmp is the physical memory of a hart as seen through the physical memory protection. The entry with the lowest
+// number that matches any byte of an access decides: it fails if the entry does not match all bytes, or does not
+// grant the permission. The permissions apply to S-mode and U-mode, and to M-mode only if the entry is locked. An
+// access that matches no entry succeeds in M-mode and fails in the other modes.
+//
+// The checks start once an entry is enabled, a hart whose PMP was never configured has access to everything.
+type pmp struct {
+	cpu  *CPU
+	walk bool
+}
+
+// check returns an access fault if the access of n bytes at a with permission perm is not permitted.
+func (p *pmp) check(a uint64, n uint64, perm uint8) error {
+	priv := p.cpu.privilege(perm)
+	if p.walk {
+		priv = PrivilegeS
+	}
+	csr := p.cpu.GetCSR()
+	prev := uint64(0)
+	for i := uint64(0); i < pmpEntries; i++ {
+		cfg := pmpConfig(csr, i)
+		addr := csr.Get(CSRpmpaddr0 + i)
+		var base, top uint64
+		switch cfg & PMPA {
+		case PMPTOR:
+			base, top = prev<<2, addr<<2
+		case PMPNA4:
+			base, top = addr<<2, addr<<2+4
+		case PMPNAPOT:
+			t := uint64(bits.TrailingZeros64(^addr))
+			base = addr &^ (1<<t - 1) << 2
+			top = base + 1<<(t+3)
+		}
+		prev = addr
+		if base >= top || a >= top || a+n <= base {
+			continue
+		}
+		if a >= base && a+n <= top && (priv == PrivilegeM && cfg&PMPL == 0 || cfg&uint64(perm) != 0) {
+			return nil
+		}
+		return fault(a, perm, false)
+	}
+	if priv == PrivilegeM {
+		return nil
+	}
+	return fault(a, perm, false)
+}
+
+// mem returns the memory behind the checks.
+func (p *pmp) mem() *Memory {
+	return &Memory{Fasten: p.cpu.fasten}
+}
+
+func (p *pmp) Get(a uint64) (byte, error) {
+	if err := p.check(a, 1, PermR); err != nil {
+		return 0, err
+	}
+	return p.cpu.fasten.Get(a)
+}
+
+func (p *pmp) Set(a uint64, v byte) error {
+	if err := p.check(a, 1, PermW); err != nil {
+		return err
+	}
+	return p.cpu.fasten.Set(a, v)
+}
+
+func (p *pmp) Len() uint64 {
+	return p.cpu.fasten.Len()
+}
+
+// Fetch reads the bytes of an instruction.
+func (p *pmp) Fetch(a uint64, b []byte) error {
+	if err := p.check(a, uint64(len(b)), PermX); err != nil {
+		return err
+	}
+	if f, ok := p.cpu.fasten.(fastenFetch); ok {
+		return f.Fetch(a, b)
+	}
+	return p.mem().get(a, b)
+}
+
+func (p *pmp) GetBytes(a uint64, b []byte) error {
+	if err := p.check(a, uint64(len(b)), PermR); err != nil {
+		return err
+	}
+	return p.mem().get(a, b)
+}
+
+func (p *pmp) SetBytes(a uint64, b []byte) error {
+	if err := p.check(a, uint64(len(b)), PermW); err != nil {
+		return err
+	}
+	return p.mem().SetByte(a, b)
+}
+
+func (p *pmp) Protect(a uint64, size uint64, perm uint8) error {
+	return p.mem().Protect(a, size, perm)
+}
+
+func (p *pmp) Regions() ([]Region, bool) {
+	return p.mem().Regions()
+}
+
+func (p *pmp) Release(a uint64, size uint64) bool {
+	return p.mem().Release(a, size)
+}
+
+func (p *pmp) GetUint16(a uint64) (uint16, error) {
+	if err := p.check(a, 2, PermR); err != nil {
+		return 0, err
+	}
+	return p.mem().GetUint16(a)
+}
+
+func (p *pmp) GetUint32(a uint64) (uint32, error) {
+	if err := p.check(a, 4, PermR); err != nil {
+		return 0, err
+	}
+	return p.mem().GetUint32(a)
+}
+
+func (p *pmp) GetUint64(a uint64) (uint64, error) {
+	if err := p.check(a, 8, PermR); err != nil {
+		return 0, err
+	}
+	return p.mem().GetUint64(a)
+}
+
+func (p *pmp) SetUint16(a uint64, n uint16) error {
+	if err := p.check(a, 2, PermW); err != nil {
+		return err
+	}
+	return p.mem().SetUint16(a, n)
+}
+
+func (p *pmp) SetUint32(a uint64, n uint32) error {
+	if err := p.check(a, 4, PermW); err != nil {
+		return err
+	}
+	return p.mem().SetUint32(a, n)
+}
+
+func (p *pmp) SetUint64(a uint64, n uint64) error {
+	if err := p.check(a, 8, PermW); err != nil {
+		return err
+	}
+	return p.mem().SetUint64(a, n)
+}
@@ -0,0 +1,61 @@
+package rv64
+
+import (
+	"testing"
+)
+
+func TestCPUPMP(t *testing.T) {
+	c := testCPU(map[uint64]uint32{
+		0x1000: 0x0005b503, // ld a0, 0(a1)
+		0x2000: 0x34202573, // csrr a0, mcause
+		0x2004: 0x05d00893, // li a7, 93
+		0x2008: 0x00000073, // ecall
+	})
+	// [0, 0x2000) is readable and executable, [0x2000, 0x3000) readable and writable. The 4 bytes at 0x3000 are
+	// locked, not even M-mode can access them.
+	c.GetCSR().Set(CSRpmpaddr0, 0x3ff)
+	c.GetCSR().Set(CSRpmpaddr0+1, 0xc00)
+	c.GetCSR().Set(CSRpmpaddr0+2, 0xc00)
+	c.GetCSR().Set(CSRpmpcfg0, PMPNAPOT|PMPR|PMPX|(PMPTOR|PMPR|PMPW)<<8|(PMPNA4|PMPL)<<16)
+
+	c.SetPrivilege(PrivilegeU)
+	mem := c.GetMemory()
+	if _, err := mem.GetUint64(0x1000); err != nil {
+		t.Fatal(err)
+	}
+	if err := mem.SetUint64(0x2800, 1); err != nil {
+		t.Fatal(err)
+	}
+	for _, err := range []error{
+		mem.SetUint64(0x1000, 1),
+		mem.SetUint64(0x1ffc, 1), // Only partially inside the first entry
+		mem.SetUint64(0x3800, 1), // No entry
+	} {
+		if f, ok := err.(*AccessFault); !ok || f.Err != ErrStoreAccessFault {
+			t.Fatal(err)
+		}
+	}
+
+	c.SetPrivilege(PrivilegeM)
+	if _, err := c.GetMemory().GetUint64(0x3800); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetMemory().GetUint32(0x3000); err == nil {
+		t.Fatal(err)
+	}
+	// The locked entry can not be changed until reset.
+	c.GetCSR().Set(CSRpmpcfg0, 0)
+	c.GetCSR().Set(CSRpmpaddr0+2, 0)
+	if pmpConfig(c.GetCSR(), 2) != PMPNA4|PMPL || c.GetCSR().Get(CSRpmpaddr0+2) != 0xc00 {
+		t.Fatal(c.GetCSR().Get(CSRpmpcfg0))
+	}
+
+	c.GetCSR().Set(CSRpmpcfg0, PMPNAPOT|PMPR|PMPX)
+	c.GetCSR().Set(CSRmtvec, 0x2000)
+	c.SetPrivilege(PrivilegeU)
+	c.SetRegister(Ra1, 0x2800)
+	c.SetPC(0x1000)
+	if r := c.Run(); uint64(r) != ExceptionLoadAccessFault || c.GetCSR().Get(CSRmtval) != 0x2800 {
+		t.Fatal(r, c.GetCSR().Get(CSRmtval))
+	}
+}
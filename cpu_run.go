@@ -1,19 +1,18 @@
 package rv64
 
-import (
-	"log"
-)
-
 func (c *CPU) Run() uint8 {
 	for {
 		if c.GetStatus() == 1 {
-			Debugln("Exit:", c.GetSystem().Code())
-			return c.GetSystem().Code()
+			Debugln("Exit:", c.Code())
+			return c.Code()
 		}
-		data, err := c.PipelineInstructionFetch()
-		if err != nil {
-			Panicln(err)
+		if c.wait {
+			c.sleep()
+			c.wait = false
 		}
+		c.interrupt()
+		var n uint64
+		data, err := c.PipelineInstructionFetch()
 
 		// Debugln("----------------------------------------")
 		// var s uint64 = 0
@@ -29,13 +28,28 @@ func (c *CPU) Run() uint8 {
 		// 	Panicln("")
 		// }
 
-		n, err := c.PipelineExecute(data)
+		if err == nil {
+			n, err = c.PipelineExecute(data)
+		}
 		if err != nil {
-			log.Panicln(err)
+			if err := c.exception(err, data); err != nil {
+				Panicln(err)
+			}
+			// The instruction did not retire, but the trap took a cycle.
+			n = 1
+		} else {
+			c.GetCSR().Set(CSRinstret, c.GetCSR().Get(CSRinstret)+1)
 		}
 
 		c.GetCSR().Set(CSRcycle, c.GetCSR().Get(CSRcycle)+n)
-		c.GetCSR().Set(CSRtime, c.GetCSR().Get(CSRtime)+n)
-		c.GetCSR().Set(CSRinstret, c.GetCSR().Get(CSRinstret)+1)
+		c.tick()
+	}
+}
+
+// tick updates the time CSR and the devices.
+func (c *CPU) tick() {
+	c.GetCSR().Set(CSRtime, ClockTicks(c.GetClock().Since(c)))
+	for _, e := range c.ticker {
+		e.Tick(c)
 	}
 }
@@ -0,0 +1,229 @@
+package rv64
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Trap takes the trap cause with the trap value tval: the faulting address, the illegal instruction or 0. The trap
+// goes to S-mode if it happens in S-mode or U-mode and is delegated in medeleg, or mideleg for interrupts. Otherwise
+// it goes to M-mode. The PC is saved in xepc and set to the handler at xtvec. In vectored mode, interrupts jump to
+// the base plus four times their code. In mstatus, xIE is cleared after being saved in xPIE, and the privilege the
+// trap came from is saved in xPP.
+//
+// A hart that runs a user process, see CPU.SetSignals, has no trap handler. Trap returns ErrTrapHandler then.
+func (c *CPU) Trap(cause uint64, tval uint64) error {
+	code := cause &^ ExceptionInterrupt
+	deleg := c.GetCSR().Get(CSRmedeleg)
+	if cause&ExceptionInterrupt != 0 {
+		deleg = c.GetCSR().Get(CSRmideleg)
+	}
+	var epc, xcause, xtval, xtvec uint64 = CSRmepc, CSRmcause, CSRmtval, CSRmtvec
+	target := PrivilegeM
+	if c.GetPrivilege() <= PrivilegeS && deleg>>code&1 != 0 {
+		epc, xcause, xtval, xtvec = CSRsepc, CSRscause, CSRstval, CSRstvec
+		target = PrivilegeS
+	}
+	if c.signals {
+		return ErrTrapHandler
+	}
+	vec := c.GetCSR().Get(xtvec)
+	Debugln(fmt.Sprintf("%#08x % 10s cause: %#x tval: %#x", c.GetPC(), "trap", cause, tval))
+	c.GetCSR().Set(epc, c.GetPC())
+	c.GetCSR().Set(xcause, cause)
+	c.GetCSR().Set(xtval, tval)
+	// The interrupts of the target mode are disabled, their previous state and the previous privilege are saved.
+	s := c.GetCSR().Get(CSRmstatus)
+	if target == PrivilegeM {
+		s &^= MStatusMPIE | MStatusMPP
+		if s&MStatusMIE != 0 {
+			s |= MStatusMPIE
+		}
+		s = s&^MStatusMIE | c.GetPrivilege()<<11
+	} else {
+		s &^= MStatusSPIE | MStatusSPP
+		if s&MStatusSIE != 0 {
+			s |= MStatusSPIE
+		}
+		s = s&^MStatusSIE | c.GetPrivilege()<<8
+	}
+	c.GetCSR().Set(CSRmstatus, s)
+	c.SetPrivilege(target)
+	if vec&3 == 1 && cause&ExceptionInterrupt != 0 {
+		c.SetPC(vec&^3 + code*4)
+	} else {
+		c.SetPC(vec &^ 3)
+	}
+	return nil
+}
+
+// csrAccessible reports whether an instruction can access the CSR csr in the current privilege level, and write it
+// if write is set. The CSR must exist, its number encodes whether it is read-only. In S-mode, satp is not accessible
+// when mstatus.TVM is set. Below M-mode, the counters must be enabled in mcounteren, and in scounteren for U-mode.
+// The floating-point CSRs are not accessible when mstatus.FS is Off.
+func (c *CPU) csrAccessible(csr uint64, write bool) bool {
+	if e, ok := c.GetCSR().(csrExists); ok && !e.Exists(csr) {
+		return false
+	}
+	p := c.GetPrivilege()
+	switch {
+	case p < CSRPrivilege(csr):
+		return false
+	case write && csr>>10 == 3:
+		return false
+	case csr >= CSRfflags && csr <= CSRfcsr:
+		return c.GetCSR().Get(CSRmstatus)&MStatusFS != 0
+	case csr == CSRsatp && p == PrivilegeS:
+		return c.GetCSR().Get(CSRmstatus)&MStatusTVM == 0
+	case csr >= CSRcycle && csr < CSRcycle+32 && p < PrivilegeM:
+		n := csr - CSRcycle
+		return c.GetCSR().Get(CSRmcounteren)>>n&1 != 0 && (p == PrivilegeS || c.GetCSR().Get(CSRscounteren)>>n&1 != 0)
+	}
+	return true
+}
+
+// writeCSR writes the CSR csr for an instruction.
+func (c *CPU) writeCSR(csr uint64, v uint64) {
+	if csr >= CSRfflags && csr <= CSRfcsr {
+		c.floatDirty()
+	}
+	if w, ok := c.GetCSR().(csrWrite); ok {
+		w.Write(csr, v)
+		return
+	}
+	c.GetCSR().Set(csr, v)
+}
+
+// exception converts the error of the instruction data to an exception and takes it. It returns the error if it is
+// not an exception of the architecture. An exception that can not be taken halts the hart, like Linux kills a process
+// with a signal: the exit status is 128 plus the number of the signal.
+func (c *CPU) exception(err error, data []byte) error {
+	var cause, tval uint64
+	var f *AccessFault
+	switch {
+	case errors.As(err, &f):
+		switch f.Err {
+		case ErrInstructionAccessFault:
+			cause = ExceptionInstructionAccessFault
+		case ErrLoadAccessFault:
+			cause = ExceptionLoadAccessFault
+		case ErrStoreAccessFault:
+			cause = ExceptionStoreAccessFault
+		case ErrInstructionPageFault:
+			cause = ExceptionInstructionPageFault
+		case ErrLoadPageFault:
+			cause = ExceptionLoadPageFault
+		case ErrStorePageFault:
+			cause = ExceptionStorePageFault
+		default:
+			return err
+		}
+		tval = f.Addr
+	case err == ErrAbnormalInstruction || err == ErrReservedInstruction:
+		cause = ExceptionIllegalInstruction
+		for j := len(data) - 1; j >= 0; j-- {
+			tval = tval<<8 | uint64(data[j])
+		}
+	case err == ErrMisalignedInstructionFetch:
+		// Jumps never produce a misaligned target with the C extension, the target is not known here.
+		cause = ExceptionInstructionAddressMisaligned
+	case err == ErrBreakpoint:
+		cause = ExceptionBreakpoint
+		tval = c.GetPC()
+	case err == ErrEnvironmentCall:
+		cause = ExceptionEcallU + c.GetPrivilege()
+	default:
+		return err
+	}
+	if c.Trap(cause, tval) != nil {
+		Println(fmt.Sprintf("%#08x %v", c.GetPC(), err))
+		c.Halt(uint8(128 + exceptionSignal(cause)))
+	}
+	return nil
+}
+
+// exceptionSignal returns the signal Linux sends to a process for the exception cause.
+func exceptionSignal(cause uint64) uint64 {
+	switch cause {
+	case ExceptionIllegalInstruction:
+		return LinuxSigill
+	case ExceptionBreakpoint:
+		return LinuxSigtrap
+	case ExceptionInstructionAddressMisaligned:
+		return LinuxSigbus
+	case ExceptionEcallU, ExceptionEcallS, ExceptionEcallM:
+		return LinuxSigsys
+	}
+	return LinuxSigsegv
+}
+
+// Interrupts in decreasing priority, machine level ones first.
+var cpuInterrupts = []uint64{11, 3, 7, 9, 1, 5}
+
+// interrupt takes the pending and enabled interrupt with the highest priority, if any. An interrupt that is not
+// delegated in mideleg goes to M-mode, it is enabled in a lower privilege or when mstatus.MIE is set. A delegated
+// interrupt goes to S-mode, it is enabled in U-mode or in S-mode when mstatus.SIE is set, never in M-mode.
+func (c *CPU) interrupt() {
+	p := c.GetCSR().Get(CSRmip) & c.GetCSR().Get(CSRmie)
+	if p == 0 {
+		return
+	}
+	s := c.GetCSR().Get(CSRmstatus)
+	deleg := c.GetCSR().Get(CSRmideleg)
+	for _, code := range cpuInterrupts {
+		if p>>code&1 == 0 {
+			continue
+		}
+		var enabled bool
+		if deleg>>code&1 == 0 {
+			enabled = c.GetPrivilege() < PrivilegeM || s&MStatusMIE != 0
+		} else {
+			enabled = c.GetPrivilege() < PrivilegeS || c.GetPrivilege() == PrivilegeS && s&MStatusSIE != 0
+		}
+		if enabled {
+			c.Trap(ExceptionInterrupt|code, 0)
+			return
+		}
+	}
+}
+
+// cpuNap is the longest sleep of a waiting hart. Devices that receive data from the host can wake the hart at any
+// time, they are checked at least that often.
+const cpuNap = time.Millisecond
+
+// sleep waits until an interrupt is pending in mip and enabled in mie, whether it can be taken or not. If a device
+// has an alarm set, a clock that can skip time jumps to it, otherwise the host sleeps until then. The hart does not
+// wait when nothing can wake it: no interrupt is enabled, or the clock only advances with the instructions and there
+// is no alarm. WFI is then a no-op, which the architecture allows.
+func (c *CPU) sleep() {
+	for c.GetCSR().Get(CSRmip)&c.GetCSR().Get(CSRmie) == 0 {
+		if c.GetCSR().Get(CSRmie) == 0 {
+			return
+		}
+		now := c.GetClock().Since(c)
+		next := time.Duration(-1)
+		for _, e := range c.ticker {
+			if a, ok := e.(DeviceAlarm); ok {
+				if d, ok := a.Alarm(c); ok && d > now && (next < 0 || d < next) {
+					next = d
+				}
+			}
+		}
+		k, skip := c.GetClock().(clockSkip)
+		if skip && next < 0 {
+			return
+		}
+		if skip {
+			k.Skip(c, next)
+		} else if next >= 0 && next-now < cpuNap {
+			time.Sleep(next - now)
+		} else {
+			time.Sleep(cpuNap)
+		}
+		if k, ok := c.GetClock().(clockSampler); ok {
+			k.Sample()
+		}
+		c.tick()
+	}
+}
@@ -0,0 +1,218 @@
+package rv64
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// testCPU returns a hart in M-mode with the instructions code in memory. It exits with ecall 93.
+func testCPU(code map[uint64]uint32) *CPU {
+	c := NewCPU()
+	c.SetFasten(NewLinear(0x4000))
+	c.SetCSR(NewCSRStandard())
+	c.SetSystem(NewSystemStandard())
+	for a, e := range code {
+		b := make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, e)
+		c.GetMemory().SetByte(a, b)
+	}
+	return c
+}
+
+func TestCPUTrap(t *testing.T) {
+	c := testCPU(map[uint64]uint32{
+		0x1000: 0x0000007b, // Custom opcode, illegal
+		0x1004: 0x00100073, // ebreak
+		0x2000: 0x34302573, // csrr a0, mtval
+		0x2004: 0x05d00893, // li a7, 93
+		0x2008: 0x00000073, // ecall
+		0x3000: 0x14202573, // csrr a0, scause
+		0x3004: 0x05d00893, // li a7, 93
+		0x3008: 0x00000073, // ecall
+	})
+
+	c.GetCSR().Set(CSRmtvec, 0x2000)
+	c.SetPC(0x1000)
+	if r := c.Run(); r != 0x7b || c.GetCSR().Get(CSRmcause) != ExceptionIllegalInstruction || c.GetCSR().Get(CSRmepc) != 0x1000 {
+		t.Fatal(r, c.GetCSR().Get(CSRmcause))
+	}
+
+	c.SetStatus(0)
+	c.SetPrivilege(PrivilegeU)
+	c.GetCSR().Set(CSRmedeleg, 1<<ExceptionBreakpoint)
+	c.GetCSR().Set(CSRstvec, 0x3000)
+	c.SetPC(0x1004)
+	if r := c.Run(); uint64(r) != ExceptionBreakpoint || c.GetPrivilege() != PrivilegeS || c.GetCSR().Get(CSRsepc) != 0x1004 || c.GetCSR().Get(CSRstval) != 0x1004 {
+		t.Fatal(r, c.GetPrivilege(), c.GetCSR().Get(CSRsepc))
+	}
+}
+
+func TestCPUTrapHandler(t *testing.T) {
+	c := testCPU(map[uint64]uint32{
+		0x1000: 0x0000007b, // Custom opcode, illegal
+		0x1004: 0x00100073, // ebreak
+	})
+	// A user process has no trap handler, the hart stops like a process killed by SIGILL.
+	c.SetSignals(true)
+	c.SetPrivilege(PrivilegeU)
+	c.SetPC(0x1000)
+	if r := c.Run(); uint64(r) != 128+LinuxSigill || c.GetPC() != 0x1000 {
+		t.Fatal(r, c.GetPC())
+	}
+	c.SetStatus(0)
+	c.SetPC(0x1004)
+	if r := c.Run(); uint64(r) != 128+LinuxSigtrap {
+		t.Fatal(r)
+	}
+
+	// Firmware at address 0 handles its traps.
+	c = testCPU(map[uint64]uint32{
+		0x0000: 0x34202573, // csrr a0, mcause
+		0x0004: 0x05d00893, // li a7, 93
+		0x0008: 0x00000073, // ecall
+		0x1000: 0x0000007b, // Custom opcode, illegal
+	})
+	c.SetPC(0x1000)
+	if r := c.Run(); uint64(r) != ExceptionIllegalInstruction || c.GetCSR().Get(CSRmepc) != 0x1000 {
+		t.Fatal(r, c.GetCSR().Get(CSRmepc))
+	}
+}
+
+func TestCPUEnvironmentCall(t *testing.T) {
+	c := testCPU(map[uint64]uint32{
+		0x1000: 0x00000073, // ecall
+		0x2000: 0x34202573, // csrr a0, mcause
+		0x2004: 0x001002b7, // lui t0, 0x100
+		0x2008: 0x00005337, // lui t1, 0x5
+		0x200c: 0x55530313, // addi t1, t1, 0x555
+		0x2010: 0x0062a023, // sw t1, 0(t0)
+	})
+	// Without a system emulating the environment, the call of the kernel traps to the firmware. It exits through the
+	// test finisher.
+	b := NewBus(c.fasten)
+	b.Attach(0x100000, FinisherSize, NewDeviceFinisher(c))
+	c.SetFasten(b)
+	c.SetSystem(nil)
+	c.GetCSR().Set(CSRmtvec, 0x2000)
+	c.SetPrivilege(PrivilegeS)
+	c.SetPC(0x1000)
+	if r := c.Run(); r != 0 || c.GetRegister(Ra0) != ExceptionEcallS || c.GetCSR().Get(CSRmepc) != 0x1000 {
+		t.Fatal(r, c.GetRegister(Ra0), c.GetCSR().Get(CSRmepc))
+	}
+}
+
+func TestCPUPrivilege(t *testing.T) {
+	c := testCPU(map[uint64]uint32{
+		0x1000: 0x30200073, // mret
+		0x1100: 0x10002573, // csrr a0, sstatus
+		0x1104: 0x30002573, // csrr a0, mstatus
+		0x2000: 0x34202573, // csrr a0, mcause
+		0x2004: 0x05d00893, // li a7, 93
+		0x2008: 0x00000073, // ecall
+	})
+	c.GetCSR().Set(CSRmtvec, 0x2000)
+	c.GetCSR().Set(CSRmepc, 0x1100)
+	c.GetCSR().Set(CSRmstatus, PrivilegeS<<11|MStatusMPIE)
+	c.SetPC(0x1000)
+	if r := c.Run(); uint64(r) != ExceptionIllegalInstruction || c.GetCSR().Get(CSRmepc) != 0x1104 {
+		t.Fatal(r, c.GetCSR().Get(CSRmepc))
+	}
+	// The mret enabled the interrupts of M-mode, the trap saved them and the privilege of S-mode.
+	if s := c.GetCSR().Get(CSRmstatus) & (MStatusMPP | MStatusMPIE | MStatusMIE); s != PrivilegeS<<11|MStatusMPIE {
+		t.Fatalf("%#x", s)
+	}
+}
+
+func TestCPUInterrupt(t *testing.T) {
+	c := testCPU(map[uint64]uint32{
+		0x1000: 0x10500073, // wfi
+		0x1004: 0x0000006f, // j .
+		0x2000: 0x34202573, // csrr a0, mcause
+		0x2004: 0x05d00893, // li a7, 93
+		0x2008: 0x00000073, // ecall
+	})
+	b := NewBus(c.fasten)
+	clint := NewDeviceCLINT(c)
+	b.Attach(0x2000000, CLINTSize, clint)
+	c.SetFasten(b)
+	c.AddTicker(clint)
+	c.GetMemory().SetUint64(0x2000000+CLINTMtimecmp, 1000000)
+	c.GetCSR().Set(CSRmtvec, 0x2000)
+	c.GetCSR().Set(CSRmie, MIPMTIP)
+	c.GetCSR().Set(CSRmstatus, MStatusMIE)
+	c.SetPC(0x1000)
+	// The cycle clock skips the idle time of wfi up to the timer.
+	if r := c.Run(); r != 7 || c.GetCSR().Get(CSRmcause) != ExceptionInterrupt|7 || c.GetCSR().Get(CSRmepc) != 0x1004 {
+		t.Fatal(r, c.GetCSR().Get(CSRmcause), c.GetCSR().Get(CSRmepc))
+	}
+	if n := c.GetCSR().Get(CSRcycle); n < 1000000 || n > 1000010 {
+		t.Fatal(n)
+	}
+}
+
+func TestCPUWaitForInterrupt(t *testing.T) {
+	c := testCPU(map[uint64]uint32{
+		0x1000: 0x10500073, // wfi
+		0x1004: 0x02a00513, // li a0, 42
+		0x1008: 0x05d00893, // li a7, 93
+		0x100c: 0x00000073, // ecall
+	})
+	// No interrupt is enabled, the hart does not wait.
+	c.SetPC(0x1000)
+	if r := c.Run(); r != 42 {
+		t.Fatal(r)
+	}
+	// The timer interrupt is enabled but there is no timer to raise it, and the cycle clock does not advance alone.
+	c.SetStatus(0)
+	c.GetCSR().Set(CSRmie, MIPMTIP)
+	c.SetPC(0x1000)
+	if r := c.Run(); r != 42 {
+		t.Fatal(r)
+	}
+}
+
+func TestCPUFloatStatus(t *testing.T) {
+	c := testCPU(map[uint64]uint32{
+		0x1000: 0xf2050553, // fmv.d.x fa0, a0
+		0x1004: 0x05d00893, // li a7, 93
+		0x1008: 0x00000073, // ecall
+		0x1100: 0x00302573, // frcsr a0
+		0x1104: 0x05d00893, // li a7, 93
+		0x1108: 0x00000073, // ecall
+		0x1200: 0x10a03027, // fsd fa0, 0x100(zero)
+		0x1204: 0x05d00893, // li a7, 93
+		0x1208: 0x00000073, // ecall
+		0x1300: 0x00105073, // fsflagsi 0
+		0x1304: 0x05d00893, // li a7, 93
+		0x1308: 0x00000073, // ecall
+		0x2000: 0x34202573, // csrr a0, mcause
+		0x2004: 0x05d00893, // li a7, 93
+		0x2008: 0x00000073, // ecall
+	})
+	c.GetCSR().Set(CSRmtvec, 0x2000)
+	run := func(pc uint64, fs uint64) uint8 {
+		c.SetStatus(0)
+		c.SetRegister(Ra0, 0)
+		c.GetCSR().Set(CSRmstatus, fs<<13)
+		c.SetPC(pc)
+		return c.Run()
+	}
+	// The floating-point unit is off, its instructions and CSRs are illegal.
+	for _, pc := range []uint64{0x1000, 0x1100, 0x1200, 0x1300} {
+		if r := run(pc, 0); uint64(r) != ExceptionIllegalInstruction {
+			t.Fatalf("%#x %d", pc, r)
+		}
+	}
+	// Changing the floating-point state makes it dirty, a store does not.
+	for _, e := range []struct {
+		pc uint64
+		fs uint64
+	}{{0x1000, 3}, {0x1100, 1}, {0x1200, 1}, {0x1300, 3}} {
+		if r := run(e.pc, 1); r != 0 || c.GetCSR().Get(CSRmstatus)&MStatusFS != e.fs<<13 {
+			t.Fatalf("%#x %d %#x", e.pc, r, c.GetCSR().Get(CSRmstatus))
+		}
+	}
+	if c.GetCSR().Get(CSRmstatus)&MStatusSD == 0 {
+		t.Fatal("SD is clear")
+	}
+}
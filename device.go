@@ -0,0 +1,117 @@
+package rv64
+
+import (
+	"time"
+)
+
+// Device is a peripheral attached to a Bus. Read and Write access n bytes, 1, 2, 4 or 8, at offset off from the base
+// of the device. The value is little-endian and zero extended. A device refuses an access by returning
+// ErrLoadAccessFault or ErrStoreAccessFault, the Bus adds the address.
+type Device interface {
+	Read(off uint64, n uint64) (uint64, error)
+	Write(off uint64, n uint64, v uint64) error
+}
+
+// DeviceTicker is implemented by devices whose state changes with time, see CPU.AddTicker.
+type DeviceTicker interface {
+	Tick(c *CPU)
+}
+
+// DeviceAlarm is implemented by tickers that will raise an interrupt at a known time. Alarm returns that time as a
+// duration of the clock of the hart, see Clock.Since. A hart waiting for an interrupt uses it to skip the idle time.
+type DeviceAlarm interface {
+	Alarm(c *CPU) (time.Duration, bool)
+}
+
+// DeviceFetch is implemented by devices holding code, such as a boot ROM. Fetch reads the len(p) bytes of an
+// instruction at offset off. Instructions can not be fetched from the other devices.
+type DeviceFetch interface {
+	Fetch(off uint64, p []byte) error
+}
+
+// DeviceRAM is readable and writable memory of a fixed size.
+type DeviceRAM struct {
+	Data []byte
+}
+
+func (d *DeviceRAM) Read(off uint64, n uint64) (uint64, error) {
+	if off >= uint64(len(d.Data)) || uint64(len(d.Data))-off < n {
+		return 0, ErrLoadAccessFault
+	}
+	var v uint64
+	for i := n; i > 0; i-- {
+		v = v<<8 | uint64(d.Data[off+i-1])
+	}
+	return v, nil
+}
+
+func (d *DeviceRAM) Write(off uint64, n uint64, v uint64) error {
+	if off >= uint64(len(d.Data)) || uint64(len(d.Data))-off < n {
+		return ErrStoreAccessFault
+	}
+	for i := uint64(0); i < n; i++ {
+		d.Data[off+i] = byte(v >> (i * 8))
+	}
+	return nil
+}
+
+func (d *DeviceRAM) Fetch(off uint64, p []byte) error {
+	if off >= uint64(len(d.Data)) || uint64(len(d.Data))-off < uint64(len(p)) {
+		return ErrInstructionAccessFault
+	}
+	copy(p, d.Data[off:])
+	return nil
+}
+
+// NewDeviceRAM returns size bytes of zeroed memory.
+func NewDeviceRAM(size uint64) *DeviceRAM {
+	return &DeviceRAM{Data: make([]byte, size)}
+}
+
+// DeviceROM is read-only memory, writes are store access faults.
+type DeviceROM struct {
+	DeviceRAM
+}
+
+func (d *DeviceROM) Write(off uint64, n uint64, v uint64) error {
+	return ErrStoreAccessFault
+}
+
+// NewDeviceROM returns a ROM holding a copy of data.
+func NewDeviceROM(data []byte) *DeviceROM {
+	return &DeviceROM{DeviceRAM{Data: append([]byte{}, data...)}}
+}
+
+// DeviceMMIO is a bank of registers of Width bytes, implemented by callbacks. Only naturally aligned accesses of
+// exactly Width bytes reach the callbacks, the others are access faults. A nil OnRead reads zero and a nil OnWrite
+// ignores the value.
+type DeviceMMIO struct {
+	Width   uint64
+	OnRead  func(off uint64) uint64
+	OnWrite func(off uint64, v uint64)
+}
+
+func (d *DeviceMMIO) Read(off uint64, n uint64) (uint64, error) {
+	if n != d.Width || off%n != 0 {
+		return 0, ErrLoadAccessFault
+	}
+	if d.OnRead == nil {
+		return 0, nil
+	}
+	return d.OnRead(off), nil
+}
+
+func (d *DeviceMMIO) Write(off uint64, n uint64, v uint64) error {
+	if n != d.Width || off%n != 0 {
+		return ErrStoreAccessFault
+	}
+	if d.OnWrite != nil {
+		d.OnWrite(off, v)
+	}
+	return nil
+}
+
+// NewDeviceMMIO returns registers of width bytes backed by the callbacks.
+func NewDeviceMMIO(width uint64, onRead func(off uint64) uint64, onWrite func(off uint64, v uint64)) *DeviceMMIO {
+	return &DeviceMMIO{Width: width, OnRead: onRead, OnWrite: onWrite}
+}
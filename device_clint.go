@@ -0,0 +1,109 @@
+package rv64
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+)
+
+// CLINTSize is the size of the register window of DeviceCLINT.
+const CLINTSize uint64 = 0x10000
+
+// Registers of the CLINT of hart 0, as offsets from the base. The layout is the one of SiFive, also used by QEMU.
+const (
+	CLINTMsip     uint64 = 0x0000 // Machine software interrupt pending, bit 0. 4 bytes.
+	CLINTMtimecmp uint64 = 0x4000 // Machine timer compare. 8 bytes.
+	CLINTMtime    uint64 = 0xbff8 // Machine time. 8 bytes.
+)
+
+// DeviceCLINT is the core local interruptor of a single hart. It raises the machine software interrupt when msip is
+// set, and the machine timer interrupt while mtime is greater than or equal to mtimecmp.
+//
+// The mtime counter runs at ClockFrequency and follows the Clock of the hart, it advances with the instructions
+// executed or with the time of the host. Writing mtime offsets it, and the time CSR reads the same value. mtimecmp
+// starts at its maximum, no timer interrupt is pending after reset. The 64-bit registers may be accessed as two
+// 32-bit halves.
+type DeviceCLINT struct {
+	cpu      *CPU
+	msip     uint32
+	mtimecmp uint64
+	offset   uint64
+}
+
+// mtime returns the current value of mtime.
+func (d *DeviceCLINT) mtime() uint64 {
+	return ClockTicks(d.cpu.GetClock().Since(d.cpu)) + d.offset
+}
+
+// reg returns the register accessed at off and the offset of the access inside it. It reports false if there is no
+// register of width n at off.
+func (d *DeviceCLINT) reg(off uint64, n uint64) (uint64, uint64, bool) {
+	if off%n != 0 {
+		return 0, 0, false
+	}
+	switch {
+	case off == CLINTMsip && n == 4:
+		return uint64(d.msip), 0, true
+	case off&^4 == CLINTMtimecmp && (n == 4 || n == 8):
+		return d.mtimecmp, off - CLINTMtimecmp, true
+	case off&^4 == CLINTMtime && (n == 4 || n == 8):
+		return d.mtime(), off - CLINTMtime, true
+	}
+	return 0, 0, false
+}
+
+func (d *DeviceCLINT) Read(off uint64, n uint64) (uint64, error) {
+	r, o, ok := d.reg(off, n)
+	if !ok {
+		return 0, ErrLoadAccessFault
+	}
+	if n == 4 {
+		return r >> (o * 8) & 0xffffffff, nil
+	}
+	return r, nil
+}
+
+func (d *DeviceCLINT) Write(off uint64, n uint64, v uint64) error {
+	r, o, ok := d.reg(off, n)
+	if !ok {
+		return ErrStoreAccessFault
+	}
+	if n == 4 {
+		b := make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, r)
+		binary.LittleEndian.PutUint32(b[o:], uint32(v))
+		v = binary.LittleEndian.Uint64(b)
+	}
+	switch off &^ 4 {
+	case CLINTMsip:
+		d.msip = uint32(v) & 1
+	case CLINTMtimecmp:
+		d.mtimecmp = v
+	case CLINTMtime:
+		d.offset += v - r
+	}
+	d.Tick(d.cpu)
+	return nil
+}
+
+// Tick updates the time CSR and the interrupt pending bits of the hart.
+func (d *DeviceCLINT) Tick(c *CPU) {
+	t := d.mtime()
+	c.GetCSR().Set(CSRtime, t)
+	c.SetPending(MIPMSIP, d.msip != 0)
+	c.SetPending(MIPMTIP, t >= d.mtimecmp)
+}
+
+// Alarm returns when mtime reaches mtimecmp.
+func (d *DeviceCLINT) Alarm(c *CPU) (time.Duration, bool) {
+	n := d.mtimecmp - d.offset
+	if d.mtimecmp == ^uint64(0) || n > uint64(math.MaxInt64)/(uint64(time.Second)/ClockFrequency) {
+		return 0, false
+	}
+	return time.Duration(n * (uint64(time.Second) / ClockFrequency)), true
+}
+
+// NewDeviceCLINT returns the CLINT of the hart c. It must be added to the tickers of c with CPU.AddTicker.
+func NewDeviceCLINT(c *CPU) *DeviceCLINT {
+	return &DeviceCLINT{cpu: c, mtimecmp: ^uint64(0)}
+}
@@ -0,0 +1,40 @@
+package rv64
+
+import (
+	"testing"
+)
+
+func TestDeviceCLINT(t *testing.T) {
+	c := NewCPU()
+	c.SetCSR(NewCSRStandard())
+	d := NewDeviceCLINT(c)
+	c.AddTicker(d)
+	b := NewBus(NewLinear(0x1000))
+	b.Attach(0x2000000, CLINTSize, d)
+	m := &Memory{Fasten: b}
+
+	m.SetUint64(0x2000000+CLINTMtimecmp, 100)
+	c.GetCSR().Set(CSRcycle, 99)
+	d.Tick(c)
+	if v, _ := m.GetUint64(0x2000000 + CLINTMtime); v != 99 || c.GetCSR().Get(CSRmip) != 0 {
+		t.Fatal(v, c.GetCSR().Get(CSRmip))
+	}
+	c.GetCSR().Set(CSRcycle, 100)
+	d.Tick(c)
+	if c.GetCSR().Get(CSRmip) != MIPMTIP || c.GetCSR().Get(CSRtime) != 100 {
+		t.Fatal(c.GetCSR().Get(CSRmip))
+	}
+	// Moving mtime backwards with its high half clears the interrupt.
+	m.SetUint32(0x2000000+CLINTMtime+4, 0)
+	m.SetUint32(0x2000000+CLINTMtime, 10)
+	if v, _ := m.GetUint64(0x2000000 + CLINTMtime); v != 10 || c.GetCSR().Get(CSRmip) != 0 {
+		t.Fatal(v, c.GetCSR().Get(CSRmip))
+	}
+	m.SetUint32(0x2000000+CLINTMsip, 1)
+	if c.GetCSR().Get(CSRmip) != MIPMSIP {
+		t.Fatal(c.GetCSR().Get(CSRmip))
+	}
+	if _, err := m.GetUint8(0x2000000 + CLINTMtime); err == nil {
+		t.Fatal("byte access")
+	}
+}
@@ -0,0 +1,42 @@
+package rv64
+
+// FinisherSize is the size of the register window of DeviceFinisher.
+const FinisherSize uint64 = 0x1000
+
+// Values of the register of DeviceFinisher.
+const (
+	FinisherFail uint64 = 0x3333 // Exit with the status in the upper 16 bits
+	FinisherPass uint64 = 0x5555 // Exit with status 0
+)
+
+// DeviceFinisher is the test finisher of SiFive, also found on the virt board of QEMU. Bare-metal programs have no
+// system to exit through, they stop the hart by writing a 32-bit value to its register at offset 0. Other values are
+// ignored, the hart can not be reset.
+type DeviceFinisher struct {
+	cpu *CPU
+}
+
+func (d *DeviceFinisher) Read(off uint64, n uint64) (uint64, error) {
+	if off != 0 || n != 4 {
+		return 0, ErrLoadAccessFault
+	}
+	return 0, nil
+}
+
+func (d *DeviceFinisher) Write(off uint64, n uint64, v uint64) error {
+	if off != 0 || n != 4 {
+		return ErrStoreAccessFault
+	}
+	switch v & 0xffff {
+	case FinisherFail:
+		d.cpu.Halt(uint8(v >> 16))
+	case FinisherPass:
+		d.cpu.Halt(0)
+	}
+	return nil
+}
+
+// NewDeviceFinisher returns the test finisher of the hart c.
+func NewDeviceFinisher(c *CPU) *DeviceFinisher {
+	return &DeviceFinisher{cpu: c}
+}
@@ -0,0 +1,22 @@
+package rv64
+
+import (
+	"testing"
+)
+
+func TestDeviceFinisher(t *testing.T) {
+	c := NewCPU()
+	b := NewBus(NewLinear(0x1000))
+	b.Attach(0x100000, FinisherSize, NewDeviceFinisher(c))
+	m := &Memory{Fasten: b}
+	if err := m.SetUint32(0x100000, 0x1234); err != nil || c.GetStatus() != 0 {
+		t.Fatal(err, c.GetStatus())
+	}
+	if err := m.SetUint32(0x100000, 3<<16|uint32(FinisherFail)); err != nil || c.GetStatus() != 1 || c.Code() != 3 {
+		t.Fatal(err, c.GetStatus(), c.Code())
+	}
+	c.SetStatus(0)
+	if err := m.SetUint32(0x100000, uint32(FinisherPass)); err != nil || c.GetStatus() != 1 || c.Code() != 0 {
+		t.Fatal(err, c.GetStatus(), c.Code())
+	}
+}
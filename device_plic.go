@@ -0,0 +1,195 @@
+package rv64
+
+// PLICSize is the size of the register window of DevicePLIC.
+const PLICSize uint64 = 0x4000000
+
+// Registers of the PLIC, as offsets from the base. The layout is the one of SiFive, also used by QEMU. All registers
+// are 4 bytes wide.
+const (
+	PLICPriority  uint64 = 0x000000 // Priority of source n at 4*n.
+	PLICPending   uint64 = 0x001000 // Pending bits, 32 sources per word.
+	PLICEnable    uint64 = 0x002000 // Enable bits of context n at 0x80*n, 32 sources per word.
+	PLICThreshold uint64 = 0x200000 // Priority threshold of context n at 0x1000*n.
+	PLICClaim     uint64 = 0x200004 // Claim and complete of context n at 0x1000*n.
+)
+
+// Contexts of the hart. Each has its own enables and threshold, and drives one external interrupt pending bit.
+const (
+	PLICContextM uint64 = 0 // Machine mode, MEIP
+	PLICContextS uint64 = 1 // Supervisor mode, SEIP
+)
+
+// PLICPriorityMax is the highest priority of a source. Priority 0 never interrupts.
+const PLICPriorityMax uint32 = 7
+
+// DevicePLIC is the platform-level interrupt controller of a single hart. Sources are level triggered: a source
+// becomes pending while its line is high, and stays pending until it is claimed. A claimed source is not pending
+// again until its handler completes it.
+//
+// A context raises its external interrupt pending bit while one of its enabled sources is pending with a priority
+// above the threshold. A claim returns the pending source with the highest priority, the lowest id on ties, or 0 if
+// there is none. Registers that do not exist read zero and ignore writes.
+type DevicePLIC struct {
+	cpu       *CPU
+	priority  []uint32
+	level     []bool
+	pending   []bool
+	claimed   []bool
+	enable    [2][]bool
+	threshold [2]uint32
+}
+
+// bits returns the word-th 32 bits of the bitmap b.
+func (p *DevicePLIC) bits(b []bool, word uint64) uint64 {
+	var r uint64
+	for i := uint64(0); i < 32; i++ {
+		if j := word*32 + i; j < uint64(len(b)) && b[j] {
+			r |= 1 << i
+		}
+	}
+	return r
+}
+
+func (p *DevicePLIC) Read(off uint64, n uint64) (uint64, error) {
+	if n != 4 || off%4 != 0 {
+		return 0, ErrLoadAccessFault
+	}
+	switch {
+	case off < PLICPending:
+		if i := off / 4; i < uint64(len(p.priority)) {
+			return uint64(p.priority[i]), nil
+		}
+	case off < PLICEnable:
+		return p.bits(p.pending, (off-PLICPending)/4), nil
+	case off < PLICThreshold:
+		if ctx := (off - PLICEnable) / 0x80; ctx <= PLICContextS {
+			return p.bits(p.enable[ctx], (off-PLICEnable)%0x80/4), nil
+		}
+	default:
+		ctx := (off - PLICThreshold) / 0x1000
+		if ctx > PLICContextS {
+			break
+		}
+		switch off - ctx*0x1000 {
+		case PLICThreshold:
+			return uint64(p.threshold[ctx]), nil
+		case PLICClaim:
+			return p.Claim(ctx), nil
+		}
+	}
+	return 0, nil
+}
+
+func (p *DevicePLIC) Write(off uint64, n uint64, v uint64) error {
+	if n != 4 || off%4 != 0 {
+		return ErrStoreAccessFault
+	}
+	switch {
+	case off < PLICPending:
+		// Source 0 does not exist, its priority is hardwired to zero.
+		if i := off / 4; i != 0 && i < uint64(len(p.priority)) {
+			p.priority[i] = uint32(v) & PLICPriorityMax
+		}
+	case off < PLICEnable:
+		// The pending bits are read-only.
+	case off < PLICThreshold:
+		ctx := (off - PLICEnable) / 0x80
+		if ctx > PLICContextS {
+			break
+		}
+		word := (off - PLICEnable) % 0x80 / 4
+		for i := uint64(0); i < 32; i++ {
+			if j := word*32 + i; j != 0 && j < uint64(len(p.enable[ctx])) {
+				p.enable[ctx][j] = v>>i&1 != 0
+			}
+		}
+	default:
+		ctx := (off - PLICThreshold) / 0x1000
+		if ctx > PLICContextS {
+			break
+		}
+		switch off - ctx*0x1000 {
+		case PLICThreshold:
+			p.threshold[ctx] = uint32(v) & PLICPriorityMax
+		case PLICClaim:
+			p.Complete(ctx, v)
+		}
+	}
+	p.update()
+	return nil
+}
+
+// best returns the source to be claimed by ctx, or 0.
+func (p *DevicePLIC) best(ctx uint64) uint64 {
+	r := uint64(0)
+	max := p.threshold[ctx]
+	for i := range p.pending {
+		if p.pending[i] && p.enable[ctx][i] && p.priority[i] > max {
+			r = uint64(i)
+			max = p.priority[i]
+		}
+	}
+	return r
+}
+
+// update sets the external interrupt pending bits of the hart.
+func (p *DevicePLIC) update() {
+	p.cpu.SetPending(MIPMEIP, p.best(PLICContextM) != 0)
+	p.cpu.SetPending(MIPSEIP, p.best(PLICContextS) != 0)
+}
+
+// Claim acknowledges the interrupt with the highest priority of context ctx and returns its source, or 0.
+func (p *DevicePLIC) Claim(ctx uint64) uint64 {
+	i := p.best(ctx)
+	if i != 0 {
+		p.pending[i] = false
+		p.claimed[i] = true
+		p.update()
+	}
+	return i
+}
+
+// Complete ends the handling of source src by context ctx. It is ignored if the source is not enabled for ctx.
+func (p *DevicePLIC) Complete(ctx uint64, src uint64) {
+	if src == 0 || src >= uint64(len(p.claimed)) || !p.enable[ctx][src] {
+		return
+	}
+	p.claimed[src] = false
+	if p.level[src] {
+		p.pending[src] = true
+	}
+	p.update()
+}
+
+// SetLevel sets the level of the interrupt line of source src.
+func (p *DevicePLIC) SetLevel(src uint64, level bool) {
+	if src == 0 || src >= uint64(len(p.level)) {
+		return
+	}
+	p.level[src] = level
+	if level && !p.claimed[src] {
+		p.pending[src] = true
+	}
+	p.update()
+}
+
+// IRQ returns the interrupt line of source src, to be connected to a device. See DeviceUART.IRQ.
+func (p *DevicePLIC) IRQ(src uint64) func(level bool) {
+	return func(level bool) {
+		p.SetLevel(src, level)
+	}
+}
+
+// NewDevicePLIC returns the PLIC of the hart c with the sources 1 to n. All sources start disabled with priority 0.
+func NewDevicePLIC(c *CPU, n uint64) *DevicePLIC {
+	p := &DevicePLIC{
+		cpu:      c,
+		priority: make([]uint32, n+1),
+		level:    make([]bool, n+1),
+		pending:  make([]bool, n+1),
+		claimed:  make([]bool, n+1),
+	}
+	p.enable[PLICContextM] = make([]bool, n+1)
+	p.enable[PLICContextS] = make([]bool, n+1)
+	return p
+}
@@ -0,0 +1,46 @@
+package rv64
+
+import (
+	"testing"
+)
+
+func TestDevicePLIC(t *testing.T) {
+	c := NewCPU()
+	c.SetCSR(NewCSRStandard())
+	p := NewDevicePLIC(c, 64)
+	b := NewBus(NewLinear(0x1000))
+	b.Attach(0xc000000, PLICSize, p)
+	m := &Memory{Fasten: b}
+	mip := func() uint64 { return c.GetCSR().Get(CSRmip) }
+
+	m.SetUint32(0xc000000+PLICPriority+4*10, 1)
+	m.SetUint32(0xc000000+PLICPriority+4*33, 2)
+	m.SetUint32(0xc000000+PLICEnable, 1<<10)
+	m.SetUint32(0xc000000+PLICEnable+4, 1<<1)
+	p.SetLevel(10, true)
+	p.SetLevel(33, true)
+	if v, _ := m.GetUint32(0xc000000 + PLICPending + 4); v != 1<<1 || mip() != MIPMEIP {
+		t.Fatal(v, mip())
+	}
+	// The threshold masks source 10, but not 33.
+	m.SetUint32(0xc000000+PLICThreshold, 1)
+	if v, _ := m.GetUint32(0xc000000 + PLICClaim); v != 33 || mip() != 0 {
+		t.Fatal(v, mip())
+	}
+	if v, _ := m.GetUint32(0xc000000 + PLICClaim); v != 0 {
+		t.Fatal(v)
+	}
+	// The line is still high at completion, the source is pending again.
+	m.SetUint32(0xc000000+PLICClaim, 33)
+	if mip() != MIPMEIP {
+		t.Fatal(mip())
+	}
+	// The supervisor context sees nothing it did not enable.
+	m.SetUint32(0xc000000+PLICEnable+0x80, 1<<10)
+	if mip() != MIPMEIP|MIPSEIP {
+		t.Fatal(mip())
+	}
+	if v, _ := m.GetUint32(0xc000000 + PLICClaim + 0x1000); v != 10 || mip() != MIPMEIP {
+		t.Fatal(v, mip())
+	}
+}
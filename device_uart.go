@@ -0,0 +1,244 @@
+package rv64
+
+import (
+	"io"
+)
+
+// UARTSize is the size of the register window of DeviceUART.
+const UARTSize uint64 = 8
+
+// Registers of the 16550, as offsets from the base. Some offsets select another register when LCR.DLAB is set, or on
+// write.
+const (
+	UARTRBR uint64 = 0 // Receiver buffer, read. Transmitter holding register on write, divisor latch low with DLAB.
+	UARTIER uint64 = 1 // Interrupt enable. Divisor latch high with DLAB.
+	UARTIIR uint64 = 2 // Interrupt identification, read. FIFO control on write.
+	UARTLCR uint64 = 3 // Line control.
+	UARTMCR uint64 = 4 // Modem control.
+	UARTLSR uint64 = 5 // Line status.
+	UARTMSR uint64 = 6 // Modem status.
+	UARTSCR uint64 = 7 // Scratch.
+)
+
+// Bits of the 16550 registers.
+const (
+	UARTIERRDA  uint8 = 0x01 // Received data available interrupt
+	UARTIERTHRE uint8 = 0x02 // Transmitter holding register empty interrupt
+	UARTIIRNone uint8 = 0x01 // No interrupt pending
+	UARTIIRTHRE uint8 = 0x02
+	UARTIIRRDA  uint8 = 0x04
+	UARTIIRFIFO uint8 = 0xc0 // FIFOs enabled
+	UARTFCRFIFO uint8 = 0x01
+	UARTFCRRX   uint8 = 0x02 // Clear the receive FIFO
+	UARTLCRDLAB uint8 = 0x80 // Divisor latch access
+	UARTMCRLoop uint8 = 0x10 // Loopback
+	UARTLSRDR   uint8 = 0x01 // Data ready
+	UARTLSRTHRE uint8 = 0x20 // Transmitter holding register empty
+	UARTLSRTEMT uint8 = 0x40 // Transmitter empty
+)
+
+// uartFIFO is the depth of the receive FIFO of the 16550A.
+const uartFIFO = 16
+
+// DeviceUART is a NS16550A UART. Transmitted bytes are written to Writer at once, so the transmitter is always
+// empty. Received bytes are read from the reader of NewDeviceUART in the background and wait in the 16-byte receive FIFO. The registers
+// are one byte wide, other accesses are access faults.
+//
+// IRQ, if set, is called with the new level of the interrupt line whenever it changes. The device only notices
+// received bytes when it is accessed or when Update is called, the owner of the device is expected to call Update
+// regularly, see CPU.AddTicker.
+type DeviceUART struct {
+	Writer io.Writer
+	IRQ    func(level bool)
+	rx     chan byte
+	fifo   []byte
+	ier    uint8
+	lcr    uint8
+	mcr    uint8
+	scr    uint8
+	dll    uint8
+	dlm    uint8
+	fcr    uint8
+	// The THRE interrupt is cleared by reading IIR and raised again by the next write of THR.
+	thre  bool
+	level bool
+}
+
+func (u *DeviceUART) Read(off uint64, n uint64) (uint64, error) {
+	if n != 1 {
+		return 0, ErrLoadAccessFault
+	}
+	u.receive()
+	var v uint8
+	switch off {
+	case UARTRBR:
+		if u.lcr&UARTLCRDLAB != 0 {
+			v = u.dll
+		} else if len(u.fifo) != 0 {
+			v = u.fifo[0]
+			u.fifo = u.fifo[1:]
+		}
+	case UARTIER:
+		if u.lcr&UARTLCRDLAB != 0 {
+			v = u.dlm
+		} else {
+			v = u.ier
+		}
+	case UARTIIR:
+		v = u.iir()
+		if v&0x0f == UARTIIRTHRE {
+			u.thre = false
+		}
+		if u.fcr&UARTFCRFIFO != 0 {
+			v |= UARTIIRFIFO
+		}
+	case UARTLCR:
+		v = u.lcr
+	case UARTMCR:
+		v = u.mcr
+	case UARTLSR:
+		v = UARTLSRTHRE | UARTLSRTEMT
+		if len(u.fifo) != 0 {
+			v |= UARTLSRDR
+		}
+	case UARTMSR:
+		// Clear to send, data set ready and carrier detect are always asserted.
+		v = 0xb0
+	case UARTSCR:
+		v = u.scr
+	}
+	u.update()
+	return uint64(v), nil
+}
+
+func (u *DeviceUART) Write(off uint64, n uint64, v uint64) error {
+	if n != 1 {
+		return ErrStoreAccessFault
+	}
+	u.receive()
+	b := uint8(v)
+	switch off {
+	case UARTRBR:
+		if u.lcr&UARTLCRDLAB != 0 {
+			u.dll = b
+			break
+		}
+		u.thre = true
+		if u.mcr&UARTMCRLoop != 0 {
+			u.push(b)
+			break
+		}
+		if u.Writer != nil {
+			if _, err := u.Writer.Write([]byte{b}); err != nil {
+				return err
+			}
+		}
+	case UARTIER:
+		if u.lcr&UARTLCRDLAB != 0 {
+			u.dlm = b
+			break
+		}
+		// Enabling the THRE interrupt while the transmitter is empty raises it at once.
+		if b&UARTIERTHRE != 0 && u.ier&UARTIERTHRE == 0 {
+			u.thre = true
+		}
+		u.ier = b & 0x0f
+	case UARTIIR:
+		u.fcr = b
+		if b&UARTFCRRX != 0 {
+			u.fifo = u.fifo[:0]
+		}
+	case UARTLCR:
+		u.lcr = b
+	case UARTMCR:
+		u.mcr = b & 0x1f
+	case UARTSCR:
+		u.scr = b
+	}
+	u.update()
+	return nil
+}
+
+// Update moves the bytes received so far to the FIFO and updates the interrupt line.
+func (u *DeviceUART) Update() {
+	u.receive()
+	u.update()
+}
+
+// Tick calls Update.
+func (u *DeviceUART) Tick(*CPU) {
+	u.Update()
+}
+
+// Pending reports the level of the interrupt line.
+func (u *DeviceUART) Pending() bool {
+	return u.level
+}
+
+// receive moves bytes from the reader to the FIFO, as long as there is room. In loopback mode the reader is
+// disconnected.
+func (u *DeviceUART) receive() {
+	for u.rx != nil && u.mcr&UARTMCRLoop == 0 && len(u.fifo) < uartFIFO {
+		select {
+		case b, ok := <-u.rx:
+			if !ok {
+				u.rx = nil
+				return
+			}
+			u.fifo = append(u.fifo, b)
+		default:
+			return
+		}
+	}
+}
+
+// push adds a byte to the FIFO. It is lost if the FIFO is full.
+func (u *DeviceUART) push(b byte) {
+	if len(u.fifo) < uartFIFO {
+		u.fifo = append(u.fifo, b)
+	}
+}
+
+// iir returns the identification of the pending interrupt with the highest priority.
+func (u *DeviceUART) iir() uint8 {
+	switch {
+	case u.ier&UARTIERRDA != 0 && len(u.fifo) != 0:
+		return UARTIIRRDA
+	case u.ier&UARTIERTHRE != 0 && u.thre:
+		return UARTIIRTHRE
+	}
+	return UARTIIRNone
+}
+
+// update calls IRQ if the level of the interrupt line changed.
+func (u *DeviceUART) update() {
+	level := u.iir() != UARTIIRNone
+	if level != u.level {
+		u.level = level
+		if u.IRQ != nil {
+			u.IRQ(level)
+		}
+	}
+}
+
+// NewDeviceUART returns a UART transmitting to w and receiving from r. Either may be nil.
+func NewDeviceUART(r io.Reader, w io.Writer) *DeviceUART {
+	u := &DeviceUART{Writer: w}
+	if r != nil {
+		u.rx = make(chan byte, 256)
+		go func() {
+			defer close(u.rx)
+			b := make([]byte, 256)
+			for {
+				n, err := r.Read(b)
+				for _, e := range b[:n] {
+					u.rx <- e
+				}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	}
+	return u
+}
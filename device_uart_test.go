@@ -0,0 +1,51 @@
+package rv64
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeviceUART(t *testing.T) {
+	w := &bytes.Buffer{}
+	u := NewDeviceUART(strings.NewReader("hi"), w)
+	irq := false
+	u.IRQ = func(level bool) { irq = level }
+	b := NewBus(NewLinear(0x1000))
+	b.Attach(0x100, UARTSize, u)
+	m := &Memory{Fasten: b}
+
+	for _, e := range []byte("ok\n") {
+		m.SetUint8(0x100+UARTRBR, e)
+	}
+	if w.String() != "ok\n" {
+		t.Fatal(w.String())
+	}
+	m.SetUint8(0x100+UARTIER, UARTIERRDA)
+	// Wait until the whole input is in the FIFO.
+	for i := 0; !irq || u.rx != nil; i++ {
+		if i == 1000 {
+			t.Fatal("no interrupt")
+		}
+		time.Sleep(time.Millisecond)
+		u.Update()
+	}
+	if v, _ := m.GetUint8(0x100 + UARTIIR); v != UARTIIRRDA {
+		t.Fatal(v)
+	}
+	r := []byte{}
+	for {
+		if v, _ := m.GetUint8(0x100 + UARTLSR); v&UARTLSRDR == 0 {
+			break
+		}
+		v, _ := m.GetUint8(0x100 + UARTRBR)
+		r = append(r, v)
+	}
+	if string(r) != "hi" || irq {
+		t.Fatal(string(r), irq)
+	}
+	if _, err := m.GetUint32(0x100); err == nil {
+		t.Fatal("word access")
+	}
+}
@@ -0,0 +1,304 @@
+package rv64
+
+import (
+	"debug/elf"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ELFDynBase is the default load address of position independent executables. It is the lowest address Linux lets a
+// program map, vm.mmap_min_addr.
+const ELFDynBase uint64 = 0x10000
+
+// elfChunk is the most bytes read from the file at once. The sizes in the file are not trusted, a corrupt one must not
+// exhaust the memory of the host.
+const elfChunk = 64 * 1024
+
+// elfInterpMax is the longest path of a program interpreter, PATH_MAX of Linux.
+const elfInterpMax = 4096
+
+// ELFOptions controls how LoadELF loads an image.
+type ELFOptions struct {
+	// Symbols requests the symbol table of the image to be read.
+	Symbols bool
+	// Bias is added to every address of an ET_DYN image. If it is zero and Heap is set, the image is placed in a
+	// mapping of Heap, the way Linux places the program interpreter. Otherwise the image is loaded at ELFDynBase.
+	Bias uint64
+	Heap *Heap
+}
+
+// ELFSegment is the memory range of a loaded PT_LOAD segment, with its permissions.
+type ELFSegment struct {
+	Addr  uint64
+	Size  uint64
+	Flags elf.ProgFlag
+}
+
+// ELF describes an image loaded by LoadELF.
+type ELF struct {
+	Entry uint64
+	// Bias is the difference between the addresses in memory and the addresses in the file. It is zero for ET_EXEC.
+	Bias uint64
+	// Interp is the path of the program interpreter named by PT_INTERP, empty for static executables.
+	Interp string
+	// Brk is the end of the highest loadable segment, where the program break starts.
+	Brk uint64
+	// Phdr is the address of the program headers in guest memory, or 0 if they are not loaded.
+	Phdr     uint64
+	Phent    uint64
+	Phnum    uint64
+	Segments []ELFSegment
+	Symbols  []elf.Symbol
+}
+
+// Auxv returns the auxiliary vector entries that describe the image.
+func (e *ELF) Auxv() []Auxv {
+	return []Auxv{
+		{LinuxAuxvPhdr, e.Phdr},
+		{LinuxAuxvPhent, e.Phent},
+		{LinuxAuxvPhnum, e.Phnum},
+		{LinuxAuxvEntry, e.Entry},
+	}
+}
+
+// Symbol returns the value of the symbol name.
+func (e *ELF) Symbol(name string) (uint64, bool) {
+	for _, s := range e.Symbols {
+		if s.Name == name {
+			return s.Value, true
+		}
+	}
+	return 0, false
+}
+
+// LoadELF checks that r is a RV64 little-endian executable or shared object, copies its loadable segments into the
+// memory of c and sets the PC to the entry point. The stack and the program break are left to the caller, and so is
+// the program interpreter of a dynamically linked executable, see ELF.Interp.
+func LoadELF(c *CPU, r io.ReaderAt, opts ELFOptions) (*ELF, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	if f.Class != elf.ELFCLASS64 {
+		return nil, fmt.Errorf("%w: class is %s", ErrELFClass, f.Class)
+	}
+	if f.Data != elf.ELFDATA2LSB {
+		return nil, fmt.Errorf("%w: data encoding is %s", ErrELFData, f.Data)
+	}
+	if f.Machine != elf.EM_RISCV {
+		return nil, fmt.Errorf("%w: machine is %s", ErrELFMachine, f.Machine)
+	}
+	if f.Type != elf.ET_EXEC && f.Type != elf.ET_DYN {
+		return nil, fmt.Errorf("%w: type is %s", ErrELFType, f.Type)
+	}
+	// Field e_phoff is not exposed by debug/elf. In ELF64 it is the 8 bytes at offset 0x20 of the file header.
+	b := make([]byte, 8)
+	if _, err := r.ReadAt(b, 0x20); err != nil {
+		return nil, err
+	}
+	phoff := f.ByteOrder.Uint64(b)
+	e := &ELF{
+		Phent: 56,
+		Phnum: uint64(len(f.Progs)),
+	}
+	if f.Type == elf.ET_DYN {
+		switch {
+		case opts.Bias != 0:
+			e.Bias = opts.Bias
+		case opts.Heap != nil:
+			if e.Bias, err = elfReserve(c, f, opts.Heap); err != nil {
+				return nil, err
+			}
+		default:
+			e.Bias = ELFDynBase - elfLowest(f)
+		}
+	}
+	e.Entry = f.Entry + e.Bias
+	for i, p := range f.Progs {
+		switch p.Type {
+		case elf.PT_INTERP:
+			if p.Filesz > elfInterpMax {
+				return nil, fmt.Errorf("%w: segment %d has a program interpreter of %#x bytes", ErrELFSegment, i, p.Filesz)
+			}
+			b := make([]byte, p.Filesz)
+			if _, err := p.ReadAt(b, 0); err != nil {
+				return nil, fmt.Errorf("%w: segment %d: %v", ErrELFSegment, i, err)
+			}
+			e.Interp = strings.TrimRight(string(b), "\x00")
+		case elf.PT_PHDR:
+			e.Phdr = p.Vaddr + e.Bias
+		case elf.PT_LOAD:
+			if p.Filesz > p.Memsz {
+				return nil, fmt.Errorf("%w: segment %d has p_filesz %#x larger than p_memsz %#x", ErrELFSegment, i, p.Filesz, p.Memsz)
+			}
+			vaddr := p.Vaddr + e.Bias
+			if vaddr+p.Memsz < vaddr {
+				return nil, fmt.Errorf("%w: segment %d wraps around the address space", ErrELFSegment, i)
+			}
+			// Segments are writable while they are loaded and relocated, they get their own permissions at the end.
+			if err := c.GetMemory().Protect(PageAlignDown(vaddr), PageAlignUp(vaddr+p.Memsz)-PageAlignDown(vaddr), PermR|PermW); err != nil {
+				return nil, fmt.Errorf("%w: segment %d at %#x-%#x: %v", ErrELFSegment, i, vaddr, vaddr+p.Memsz, err)
+			}
+			// The bytes from the file are mapped to the beginning of the memory segment. If the segment's memory size
+			// is larger than the file size, the extra bytes hold the value 0.
+			if err := elfCopy(c.GetMemory(), vaddr, p, p.Filesz); err != nil {
+				return nil, fmt.Errorf("%w: segment %d at %#x-%#x: %v", ErrELFSegment, i, vaddr, vaddr+p.Memsz, err)
+			}
+			if err := heapZero(c.GetMemory(), vaddr+p.Filesz, p.Memsz-p.Filesz); err != nil {
+				return nil, fmt.Errorf("%w: segment %d at %#x-%#x: %v", ErrELFSegment, i, vaddr, vaddr+p.Memsz, err)
+			}
+			if e.Phdr == 0 && p.Off <= phoff && phoff < p.Off+p.Filesz {
+				e.Phdr = vaddr + phoff - p.Off
+			}
+			if vaddr+p.Memsz > e.Brk {
+				e.Brk = vaddr + p.Memsz
+			}
+			e.Segments = append(e.Segments, ELFSegment{Addr: vaddr, Size: p.Memsz, Flags: p.Flags})
+		}
+	}
+	if len(e.Segments) == 0 {
+		return nil, fmt.Errorf("%w: no loadable segment", ErrELFSegment)
+	}
+	// A dynamically linked image is relocated by its interpreter. A static-pie has nobody to do it for it.
+	if f.Type == elf.ET_DYN && e.Interp == "" {
+		if err := elfRelocate(c, f, e.Bias); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Symbols {
+		e.Symbols, err = f.Symbols()
+		if err != nil && err != elf.ErrNoSymbols {
+			return nil, err
+		}
+		for i := range e.Symbols {
+			if e.Symbols[i].Section != elf.SHN_UNDEF && e.Symbols[i].Section != elf.SHN_ABS {
+				e.Symbols[i].Value += e.Bias
+			}
+		}
+	}
+	for _, g := range e.Segments {
+		if err := c.GetMemory().Protect(PageAlignDown(g.Addr), PageAlignUp(g.Addr+g.Size)-PageAlignDown(g.Addr), elfPerm(g.Flags)); err != nil {
+			return nil, err
+		}
+	}
+	c.SetPC(e.Entry)
+	return e, nil
+}
+
+// elfCopy copies the first size bytes of r to memory at a, in chunks.
+func elfCopy(m *Memory, a uint64, r io.ReaderAt, size uint64) error {
+	b := make([]byte, elfChunk)
+	for off := uint64(0); off < size; off += elfChunk {
+		n := size - off
+		if n > elfChunk {
+			n = elfChunk
+		}
+		if _, err := r.ReadAt(b[:n], int64(off)); err != nil {
+			return err
+		}
+		if err := m.SetByte(a+off, b[:n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// elfPerm converts the p_flags of a segment to memory permissions. Write permission implies read permission.
+func elfPerm(f elf.ProgFlag) uint8 {
+	var r uint8
+	if f&(elf.PF_R|elf.PF_W) != 0 {
+		r |= PermR
+	}
+	if f&elf.PF_W != 0 {
+		r |= PermW
+	}
+	if f&elf.PF_X != 0 {
+		r |= PermX
+	}
+	return r
+}
+
+// elfLowest returns the page aligned lowest address of the loadable segments of f.
+func elfLowest(f *elf.File) uint64 {
+	lo := ^uint64(0)
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_LOAD && p.Vaddr < lo {
+			lo = p.Vaddr
+		}
+	}
+	return PageAlignDown(lo)
+}
+
+// elfReserve maps the address range spanned by the loadable segments of f in h, and returns the bias that moves the
+// image into the mapping.
+func elfReserve(c *CPU, f *elf.File, h *Heap) (uint64, error) {
+	lo := elfLowest(f)
+	hi := uint64(0)
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_LOAD && p.Vaddr+p.Memsz > hi {
+			hi = p.Vaddr + p.Memsz
+		}
+	}
+	if hi <= lo {
+		return 0, fmt.Errorf("%w: no loadable segment", ErrELFSegment)
+	}
+	a, e := h.Mmap(c.GetMemory(), 0, hi-lo, false)
+	if e != 0 {
+		return 0, fmt.Errorf("%w: no room for %#x bytes", ErrOutOfMemory, hi-lo)
+	}
+	return a - lo, nil
+}
+
+// elfRelocate applies the R_RISCV_RELATIVE relocations of the loaded image f. The table is found through DT_RELA and
+// DT_RELASZ of the dynamic section. Other relocation types need symbols, they are left to the startup code.
+func elfRelocate(c *CPU, f *elf.File, bias uint64) error {
+	var rela, relasz, relaent uint64 = 0, 0, 24
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_DYNAMIC {
+			continue
+		}
+		// Elf64_Dyn { Elf64_Sxword d_tag; Elf64_Xword d_val; }, the section is read entry by entry up to DT_NULL.
+		b := make([]byte, 16)
+		for i := uint64(0); i+16 <= p.Filesz; i += 16 {
+			if _, err := p.ReadAt(b, int64(i)); err != nil {
+				return fmt.Errorf("%w: dynamic section: %v", ErrELFSegment, err)
+			}
+			v := f.ByteOrder.Uint64(b[8:])
+			tag := elf.DynTag(f.ByteOrder.Uint64(b[0:]))
+			if tag == elf.DT_NULL {
+				break
+			}
+			switch tag {
+			case elf.DT_RELA:
+				rela = v
+			case elf.DT_RELASZ:
+				relasz = v
+			case elf.DT_RELAENT:
+				relaent = v
+			}
+		}
+	}
+	if rela == 0 || relasz == 0 {
+		return nil
+	}
+	if relaent < 24 {
+		return fmt.Errorf("%w: DT_RELAENT is %d", ErrELFSegment, relaent)
+	}
+	// Elf64_Rela { Elf64_Addr r_offset; Elf64_Xword r_info; Elf64_Sxword r_addend; }
+	mem := c.GetMemory()
+	for a := rela + bias; a < rela+bias+relasz; a += relaent {
+		b, err := mem.GetByte(a, 24)
+		if err != nil {
+			return fmt.Errorf("%w: relocation at %#x: %v", ErrELFSegment, a, err)
+		}
+		if elf.R_RISCV(f.ByteOrder.Uint64(b[8:])&0xffffffff) != elf.R_RISCV_RELATIVE {
+			continue
+		}
+		off := f.ByteOrder.Uint64(b[0:])
+		if err := mem.SetUint64(off+bias, bias+f.ByteOrder.Uint64(b[16:])); err != nil {
+			return fmt.Errorf("%w: relocation of %#x: %v", ErrELFSegment, off, err)
+		}
+	}
+	return nil
+}
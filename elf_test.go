@@ -0,0 +1,180 @@
+package rv64
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// testELF returns an executable with a single PT_LOAD segment at vaddr, which holds the file header, the program
+// header and code, followed by bss bytes of zero.
+func testELF(machine elf.Machine, vaddr uint64, code []byte, bss uint64) []byte {
+	b := make([]byte, 64+56)
+	copy(b, []byte{0x7f, 'E', 'L', 'F', byte(elf.ELFCLASS64), byte(elf.ELFDATA2LSB), byte(elf.EV_CURRENT)})
+	binary.LittleEndian.PutUint16(b[16:], uint16(elf.ET_EXEC))
+	binary.LittleEndian.PutUint16(b[18:], uint16(machine))
+	binary.LittleEndian.PutUint32(b[20:], uint32(elf.EV_CURRENT))
+	binary.LittleEndian.PutUint64(b[24:], vaddr+120)
+	binary.LittleEndian.PutUint64(b[32:], 64)
+	binary.LittleEndian.PutUint16(b[52:], 64)
+	binary.LittleEndian.PutUint16(b[54:], 56)
+	binary.LittleEndian.PutUint16(b[56:], 1)
+	p := b[64:]
+	binary.LittleEndian.PutUint32(p[0:], uint32(elf.PT_LOAD))
+	binary.LittleEndian.PutUint32(p[4:], uint32(elf.PF_R|elf.PF_X))
+	binary.LittleEndian.PutUint64(p[16:], vaddr)
+	binary.LittleEndian.PutUint64(p[24:], vaddr)
+	binary.LittleEndian.PutUint64(p[32:], uint64(120+len(code)))
+	binary.LittleEndian.PutUint64(p[40:], uint64(120+len(code))+bss)
+	binary.LittleEndian.PutUint64(p[48:], PageSize)
+	return append(b, code...)
+}
+
+func TestLoadELF(t *testing.T) {
+	c := NewCPU()
+	c.SetFasten(NewLinear(0x20000))
+	c.GetMemory().SetByte(0x10000, bytes.Repeat([]byte{0xff}, 0x1000))
+	img, err := LoadELF(c, bytes.NewReader(testELF(elf.EM_RISCV, 0x10000, []byte{0x73, 0x00, 0x00, 0x00}, 16)), ELFOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Entry != 0x10078 || c.GetPC() != img.Entry || img.Phdr != 0x10040 || img.Brk != 0x1008c {
+		t.Fatalf("%#x %#x %#x", img.Entry, img.Phdr, img.Brk)
+	}
+	if len(img.Segments) != 1 || img.Segments[0].Flags != elf.PF_R|elf.PF_X {
+		t.Fatal(img.Segments)
+	}
+	if b, _ := c.GetMemory().GetByte(0x1007c, 16); !bytes.Equal(b, make([]byte, 16)) {
+		t.Fatal("bss is not zeroed", b)
+	}
+
+	if _, err := LoadELF(c, bytes.NewReader(testELF(elf.EM_X86_64, 0x10000, nil, 0)), ELFOptions{}); !errors.Is(err, ErrELFMachine) {
+		t.Fatal(err)
+	}
+	if _, err := LoadELF(c, bytes.NewReader(testELF(elf.EM_RISCV, 0x40000, nil, 0)), ELFOptions{}); !errors.Is(err, ErrELFSegment) {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadELFSize(t *testing.T) {
+	// The sizes in the file are not trusted: a huge bss does not fit the memory and a huge p_filesz is past the end of
+	// the file.
+	c := NewCPU()
+	c.SetFasten(NewLinear(0x20000))
+	if _, err := LoadELF(c, bytes.NewReader(testELF(elf.EM_RISCV, 0x10000, nil, 1<<40)), ELFOptions{}); !errors.Is(err, ErrELFSegment) {
+		t.Fatal(err)
+	}
+	b := testELF(elf.EM_RISCV, 0x10000, nil, 0)
+	binary.LittleEndian.PutUint64(b[64+32:], 1<<40)
+	binary.LittleEndian.PutUint64(b[64+40:], 1<<40)
+	if _, err := LoadELF(c, bytes.NewReader(b), ELFOptions{}); !errors.Is(err, ErrELFSegment) {
+		t.Fatal(err)
+	}
+	// A sparse memory holds the bss without allocating it.
+	m := NewPaged(0)
+	c.SetFasten(m)
+	if _, err := LoadELF(c, bytes.NewReader(testELF(elf.EM_RISCV, 0x10000, nil, 1<<40)), ELFOptions{}); err != nil || m.Resident() != PageSize {
+		t.Fatal(err, m.Resident())
+	}
+}
+
+func TestLoadELFRelocate(t *testing.T) {
+	// A static-pie linked at 0: file header, PT_LOAD and PT_DYNAMIC headers, the dynamic section, one relocation
+	// entry and the 8-byte slot it patches.
+	const dyn, rela, slot = 0xb0, 0xe0, 0xf8
+	b := testELF(elf.EM_RISCV, 0, make([]byte, slot+8-120), 0)
+	binary.LittleEndian.PutUint16(b[16:], uint16(elf.ET_DYN))
+	binary.LittleEndian.PutUint16(b[56:], 2)
+	p := b[120:]
+	binary.LittleEndian.PutUint32(p[0:], uint32(elf.PT_DYNAMIC))
+	binary.LittleEndian.PutUint64(p[8:], dyn)
+	binary.LittleEndian.PutUint64(p[16:], dyn)
+	binary.LittleEndian.PutUint64(p[32:], 48)
+	binary.LittleEndian.PutUint64(p[40:], 48)
+	for i, e := range []uint64{uint64(elf.DT_RELA), rela, uint64(elf.DT_RELASZ), 24, uint64(elf.DT_NULL), 0} {
+		binary.LittleEndian.PutUint64(b[dyn+i*8:], e)
+	}
+	binary.LittleEndian.PutUint64(b[rela:], slot)
+	binary.LittleEndian.PutUint64(b[rela+8:], uint64(elf.R_RISCV_RELATIVE))
+	binary.LittleEndian.PutUint64(b[rela+16:], 0x1234)
+
+	c := NewCPU()
+	c.SetFasten(NewLinear(0x20000))
+	img, err := LoadELF(c, bytes.NewReader(b), ELFOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Bias != ELFDynBase || img.Entry != ELFDynBase+120 {
+		t.Fatalf("%#x %#x", img.Bias, img.Entry)
+	}
+	if v, _ := c.GetMemory().GetUint64(ELFDynBase + slot); v != ELFDynBase+0x1234 {
+		t.Fatalf("%#x", v)
+	}
+}
+
+// testAuxv reads the auxiliary vector from the initial stack of the process at sp.
+func testAuxv(c *CPU) map[uint64]uint64 {
+	a := c.GetRegister(Rsp)
+	argc, _ := c.GetMemory().GetUint64(a)
+	a += (argc + 2) * 8
+	for v, _ := c.GetMemory().GetUint64(a); v != 0; v, _ = c.GetMemory().GetUint64(a) {
+		a += 8
+	}
+	r := map[uint64]uint64{}
+	for a += 8; ; a += 16 {
+		k, _ := c.GetMemory().GetUint64(a)
+		v, _ := c.GetMemory().GetUint64(a + 8)
+		if k == LinuxAuxvNull {
+			return r
+		}
+		r[k] = v
+	}
+}
+
+func TestLoadELFInterp(t *testing.T) {
+	// The program has a PT_INTERP segment after its PT_LOAD, the interpreter is position independent.
+	const interp = 120 + 56
+	b := testELF(elf.EM_RISCV, 0x10000, make([]byte, 56+16), 0)
+	binary.LittleEndian.PutUint16(b[56:], 2)
+	p := b[120:]
+	binary.LittleEndian.PutUint32(p[0:], uint32(elf.PT_INTERP))
+	binary.LittleEndian.PutUint64(p[8:], interp)
+	binary.LittleEndian.PutUint64(p[16:], 0x10000+interp)
+	binary.LittleEndian.PutUint64(p[32:], 16)
+	binary.LittleEndian.PutUint64(p[40:], 16)
+	copy(b[interp:], "/lib/ld.so")
+	ld := testELF(elf.EM_RISCV, 0, []byte{0x73, 0x00, 0x00, 0x00}, 0)
+	binary.LittleEndian.PutUint16(ld[16:], uint16(elf.ET_DYN))
+
+	c := NewCPU()
+	c.SetFasten(NewPaged(0))
+	img, err := LoadELF(c, bytes.NewReader(b), ELFOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if img.Interp != "/lib/ld.so" {
+		t.Fatalf("%q", img.Interp)
+	}
+	// The interpreter is mapped like a shared library, below the stack, and starts first.
+	h := NewHeap(img.Brk, 0x40000000)
+	i, err := LoadELF(c, bytes.NewReader(ld), ELFOptions{Heap: h})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if i.Bias != 0x3ffff000 || c.GetPC() != 0x3ffff078 {
+		t.Fatalf("%#x %#x", i.Bias, c.GetPC())
+	}
+	proc := NewProcess([]string{"prog"}, nil)
+	proc.Auxv = append(proc.Auxv, img.Auxv()...)
+	proc.Auxv = append(proc.Auxv, Auxv{Type: LinuxAuxvBase, Value: i.Bias})
+	c.SetRegister(Rsp, 0x7ffff000)
+	if err := proc.Push(c); err != nil {
+		t.Fatal(err)
+	}
+	auxv := testAuxv(c)
+	if auxv[LinuxAuxvBase] != 0x3ffff000 || auxv[LinuxAuxvPhdr] != 0x10040 || auxv[LinuxAuxvEntry] != 0x10078 || auxv[LinuxAuxvPhnum] != 2 {
+		t.Fatal(auxv)
+	}
+}
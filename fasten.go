@@ -6,3 +6,21 @@ type Fasten interface {
 	Set(uint64, byte) error
 	Len() uint64
 }
+
+// FastenBulk is implemented by a Fasten that can read and write a range of bytes at once. GetBytes fills b with the
+// bytes at a. If an error is returned the content of b, or of the memory for SetBytes, is undefined.
+type FastenBulk interface {
+	GetBytes(a uint64, b []byte) error
+	SetBytes(a uint64, b []byte) error
+}
+
+// FastenWord is implemented by a Fasten with native little-endian accessors for 16, 32 and 64-bit words. The
+// addresses are not necessarily aligned.
+type FastenWord interface {
+	GetUint16(a uint64) (uint16, error)
+	GetUint32(a uint64) (uint32, error)
+	GetUint64(a uint64) (uint64, error)
+	SetUint16(a uint64, n uint16) error
+	SetUint32(a uint64, n uint32) error
+	SetUint64(a uint64, n uint64) error
+}
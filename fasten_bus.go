@@ -0,0 +1,220 @@
+package rv64
+
+import (
+	"fmt"
+	"sort"
+)
+
+// BusMapping is a device attached to a Bus at [Addr, Addr+Size).
+type BusMapping struct {
+	Addr   uint64
+	Size   uint64
+	Device Device
+}
+
+// Bus routes the accesses to memory mapped devices, and everything else to the wrapped Fasten, the RAM. A word access
+// reaches a device as a single access of its width, while bulk accesses are split into bytes. An access overlapping a
+// device only in part is an access fault, and so are instruction fetches from devices other than memories, see
+// DeviceFetch.
+type Bus struct {
+	Fasten
+	mappings []BusMapping
+}
+
+// Attach maps d at [addr, addr+size). It fails with ErrDeviceOverlap if the range is used by another device.
+func (b *Bus) Attach(addr uint64, size uint64, d Device) error {
+	if size == 0 || addr+size < addr {
+		return fmt.Errorf("%w: empty or wrapping range %#x+%#x", ErrDeviceOverlap, addr, size)
+	}
+	for _, e := range b.mappings {
+		if addr < e.Addr+e.Size && e.Addr < addr+size {
+			return fmt.Errorf("%w: %#x-%#x and %#x-%#x", ErrDeviceOverlap, addr, addr+size, e.Addr, e.Addr+e.Size)
+		}
+	}
+	b.mappings = append(b.mappings, BusMapping{Addr: addr, Size: size, Device: d})
+	sort.Slice(b.mappings, func(i, j int) bool { return b.mappings[i].Addr < b.mappings[j].Addr })
+	return nil
+}
+
+// Detach removes the device mapped at addr. It reports whether there was one.
+func (b *Bus) Detach(addr uint64) bool {
+	for i, e := range b.mappings {
+		if e.Addr == addr {
+			b.mappings = append(b.mappings[:i], b.mappings[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Mappings returns the attached devices sorted by address.
+func (b *Bus) Mappings() []BusMapping {
+	return append([]BusMapping{}, b.mappings...)
+}
+
+// find returns the first mapping overlapping [a, a+n), or nil if the range is RAM only.
+func (b *Bus) find(a uint64, n uint64) *BusMapping {
+	if len(b.mappings) == 0 {
+		return nil
+	}
+	i := sort.Search(len(b.mappings), func(i int) bool { return b.mappings[i].Addr+b.mappings[i].Size > a })
+	if i == len(b.mappings) || b.mappings[i].Addr > a && b.mappings[i].Addr-a >= n {
+		return nil
+	}
+	return &b.mappings[i]
+}
+
+// read accesses the n bytes at a. It returns ok false if they are RAM.
+func (b *Bus) read(a uint64, n uint64) (uint64, bool, error) {
+	m := b.find(a, n)
+	if m == nil {
+		return 0, false, nil
+	}
+	if a < m.Addr || m.Addr+m.Size-a < n {
+		return 0, true, &AccessFault{Err: ErrLoadAccessFault, Addr: a}
+	}
+	v, err := m.Device.Read(a-m.Addr, n)
+	return v, true, busFault(err, a)
+}
+
+// write accesses the n bytes at a. It returns ok false if they are RAM.
+func (b *Bus) write(a uint64, n uint64, v uint64) (bool, error) {
+	m := b.find(a, n)
+	if m == nil {
+		return false, nil
+	}
+	if a < m.Addr || m.Addr+m.Size-a < n {
+		return true, &AccessFault{Err: ErrStoreAccessFault, Addr: a}
+	}
+	return true, busFault(m.Device.Write(a-m.Addr, n, v), a)
+}
+
+// ram returns the memory behind the devices.
+func (b *Bus) ram() *Memory {
+	return &Memory{Fasten: b.Fasten}
+}
+
+// busFault adds the address to the access faults returned by devices.
+func busFault(err error, a uint64) error {
+	if err == ErrLoadAccessFault || err == ErrStoreAccessFault || err == ErrInstructionAccessFault {
+		return &AccessFault{Err: err, Addr: a}
+	}
+	return err
+}
+
+func (b *Bus) Get(a uint64) (byte, error) {
+	if v, ok, err := b.read(a, 1); ok {
+		return byte(v), err
+	}
+	return b.Fasten.Get(a)
+}
+
+func (b *Bus) Set(a uint64, v byte) error {
+	if ok, err := b.write(a, 1, uint64(v)); ok {
+		return err
+	}
+	return b.Fasten.Set(a, v)
+}
+
+func (b *Bus) GetBytes(a uint64, p []byte) error {
+	if b.find(a, uint64(len(p))) == nil {
+		return b.ram().get(a, p)
+	}
+	for i := range p {
+		e, err := b.Get(a + uint64(i))
+		if err != nil {
+			return err
+		}
+		p[i] = e
+	}
+	return nil
+}
+
+func (b *Bus) SetBytes(a uint64, p []byte) error {
+	if b.find(a, uint64(len(p))) == nil {
+		return b.ram().SetByte(a, p)
+	}
+	for i, e := range p {
+		if err := b.Set(a+uint64(i), e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fetch reads the bytes of an instruction. Code can run from RAM and from the devices implementing DeviceFetch.
+func (b *Bus) Fetch(a uint64, p []byte) error {
+	if m := b.find(a, uint64(len(p))); m != nil {
+		f, ok := m.Device.(DeviceFetch)
+		if !ok || a < m.Addr || m.Addr+m.Size-a < uint64(len(p)) {
+			return &AccessFault{Err: ErrInstructionAccessFault, Addr: a}
+		}
+		return busFault(f.Fetch(a-m.Addr, p), a)
+	}
+	if f, ok := b.Fasten.(fastenFetch); ok {
+		return f.Fetch(a, p)
+	}
+	return b.ram().get(a, p)
+}
+
+func (b *Bus) GetUint16(a uint64) (uint16, error) {
+	if v, ok, err := b.read(a, 2); ok {
+		return uint16(v), err
+	}
+	return b.ram().GetUint16(a)
+}
+
+func (b *Bus) GetUint32(a uint64) (uint32, error) {
+	if v, ok, err := b.read(a, 4); ok {
+		return uint32(v), err
+	}
+	return b.ram().GetUint32(a)
+}
+
+func (b *Bus) GetUint64(a uint64) (uint64, error) {
+	if v, ok, err := b.read(a, 8); ok {
+		return v, err
+	}
+	return b.ram().GetUint64(a)
+}
+
+func (b *Bus) SetUint16(a uint64, n uint16) error {
+	if ok, err := b.write(a, 2, uint64(n)); ok {
+		return err
+	}
+	return b.ram().SetUint16(a, n)
+}
+
+func (b *Bus) SetUint32(a uint64, n uint32) error {
+	if ok, err := b.write(a, 4, uint64(n)); ok {
+		return err
+	}
+	return b.ram().SetUint32(a, n)
+}
+
+func (b *Bus) SetUint64(a uint64, n uint64) error {
+	if ok, err := b.write(a, 8, n); ok {
+		return err
+	}
+	return b.ram().SetUint64(a, n)
+}
+
+// Protect forwards to the RAM, see Protected. Devices have no permissions.
+func (b *Bus) Protect(a uint64, size uint64, perm uint8) error {
+	return b.ram().Protect(a, size, perm)
+}
+
+// Regions forwards to the RAM, see Memory.Regions.
+func (b *Bus) Regions() ([]Region, bool) {
+	return b.ram().Regions()
+}
+
+// Release forwards to the RAM, see Paged.Release.
+func (b *Bus) Release(a uint64, size uint64) bool {
+	return b.ram().Release(a, size)
+}
+
+// NewBus returns a bus with no device, all accesses go to ram.
+func NewBus(ram Fasten) *Bus {
+	return &Bus{Fasten: ram}
+}
@@ -0,0 +1,77 @@
+package rv64
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestBus(t *testing.T) {
+	b := NewBus(NewLinear(0x10000))
+	m := &Memory{Fasten: b}
+	regs := [4]uint64{}
+	if err := b.Attach(0x1000, 0x20, NewDeviceMMIO(4, func(off uint64) uint64 {
+		return regs[off/4] + 1
+	}, func(off uint64, v uint64) {
+		regs[off/4] = v
+	})); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Attach(0x2000, 4, NewDeviceROM([]byte{0x13, 0x00, 0x00, 0x00})); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Attach(0x101c, 8, NewDeviceRAM(8)); !errors.Is(err, ErrDeviceOverlap) {
+		t.Fatal(err)
+	}
+
+	if err := m.SetUint32(0x1008, 0x41); err != nil || regs[2] != 0x41 {
+		t.Fatal(err, regs)
+	}
+	if v, err := m.GetUint32(0x1008); err != nil || v != 0x42 {
+		t.Fatal(err, v)
+	}
+	var f *AccessFault
+	if _, err := m.GetUint8(0x1008); !errors.As(err, &f) || f.Err != ErrLoadAccessFault || f.Addr != 0x1008 {
+		t.Fatal(err)
+	}
+	if err := m.SetUint64(0x0ffc, 0); !errors.Is(err, ErrStoreAccessFault) {
+		t.Fatal(err)
+	}
+	if v, err := m.GetUint32(0x2000); err != nil || v != 0x13 {
+		t.Fatal(err, v)
+	}
+	if err := m.SetUint8(0x2000, 0); !errors.Is(err, ErrStoreAccessFault) {
+		t.Fatal(err)
+	}
+	// Code runs from the ROM, not from the registers.
+	if b, err := m.Fetch(0x2000, 4); err != nil || b[0] != 0x13 {
+		t.Fatal(err, b)
+	}
+	if _, err := m.Fetch(0x1000, 4); !errors.Is(err, ErrInstructionAccessFault) {
+		t.Fatal(err)
+	}
+	if _, err := m.Fetch(0x2002, 4); !errors.As(err, &f) || f.Err != ErrInstructionAccessFault || f.Addr != 0x2002 {
+		t.Fatal(err)
+	}
+	if err := m.SetUint64(0x3000, 0x1122334455667788); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := m.GetUint64(0x3000); err != nil || v != 0x1122334455667788 {
+		t.Fatal(err, v)
+	}
+}
+
+func TestBusRegions(t *testing.T) {
+	m := &Memory{Fasten: NewBus(NewLinear(0x10000))}
+	if _, ok := m.Regions(); ok {
+		t.Fatal("regions of a RAM without permissions")
+	}
+	p := NewProtected(NewPaged(0))
+	m = &Memory{Fasten: NewBus(p)}
+	if err := m.Protect(0x1000, 0x1000, PermR); err != nil {
+		t.Fatal(err)
+	}
+	r, ok := m.Regions()
+	if !ok || len(r) != 1 || r[0] != (Region{Addr: 0x1000, Size: 0x1000, Perm: PermR}) {
+		t.Fatal(r, ok)
+	}
+}
@@ -1,5 +1,9 @@
 package rv64
 
+import (
+	"encoding/binary"
+)
+
 // Linear is a very simple memory implementation that maps data completely into a byte array
 type Linear struct {
 	data []byte
@@ -24,6 +28,83 @@ func (l *Linear) Len() uint64 {
 	return uint64(len(l.data))
 }
 
+// slice returns the n bytes at a.
+func (l *Linear) slice(a uint64, n uint64) ([]byte, error) {
+	if a > l.Len() || l.Len()-a < n {
+		return nil, ErrOutOfMemory
+	}
+	return l.data[a : a+n], nil
+}
+
+func (l *Linear) GetBytes(a uint64, b []byte) error {
+	s, err := l.slice(a, uint64(len(b)))
+	if err != nil {
+		return err
+	}
+	copy(b, s)
+	return nil
+}
+
+func (l *Linear) SetBytes(a uint64, b []byte) error {
+	s, err := l.slice(a, uint64(len(b)))
+	if err != nil {
+		return err
+	}
+	copy(s, b)
+	return nil
+}
+
+func (l *Linear) GetUint16(a uint64) (uint16, error) {
+	s, err := l.slice(a, 2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(s), nil
+}
+
+func (l *Linear) GetUint32(a uint64) (uint32, error) {
+	s, err := l.slice(a, 4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(s), nil
+}
+
+func (l *Linear) GetUint64(a uint64) (uint64, error) {
+	s, err := l.slice(a, 8)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(s), nil
+}
+
+func (l *Linear) SetUint16(a uint64, n uint16) error {
+	s, err := l.slice(a, 2)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint16(s, n)
+	return nil
+}
+
+func (l *Linear) SetUint32(a uint64, n uint32) error {
+	s, err := l.slice(a, 4)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(s, n)
+	return nil
+}
+
+func (l *Linear) SetUint64(a uint64, n uint64) error {
+	s, err := l.slice(a, 8)
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint64(s, n)
+	return nil
+}
+
 func NewLinear(n uint64) Fasten {
 	return &Linear{
 		data: make([]byte, n),
@@ -0,0 +1,236 @@
+package rv64
+
+import (
+	"encoding/binary"
+)
+
+// Paged is a sparse memory implementation covering the whole 64-bit address space. Memory is split into pages of
+// PageSize bytes which are allocated on the first write of a non-zero byte, untouched memory reads as zero. Limit caps
+// the resident size, the memory held by allocated pages. Zero means no limit. The zero value is an empty memory ready
+// to use.
+type Paged struct {
+	Limit uint64
+	pages map[uint64]*[PageSize]byte
+	peak  uint64
+	// The page accessed last, most accesses hit it.
+	lastAddr uint64
+	lastPage *[PageSize]byte
+}
+
+// page returns the page holding address a. If the page is not resident it is allocated when alloc is set, otherwise
+// nil is returned.
+func (p *Paged) page(a uint64, alloc bool) (*[PageSize]byte, error) {
+	n := PageAlignDown(a)
+	if p.lastPage != nil && p.lastAddr == n {
+		return p.lastPage, nil
+	}
+	e, ok := p.pages[n]
+	if !ok {
+		if !alloc {
+			return nil, nil
+		}
+		if p.Limit != 0 && p.Resident() >= p.Limit {
+			return nil, ErrOutOfMemory
+		}
+		if p.pages == nil {
+			p.pages = map[uint64]*[PageSize]byte{}
+		}
+		e = &[PageSize]byte{}
+		p.pages[n] = e
+		if p.Resident() > p.peak {
+			p.peak = p.Resident()
+		}
+	}
+	p.lastAddr = n
+	p.lastPage = e
+	return e, nil
+}
+
+func (p *Paged) Get(a uint64) (byte, error) {
+	e, _ := p.page(a, false)
+	if e == nil {
+		return 0x00, nil
+	}
+	return e[a&(PageSize-1)], nil
+}
+
+func (p *Paged) Set(a uint64, v byte) error {
+	// Writing zero to a page that is not resident changes nothing, so zero filled memory costs no pages.
+	e, err := p.page(a, v != 0x00)
+	if err != nil || e == nil {
+		return err
+	}
+	e[a&(PageSize-1)] = v
+	return nil
+}
+
+func (p *Paged) GetBytes(a uint64, b []byte) error {
+	for len(b) != 0 {
+		o := a & (PageSize - 1)
+		n := PageSize - o
+		if n > uint64(len(b)) {
+			n = uint64(len(b))
+		}
+		e, _ := p.page(a, false)
+		if e == nil {
+			for i := range b[:n] {
+				b[i] = 0x00
+			}
+		} else {
+			copy(b[:n], e[o:])
+		}
+		a += n
+		b = b[n:]
+	}
+	return nil
+}
+
+func (p *Paged) SetBytes(a uint64, b []byte) error {
+	for len(b) != 0 {
+		o := a & (PageSize - 1)
+		n := PageSize - o
+		if n > uint64(len(b)) {
+			n = uint64(len(b))
+		}
+		e, err := p.page(a, !pagedZero(b[:n]))
+		if err != nil {
+			return err
+		}
+		if e != nil {
+			copy(e[o:], b[:n])
+		}
+		a += n
+		b = b[n:]
+	}
+	return nil
+}
+
+// word returns the n bytes at a if they are resident and inside one page. Otherwise it returns nil, and the word is
+// accessed byte by byte.
+func (p *Paged) word(a uint64, n uint64, alloc bool) ([]byte, error) {
+	o := a & (PageSize - 1)
+	if o > PageSize-n {
+		return nil, nil
+	}
+	e, err := p.page(a, alloc)
+	if err != nil || e == nil {
+		return nil, err
+	}
+	return e[o : o+n], nil
+}
+
+func (p *Paged) GetUint16(a uint64) (uint16, error) {
+	b, _ := p.word(a, 2, false)
+	if b == nil {
+		b = make([]byte, 2)
+		p.GetBytes(a, b)
+	}
+	return binary.LittleEndian.Uint16(b), nil
+}
+
+func (p *Paged) GetUint32(a uint64) (uint32, error) {
+	b, _ := p.word(a, 4, false)
+	if b == nil {
+		b = make([]byte, 4)
+		p.GetBytes(a, b)
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+func (p *Paged) GetUint64(a uint64) (uint64, error) {
+	b, _ := p.word(a, 8, false)
+	if b == nil {
+		b = make([]byte, 8)
+		p.GetBytes(a, b)
+	}
+	return binary.LittleEndian.Uint64(b), nil
+}
+
+func (p *Paged) SetUint16(a uint64, n uint16) error {
+	b, err := p.word(a, 2, n != 0)
+	if err != nil || b == nil {
+		b = make([]byte, 2)
+		binary.LittleEndian.PutUint16(b, n)
+		return p.SetBytes(a, b)
+	}
+	binary.LittleEndian.PutUint16(b, n)
+	return nil
+}
+
+func (p *Paged) SetUint32(a uint64, n uint32) error {
+	b, err := p.word(a, 4, n != 0)
+	if err != nil || b == nil {
+		b = make([]byte, 4)
+		binary.LittleEndian.PutUint32(b, n)
+		return p.SetBytes(a, b)
+	}
+	binary.LittleEndian.PutUint32(b, n)
+	return nil
+}
+
+func (p *Paged) SetUint64(a uint64, n uint64) error {
+	b, err := p.word(a, 8, n != 0)
+	if err != nil || b == nil {
+		b = make([]byte, 8)
+		binary.LittleEndian.PutUint64(b, n)
+		return p.SetBytes(a, b)
+	}
+	binary.LittleEndian.PutUint64(b, n)
+	return nil
+}
+
+// Len returns the size of the address space. It is 1<<64, which does not fit and is reported as the highest address.
+func (p *Paged) Len() uint64 {
+	return ^uint64(0)
+}
+
+// Release drops the resident pages entirely inside [a, a+size). Their memory reads as zero again. It always returns
+// true.
+func (p *Paged) Release(a uint64, size uint64) bool {
+	lo := PageAlignUp(a)
+	hi := PageAlignDown(a + size)
+	if hi <= lo {
+		return true
+	}
+	if uint64(len(p.pages)) < (hi-lo)/PageSize {
+		for n := range p.pages {
+			if n >= lo && n < hi {
+				delete(p.pages, n)
+			}
+		}
+	} else {
+		for n := lo; n < hi; n += PageSize {
+			delete(p.pages, n)
+		}
+	}
+	p.lastPage = nil
+	return true
+}
+
+// Resident returns the number of bytes held by allocated pages.
+func (p *Paged) Resident() uint64 {
+	return uint64(len(p.pages)) * PageSize
+}
+
+// Peak returns the highest resident size seen so far.
+func (p *Paged) Peak() uint64 {
+	return p.peak
+}
+
+// NewPaged returns an empty memory whose resident size never exceeds limit bytes.
+func NewPaged(limit uint64) *Paged {
+	return &Paged{
+		Limit: limit,
+		pages: map[uint64]*[PageSize]byte{},
+	}
+}
+
+// pagedZero reports whether all bytes of b are zero.
+func pagedZero(b []byte) bool {
+	for _, e := range b {
+		if e != 0x00 {
+			return false
+		}
+	}
+	return true
+}
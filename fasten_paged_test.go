@@ -0,0 +1,42 @@
+package rv64
+
+import (
+	"testing"
+)
+
+func TestPaged(t *testing.T) {
+	p := NewPaged(2 * PageSize)
+	if err := p.Set(0x3ffffff000, 0x00); err != nil || p.Resident() != 0 {
+		t.Fatal(err, p.Resident())
+	}
+	if err := p.Set(0x3ffffff001, 0x2a); err != nil || p.Resident() != PageSize {
+		t.Fatal(err, p.Resident())
+	}
+	if b, _ := p.Get(0x3ffffff001); b != 0x2a {
+		t.Fatal(b)
+	}
+	p.Set(0x1000, 0x01)
+	if err := p.Set(0x2000, 0x01); err != ErrOutOfMemory {
+		t.Fatal(err)
+	}
+	p.Release(0x3fffffe000, 2*PageSize)
+	if b, _ := p.Get(0x3ffffff001); b != 0x00 || p.Resident() != PageSize || p.Peak() != 2*PageSize {
+		t.Fatal(b, p.Resident(), p.Peak())
+	}
+}
+
+func TestPagedZero(t *testing.T) {
+	p := &Paged{}
+	if b, err := p.Get(0x1000); err != nil || b != 0 {
+		t.Fatal(b, err)
+	}
+	if err := p.SetUint64(0x1ffc, 0x2a00000001); err != nil || p.Resident() != 2*PageSize {
+		t.Fatal(err, p.Resident())
+	}
+	if v, _ := p.GetUint64(0x1ffc); v != 0x2a00000001 {
+		t.Fatal(v)
+	}
+	if !p.Release(0, 0x3000) || p.Resident() != 0 {
+		t.Fatal(p.Resident())
+	}
+}
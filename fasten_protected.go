@@ -0,0 +1,280 @@
+package rv64
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// Permissions of a memory region.
+const (
+	PermR uint8 = 1 << 0
+	PermW uint8 = 1 << 1
+	PermX uint8 = 1 << 2
+)
+
+// AccessFault is the error of an access to memory which is not mapped or not permitted. Err is one of
+// ErrLoadAccessFault, ErrStoreAccessFault and ErrInstructionAccessFault, or the page fault errors of the same
+// accesses when the virtual address can not be translated.
+type AccessFault struct {
+	Err  error
+	Addr uint64
+}
+
+func (e *AccessFault) Error() string {
+	return fmt.Sprintf("%s at %#x", e.Err, e.Addr)
+}
+
+func (e *AccessFault) Unwrap() error {
+	return e.Err
+}
+
+// Region is a range of memory with the same permissions.
+type Region struct {
+	Addr uint64
+	Size uint64
+	Perm uint8
+}
+
+// Protected wraps a Fasten and only lets through the accesses permitted by its regions. Reads need PermR, writes
+// need PermW and instruction fetches need PermX. Memory outside of any region can not be accessed at all.
+type Protected struct {
+	Fasten
+	regions []Region
+	// The region accessed last, most accesses hit it.
+	last int
+}
+
+// find returns the index of the region holding a, or -1.
+func (p *Protected) find(a uint64) int {
+	if p.last < len(p.regions) {
+		e := p.regions[p.last]
+		if a >= e.Addr && a-e.Addr < e.Size {
+			return p.last
+		}
+	}
+	i := sort.Search(len(p.regions), func(i int) bool { return p.regions[i].Addr+p.regions[i].Size > a })
+	if i == len(p.regions) || a < p.regions[i].Addr {
+		return -1
+	}
+	p.last = i
+	return i
+}
+
+// check returns an AccessFault if the byte at a does not have the permission perm.
+func (p *Protected) check(a uint64, perm uint8, err error) error {
+	i := p.find(a)
+	if i < 0 || p.regions[i].Perm&perm == 0 {
+		return &AccessFault{Err: err, Addr: a}
+	}
+	return nil
+}
+
+func (p *Protected) Get(a uint64) (byte, error) {
+	if err := p.check(a, PermR, ErrLoadAccessFault); err != nil {
+		return 0x00, err
+	}
+	return p.Fasten.Get(a)
+}
+
+func (p *Protected) Set(a uint64, v byte) error {
+	if err := p.check(a, PermW, ErrStoreAccessFault); err != nil {
+		return err
+	}
+	return p.Fasten.Set(a, v)
+}
+
+// allowed returns nil if all bytes of [a, a+n) have the permission perm, or the fault of the first byte which has not.
+func (p *Protected) allowed(a uint64, n uint64, perm uint8, err error) error {
+	if i := p.find(a); i >= 0 && p.regions[i].Perm&perm != 0 && n <= p.regions[i].Addr+p.regions[i].Size-a {
+		return nil
+	}
+	for j := uint64(0); j < n; j++ {
+		if e := p.check(a+j, perm, err); e != nil {
+			return e
+		}
+	}
+	return nil
+}
+
+// get reads the wrapped Fasten, at once if it supports it.
+func (p *Protected) get(a uint64, b []byte) error {
+	if f, ok := p.Fasten.(FastenBulk); ok {
+		return f.GetBytes(a, b)
+	}
+	for i := range b {
+		e, err := p.Fasten.Get(a + uint64(i))
+		if err != nil {
+			return err
+		}
+		b[i] = e
+	}
+	return nil
+}
+
+// set writes the wrapped Fasten, at once if it supports it.
+func (p *Protected) set(a uint64, b []byte) error {
+	if f, ok := p.Fasten.(FastenBulk); ok {
+		return f.SetBytes(a, b)
+	}
+	for i, e := range b {
+		if err := p.Fasten.Set(a+uint64(i), e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Fetch reads the bytes of an instruction.
+func (p *Protected) Fetch(a uint64, b []byte) error {
+	if err := p.allowed(a, uint64(len(b)), PermX, ErrInstructionAccessFault); err != nil {
+		return err
+	}
+	return p.get(a, b)
+}
+
+func (p *Protected) GetBytes(a uint64, b []byte) error {
+	if err := p.allowed(a, uint64(len(b)), PermR, ErrLoadAccessFault); err != nil {
+		return err
+	}
+	return p.get(a, b)
+}
+
+// SetBytes writes nothing if any of the bytes is not writable.
+func (p *Protected) SetBytes(a uint64, b []byte) error {
+	if err := p.allowed(a, uint64(len(b)), PermW, ErrStoreAccessFault); err != nil {
+		return err
+	}
+	return p.set(a, b)
+}
+
+func (p *Protected) GetUint16(a uint64) (uint16, error) {
+	if err := p.allowed(a, 2, PermR, ErrLoadAccessFault); err != nil {
+		return 0, err
+	}
+	if f, ok := p.Fasten.(FastenWord); ok {
+		return f.GetUint16(a)
+	}
+	b := make([]byte, 2)
+	err := p.get(a, b)
+	return binary.LittleEndian.Uint16(b), err
+}
+
+func (p *Protected) GetUint32(a uint64) (uint32, error) {
+	if err := p.allowed(a, 4, PermR, ErrLoadAccessFault); err != nil {
+		return 0, err
+	}
+	if f, ok := p.Fasten.(FastenWord); ok {
+		return f.GetUint32(a)
+	}
+	b := make([]byte, 4)
+	err := p.get(a, b)
+	return binary.LittleEndian.Uint32(b), err
+}
+
+func (p *Protected) GetUint64(a uint64) (uint64, error) {
+	if err := p.allowed(a, 8, PermR, ErrLoadAccessFault); err != nil {
+		return 0, err
+	}
+	if f, ok := p.Fasten.(FastenWord); ok {
+		return f.GetUint64(a)
+	}
+	b := make([]byte, 8)
+	err := p.get(a, b)
+	return binary.LittleEndian.Uint64(b), err
+}
+
+func (p *Protected) SetUint16(a uint64, n uint16) error {
+	if err := p.allowed(a, 2, PermW, ErrStoreAccessFault); err != nil {
+		return err
+	}
+	if f, ok := p.Fasten.(FastenWord); ok {
+		return f.SetUint16(a, n)
+	}
+	b := make([]byte, 2)
+	binary.LittleEndian.PutUint16(b, n)
+	return p.set(a, b)
+}
+
+func (p *Protected) SetUint32(a uint64, n uint32) error {
+	if err := p.allowed(a, 4, PermW, ErrStoreAccessFault); err != nil {
+		return err
+	}
+	if f, ok := p.Fasten.(FastenWord); ok {
+		return f.SetUint32(a, n)
+	}
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, n)
+	return p.set(a, b)
+}
+
+func (p *Protected) SetUint64(a uint64, n uint64) error {
+	if err := p.allowed(a, 8, PermW, ErrStoreAccessFault); err != nil {
+		return err
+	}
+	if f, ok := p.Fasten.(FastenWord); ok {
+		return f.SetUint64(a, n)
+	}
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, n)
+	return p.set(a, b)
+}
+
+// Release forwards to the wrapped Fasten, see Paged.Release. It does not change the permissions.
+func (p *Protected) Release(a uint64, size uint64) bool {
+	if f, ok := p.Fasten.(fastenRelease); ok {
+		return f.Release(a, size)
+	}
+	return false
+}
+
+// Protect sets the permissions of [addr, addr+size), replacing those of any region there. Permission 0 removes the
+// range from the regions. It fails if the range wraps around the address space, or grants access to memory past the
+// end of the Fasten.
+func (p *Protected) Protect(addr uint64, size uint64, perm uint8) error {
+	if size == 0 {
+		return nil
+	}
+	end := addr + size
+	if end < addr || perm != 0 && end > p.Fasten.Len() {
+		return ErrOutOfMemory
+	}
+	r := []Region{}
+	for _, e := range p.regions {
+		if e.Addr+e.Size <= addr || e.Addr >= end {
+			r = append(r, e)
+			continue
+		}
+		if e.Addr < addr {
+			r = append(r, Region{Addr: e.Addr, Size: addr - e.Addr, Perm: e.Perm})
+		}
+		if e.Addr+e.Size > end {
+			r = append(r, Region{Addr: end, Size: e.Addr + e.Size - end, Perm: e.Perm})
+		}
+	}
+	if perm != 0 {
+		r = append(r, Region{Addr: addr, Size: size, Perm: perm})
+	}
+	sort.Slice(r, func(i, j int) bool { return r[i].Addr < r[j].Addr })
+	// Merge neighbours with the same permissions, brk and the stack would leave many small regions otherwise.
+	p.regions = p.regions[:0]
+	for _, e := range r {
+		if n := len(p.regions); n != 0 && p.regions[n-1].Addr+p.regions[n-1].Size == e.Addr && p.regions[n-1].Perm == e.Perm {
+			p.regions[n-1].Size += e.Size
+			continue
+		}
+		p.regions = append(p.regions, e)
+	}
+	p.last = 0
+	return nil
+}
+
+// Regions returns the regions sorted by address.
+func (p *Protected) Regions() []Region {
+	return append([]Region{}, p.regions...)
+}
+
+// NewProtected returns f with no accessible region.
+func NewProtected(f Fasten) *Protected {
+	return &Protected{Fasten: f}
+}
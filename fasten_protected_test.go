@@ -0,0 +1,30 @@
+package rv64
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestProtected(t *testing.T) {
+	p := NewProtected(NewPaged(0))
+	m := &Memory{Fasten: p}
+	p.Protect(0x1000, 0x2000, PermR|PermX)
+	p.Protect(0x2000, 0x1000, PermR|PermW)
+	if err := m.SetUint32(0x2000, 0x13); err != nil {
+		t.Fatal(err)
+	}
+	var f *AccessFault
+	if err := m.SetUint32(0x1ffe, 0x13); !errors.As(err, &f) || f.Err != ErrStoreAccessFault || f.Addr != 0x1ffe {
+		t.Fatal(err)
+	}
+	if _, err := m.Fetch(0x2000, 4); !errors.Is(err, ErrInstructionAccessFault) {
+		t.Fatal(err)
+	}
+	if _, err := m.GetUint8(0x3000); !errors.Is(err, ErrLoadAccessFault) {
+		t.Fatal(err)
+	}
+	p.Protect(0x2000, 0x1000, PermR|PermX)
+	if r := p.Regions(); len(r) != 1 || r[0] != (Region{Addr: 0x1000, Size: 0x2000, Perm: PermR | PermX}) {
+		t.Fatal(r)
+	}
+}
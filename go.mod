@@ -1,3 +1,3 @@
 module github.com/mohanson/rv64
 
-go 1.13
+go 1.16
@@ -8,19 +8,88 @@ type Memory struct {
 	Fasten
 }
 
+// Optional methods of a Fasten, Memory uses them when they are implemented.
+type (
+	fastenFetch interface {
+		Fetch(uint64, []byte) error
+	}
+	fastenProtect interface {
+		Protect(uint64, uint64, uint8) error
+	}
+	fastenRelease interface {
+		Release(uint64, uint64) bool
+	}
+	fastenRegions interface {
+		Regions() []Region
+	}
+	// A Fasten that wraps another one, like Bus, forwards the regions of the wrapped Fasten if it has any.
+	fastenRegionsForward interface {
+		Regions() ([]Region, bool)
+	}
+)
+
+// Fetch reads l bytes of instructions. A Fasten without a Fetch method is read like data.
+func (m *Memory) Fetch(a uint64, l uint64) ([]byte, error) {
+	f, ok := m.Fasten.(fastenFetch)
+	if !ok {
+		return m.GetByte(a, l)
+	}
+	r := make([]byte, l)
+	return r, f.Fetch(a, r)
+}
+
+// Protect sets the permissions of [a, a+size), see Protected. It does nothing if the Fasten has no permissions.
+func (m *Memory) Protect(a uint64, size uint64, perm uint8) error {
+	if f, ok := m.Fasten.(fastenProtect); ok {
+		return f.Protect(a, size, perm)
+	}
+	return nil
+}
+
+// Regions returns the regions of the memory, see Protected. It reports false if the Fasten has no permissions.
+func (m *Memory) Regions() ([]Region, bool) {
+	switch f := m.Fasten.(type) {
+	case fastenRegions:
+		return f.Regions(), true
+	case fastenRegionsForward:
+		return f.Regions()
+	}
+	return nil, false
+}
+
+// Release tells the Fasten that the whole pages inside [a, a+size) are no longer used, their content reads as zero
+// afterwards. It returns false if the Fasten does not support it, then nothing changed.
+func (m *Memory) Release(a uint64, size uint64) bool {
+	if f, ok := m.Fasten.(fastenRelease); ok {
+		return f.Release(a, size)
+	}
+	return false
+}
+
 func (m *Memory) GetByte(a uint64, l uint64) ([]byte, error) {
 	r := make([]byte, l)
-	for i := 0; uint64(i) < l; i++ {
+	return r, m.get(a, r)
+}
+
+// get fills r with the bytes at a.
+func (m *Memory) get(a uint64, r []byte) error {
+	if f, ok := m.Fasten.(FastenBulk); ok {
+		return f.GetBytes(a, r)
+	}
+	for i := range r {
 		b, err := m.Fasten.Get(a + uint64(i))
 		if err != nil {
-			return r, err
+			return err
 		}
 		r[i] = b
 	}
-	return r, nil
+	return nil
 }
 
 func (m *Memory) SetByte(a uint64, b []byte) error {
+	if f, ok := m.Fasten.(FastenBulk); ok {
+		return f.SetBytes(a, b)
+	}
 	for i := 0; i < len(b); i++ {
 		err := m.Set(a+uint64(i), b[i])
 		if err != nil {
@@ -30,6 +99,22 @@ func (m *Memory) SetByte(a uint64, b []byte) error {
 	return nil
 }
 
+// GetString reads a NUL-terminated string.
+func (m *Memory) GetString(a uint64) (string, error) {
+	b := []byte{}
+	for {
+		e, err := m.GetUint8(a)
+		if err != nil {
+			return "", err
+		}
+		if e == 0x00 {
+			return string(b), nil
+		}
+		b = append(b, e)
+		a++
+	}
+}
+
 func (m *Memory) GetUint8(a uint64) (uint8, error) {
 	mem, err := m.Get(a)
 	if err != nil {
@@ -43,6 +128,9 @@ func (m *Memory) SetUint8(a uint64, n uint8) error {
 }
 
 func (m *Memory) GetUint16(a uint64) (uint16, error) {
+	if f, ok := m.Fasten.(FastenWord); ok {
+		return f.GetUint16(a)
+	}
 	mem, err := m.GetByte(a, 2)
 	if err != nil {
 		return 0, err
@@ -51,12 +139,18 @@ func (m *Memory) GetUint16(a uint64) (uint16, error) {
 }
 
 func (m *Memory) SetUint16(a uint64, n uint16) error {
+	if f, ok := m.Fasten.(FastenWord); ok {
+		return f.SetUint16(a, n)
+	}
 	mem := make([]byte, 2)
 	binary.LittleEndian.PutUint16(mem, n)
 	return m.SetByte(a, mem)
 }
 
 func (m *Memory) GetUint32(a uint64) (uint32, error) {
+	if f, ok := m.Fasten.(FastenWord); ok {
+		return f.GetUint32(a)
+	}
 	mem, err := m.GetByte(a, 4)
 	if err != nil {
 		return 0, err
@@ -65,12 +159,18 @@ func (m *Memory) GetUint32(a uint64) (uint32, error) {
 }
 
 func (m *Memory) SetUint32(a uint64, n uint32) error {
+	if f, ok := m.Fasten.(FastenWord); ok {
+		return f.SetUint32(a, n)
+	}
 	mem := make([]byte, 4)
 	binary.LittleEndian.PutUint32(mem, n)
 	return m.SetByte(a, mem)
 }
 
 func (m *Memory) GetUint64(a uint64) (uint64, error) {
+	if f, ok := m.Fasten.(FastenWord); ok {
+		return f.GetUint64(a)
+	}
 	mem, err := m.GetByte(a, 8)
 	if err != nil {
 		return 0, err
@@ -79,6 +179,9 @@ func (m *Memory) GetUint64(a uint64) (uint64, error) {
 }
 
 func (m *Memory) SetUint64(a uint64, n uint64) error {
+	if f, ok := m.Fasten.(FastenWord); ok {
+		return f.SetUint64(a, n)
+	}
 	mem := make([]byte, 8)
 	binary.LittleEndian.PutUint64(mem, n)
 	return m.SetByte(a, mem)
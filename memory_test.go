@@ -0,0 +1,126 @@
+package rv64
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// benchBasic hides the optional interfaces of a Fasten, so that Memory falls back to Get and Set.
+type benchBasic struct {
+	Fasten
+}
+
+func benchFastens() map[string]func() Fasten {
+	paged := func() Fasten {
+		return NewPaged(0)
+	}
+	protected := func() Fasten {
+		p := NewProtected(NewPaged(0))
+		p.Protect(0, 0x100000, PermR|PermW|PermX)
+		return p
+	}
+	return map[string]func() Fasten{
+		"Linear":         func() Fasten { return NewLinear(0x100000) },
+		"LinearBasic":    func() Fasten { return &benchBasic{NewLinear(0x100000)} },
+		"Paged":          paged,
+		"PagedBasic":     func() Fasten { return &benchBasic{paged()} },
+		"Protected":      protected,
+		"ProtectedBasic": func() Fasten { return &benchBasic{protected()} },
+	}
+}
+
+func BenchmarkMemoryUint64(b *testing.B) {
+	for name, f := range benchFastens() {
+		b.Run(name, func(b *testing.B) {
+			m := &Memory{Fasten: f()}
+			for i := 0; i < b.N; i++ {
+				a := uint64(i*8) & 0xffff
+				m.SetUint64(a, uint64(i))
+				m.GetUint64(a)
+			}
+		})
+	}
+}
+
+func BenchmarkMemoryByte(b *testing.B) {
+	for name, f := range benchFastens() {
+		b.Run(name, func(b *testing.B) {
+			m := &Memory{Fasten: f()}
+			d := make([]byte, 4096)
+			b.SetBytes(int64(len(d)))
+			for i := 0; i < b.N; i++ {
+				m.SetByte(0x1000, d)
+				m.GetByte(0x1000, uint64(len(d)))
+			}
+		})
+	}
+}
+
+func BenchmarkMemoryFetch(b *testing.B) {
+	for name, f := range benchFastens() {
+		b.Run(name, func(b *testing.B) {
+			c := NewCPU()
+			c.SetFasten(f())
+			// addi a0, a0, 1
+			c.GetMemory().SetUint32(0x1000, 0x00150513)
+			c.SetPC(0x1000)
+			for i := 0; i < b.N; i++ {
+				c.PipelineInstructionFetch()
+			}
+		})
+	}
+}
+
+func TestMemory(t *testing.T) {
+	// The accesses run in order on every memory. Those with out set cross 0x2000, the end of the linear memory and of
+	// the protected region, they fail unless the memory is paged.
+	d := bytes.Repeat([]byte{1, 2, 3, 4, 5, 6, 7, 8}, 4)
+	ops := []struct {
+		out bool
+		f   func(m *Memory) (interface{}, error)
+	}{
+		{false, func(m *Memory) (interface{}, error) { return nil, m.SetUint64(0xffc, 0x1122334455667788) }},
+		{false, func(m *Memory) (interface{}, error) { return m.GetUint64(0xffc) }},
+		{false, func(m *Memory) (interface{}, error) { return m.GetUint32(0xffe) }},
+		{false, func(m *Memory) (interface{}, error) { return m.GetUint16(0xfff) }},
+		{false, func(m *Memory) (interface{}, error) { return nil, m.SetUint32(0x1ffa, 0xaabbccdd) }},
+		{false, func(m *Memory) (interface{}, error) { return nil, m.SetUint16(0x1ffe, 0xeeff) }},
+		{false, func(m *Memory) (interface{}, error) { return nil, m.SetByte(0xff0, d) }},
+		{false, func(m *Memory) (interface{}, error) { return m.GetByte(0xfe0, 64) }},
+		{false, func(m *Memory) (interface{}, error) { return m.GetUint64(0x1ff8) }},
+		{false, func(m *Memory) (interface{}, error) { return m.GetByte(0x2000, 0) }},
+		{false, func(m *Memory) (interface{}, error) { return nil, m.SetByte(0x2000, nil) }},
+		{true, func(m *Memory) (interface{}, error) { return m.GetUint64(0x1ffc) }},
+		{true, func(m *Memory) (interface{}, error) { return m.GetUint32(0x2000) }},
+		{true, func(m *Memory) (interface{}, error) { return m.GetByte(0x1ff0, 32) }},
+		{true, func(m *Memory) (interface{}, error) { return m.Fetch(0x1ffe, 4) }},
+		{true, func(m *Memory) (interface{}, error) { return m.GetUint8(^uint64(0)) }},
+		// The content of the memory is undefined after a failed write, nothing reads it again.
+		{true, func(m *Memory) (interface{}, error) { return nil, m.SetUint64(0x1ffc, 0) }},
+		{true, func(m *Memory) (interface{}, error) { return nil, m.SetByte(0x1ff8, d) }},
+	}
+	protected := func() Fasten {
+		p := NewProtected(NewPaged(0))
+		p.Protect(0, 0x2000, PermR|PermW|PermX)
+		return p
+	}
+	for name, f := range map[string]func() Fasten{
+		"Linear":    func() Fasten { return NewLinear(0x2000) },
+		"Paged":     func() Fasten { return NewPaged(0) },
+		"Protected": protected,
+	} {
+		fast := &Memory{Fasten: f()}
+		basic := &Memory{Fasten: &benchBasic{f()}}
+		for i, e := range ops {
+			v0, err0 := e.f(fast)
+			v1, err1 := e.f(basic)
+			if (err0 != nil) != (e.out && name != "Paged") {
+				t.Errorf("%s: access %d: %v", name, i, err0)
+			}
+			if (err1 != nil) != (err0 != nil) || err0 == nil && fmt.Sprint(v0) != fmt.Sprint(v1) {
+				t.Errorf("%s: access %d: %v %v, byte by byte %v %v", name, i, v0, err0, v1, err1)
+			}
+		}
+	}
+}
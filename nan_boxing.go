@@ -38,3 +38,17 @@ func NaNGnixob(f float64) float32 {
 	}
 	return math.Float32frombits(uint32(u))
 }
+
+// getFloat returns the number of the format f held in the register i. A narrower number is NaN-unboxed.
+func (c *CPU) getFloat(f SoftFloat, i uint64) uint64 {
+	r := c.GetRegisterFloat(i)
+	if f.w < 64 && r>>f.w != 1<<(64-f.w)-1 {
+		return f.NaN()
+	}
+	return r & (1<<f.w - 1)
+}
+
+// setFloat writes the number r of the format f to the register i. A narrower number is NaN-boxed.
+func (c *CPU) setFloat(f SoftFloat, i uint64, r uint64) {
+	c.SetRegisterFloat(i, r|^(1<<f.w-1))
+}
@@ -0,0 +1,154 @@
+package rv64
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+)
+
+// Types of the auxiliary vector entries, from include/uapi/linux/auxvec.h.
+const (
+	LinuxAuxvNull     uint64 = 0
+	LinuxAuxvPhdr     uint64 = 3
+	LinuxAuxvPhent    uint64 = 4
+	LinuxAuxvPhnum    uint64 = 5
+	LinuxAuxvPagesz   uint64 = 6
+	LinuxAuxvBase     uint64 = 7
+	LinuxAuxvFlags    uint64 = 8
+	LinuxAuxvEntry    uint64 = 9
+	LinuxAuxvUID      uint64 = 11
+	LinuxAuxvEUID     uint64 = 12
+	LinuxAuxvGID      uint64 = 13
+	LinuxAuxvEGID     uint64 = 14
+	LinuxAuxvPlatform uint64 = 15
+	LinuxAuxvHwcap    uint64 = 16
+	LinuxAuxvClktck   uint64 = 17
+	LinuxAuxvSecure   uint64 = 23
+	LinuxAuxvRandom   uint64 = 25
+	LinuxAuxvExecfn   uint64 = 31
+)
+
+// LinuxHwcap is the AT_HWCAP of the emulated hart. Linux sets one bit per single letter ISA extension: IMAFDC.
+const LinuxHwcap uint64 = 1<<('I'-'A') | 1<<('M'-'A') | 1<<('A'-'A') | 1<<('F'-'A') | 1<<('D'-'A') | 1<<('C'-'A')
+
+// Auxv is an entry of the auxiliary vector.
+type Auxv struct {
+	Type  uint64
+	Value uint64
+}
+
+// Process describes the initial stack of a guest process.
+//
+// Auxv holds the entries that do not point into the stack, the loader adds AT_PHDR, AT_ENTRY and friends to it.
+// AT_RANDOM, AT_PLATFORM and AT_EXECFN point to data placed on the stack by Push and must not be listed.
+type Process struct {
+	Args     []string
+	Env      []string
+	Auxv     []Auxv
+	Random   [16]byte
+	Platform string
+}
+
+// Push builds the initial stack of Linux below the stack pointer and moves the stack pointer to argc:
+//
+// | execfn         | SP Base
+// | envp strings   |
+// | argv strings   |
+// | platform       |
+// | random bytes   |
+// | padding        |
+// | AT_NULL        |
+// | auxv           |
+// | 0              |
+// | envp pointers  |
+// | 0              |
+// | argv pointers  |
+// | argc           | SP, aligned to 16 bytes
+func (p *Process) Push(c *CPU) error {
+	sp := c.GetRegister(Rsp)
+	mem := c.GetMemory()
+	pushByte := func(b []byte) (uint64, error) {
+		sp -= uint64(len(b))
+		return sp, mem.SetByte(sp, b)
+	}
+	pushString := func(s string) (uint64, error) {
+		return pushByte(append([]byte(s), 0x00))
+	}
+
+	execfn := ""
+	if len(p.Args) != 0 {
+		execfn = p.Args[0]
+	}
+	execfnPtr, err := pushString(execfn)
+	if err != nil {
+		return err
+	}
+	envPtrs := make([]uint64, len(p.Env))
+	for i := len(p.Env) - 1; i >= 0; i-- {
+		if envPtrs[i], err = pushString(p.Env[i]); err != nil {
+			return err
+		}
+	}
+	argPtrs := make([]uint64, len(p.Args))
+	for i := len(p.Args) - 1; i >= 0; i-- {
+		if argPtrs[i], err = pushString(p.Args[i]); err != nil {
+			return err
+		}
+	}
+	platformPtr, err := pushString(p.Platform)
+	if err != nil {
+		return err
+	}
+	randomPtr, err := pushByte(p.Random[:])
+	if err != nil {
+		return err
+	}
+
+	auxv := append([]Auxv{}, p.Auxv...)
+	auxv = append(auxv,
+		Auxv{LinuxAuxvRandom, randomPtr},
+		Auxv{LinuxAuxvPlatform, platformPtr},
+		Auxv{LinuxAuxvExecfn, execfnPtr},
+		Auxv{LinuxAuxvNull, 0},
+	)
+	words := []uint64{uint64(len(p.Args))}
+	words = append(words, argPtrs...)
+	words = append(words, 0)
+	words = append(words, envPtrs...)
+	words = append(words, 0)
+	for _, e := range auxv {
+		words = append(words, e.Type, e.Value)
+	}
+	// Stack pointer must be aligned to 16-byte boundary.
+	sp = (sp - uint64(len(words))*8) & ^uint64(15)
+	b := make([]byte, len(words)*8)
+	for i, e := range words {
+		binary.LittleEndian.PutUint64(b[i*8:], e)
+	}
+	if err := mem.SetByte(sp, b); err != nil {
+		return err
+	}
+	c.SetRegister(Rsp, sp)
+	return nil
+}
+
+// NewProcess returns a process with the auxiliary vector entries which do not depend on the program: the page size,
+// the hardware capabilities and the credentials of root. The random bytes come from the host.
+func NewProcess(args []string, env []string) *Process {
+	p := &Process{
+		Args: args,
+		Env:  env,
+		Auxv: []Auxv{
+			{LinuxAuxvPagesz, PageSize},
+			{LinuxAuxvHwcap, LinuxHwcap},
+			{LinuxAuxvClktck, 100},
+			{LinuxAuxvUID, 0},
+			{LinuxAuxvEUID, 0},
+			{LinuxAuxvGID, 0},
+			{LinuxAuxvEGID, 0},
+			{LinuxAuxvSecure, 0},
+		},
+		Platform: "riscv64",
+	}
+	rand.Read(p.Random[:])
+	return p
+}
@@ -0,0 +1,61 @@
+package rv64
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestProcessPush(t *testing.T) {
+	c := NewCPU()
+	c.SetFasten(NewPaged(0))
+	c.SetRegister(Rsp, 0x10000)
+	p := NewProcess([]string{"prog", "-v"}, []string{"A=1"})
+	copy(p.Random[:], "0123456789abcdef")
+	if err := p.Push(c); err != nil {
+		t.Fatal(err)
+	}
+	sp := c.GetRegister(Rsp)
+	if sp%16 != 0 || sp >= 0x10000 {
+		t.Fatalf("%#x", sp)
+	}
+	mem := c.GetMemory()
+	word := func(i uint64) uint64 {
+		v, _ := mem.GetUint64(sp + i*8)
+		return v
+	}
+	str := func(a uint64) string {
+		s, _ := mem.GetString(a)
+		return s
+	}
+	// argc, argv, NULL, envp, NULL, auxv.
+	if word(0) != 2 || str(word(1)) != "prog" || str(word(2)) != "-v" || word(3) != 0 {
+		t.Fatal(word(0), str(word(1)), str(word(2)), word(3))
+	}
+	if str(word(4)) != "A=1" || word(5) != 0 {
+		t.Fatal(str(word(4)), word(5))
+	}
+	auxv := testAuxv(c)
+	if auxv[LinuxAuxvPagesz] != PageSize || auxv[LinuxAuxvHwcap] != LinuxHwcap {
+		t.Fatal(auxv)
+	}
+	if b, _ := mem.GetByte(auxv[LinuxAuxvRandom], 16); !bytes.Equal(b, []byte("0123456789abcdef")) {
+		t.Fatal(b)
+	}
+	if s := str(auxv[LinuxAuxvPlatform]); s != "riscv64" {
+		t.Fatal(s)
+	}
+	if s := str(auxv[LinuxAuxvExecfn]); s != "prog" {
+		t.Fatal(s)
+	}
+	// The vector ends with AT_NULL, right after the entries of the process and those pointing into the stack.
+	n := uint64(6 + 2*(len(p.Auxv)+3))
+	if word(n) != LinuxAuxvNull || word(n+1) != 0 {
+		t.Fatal(word(n), word(n+1))
+	}
+	// The strings are above the vectors, below the initial stack pointer.
+	for _, a := range []uint64{word(1), word(4), auxv[LinuxAuxvRandom], auxv[LinuxAuxvExecfn]} {
+		if a <= sp+(n+2)*8 || a >= 0x10000 {
+			t.Fatalf("%#x", a)
+		}
+	}
+}
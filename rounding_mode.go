@@ -0,0 +1,31 @@
+package rv64
+
+// Rounding Modes
+// Floating-point operations use either a static rounding mode encoded in the instruction, or a dynamic rounding mode
+// held in frm. Rounding modes are encoded as shown in the table below. A value of 111 in the instruction's rm field
+// selects the dynamic rounding mode held in frm. If frm is set to an invalid value (101–111), any subsequent attempt
+// to execute a floating-point operation with a dynamic rounding mode will raise an illegal instruction exception.
+// Some instructions, including widening conversions, have the rm field but are nevertheless mathematically
+// unaffected by the rounding mode; software should set their rm field to RNE (000) but implementations must treat
+// the rm field as usual (in particular, with regard to decoding legal vs. reserved encodings).
+const (
+	RoundingRNE uint64 = 0 // Round to Nearest, ties to Even
+	RoundingRTZ uint64 = 1 // Round towards Zero
+	RoundingRDN uint64 = 2 // Round Down (towards -inf)
+	RoundingRUP uint64 = 3 // Round Up (towards +inf)
+	RoundingRMM uint64 = 4 // Round to Nearest, ties to Max Magnitude
+	RoundingDYN uint64 = 7 // In instruction's rm field, selects dynamic rounding mode
+)
+
+// rounding returns the rounding mode of the instruction i, read from frm if it is dynamic. A reserved rounding mode
+// is an illegal instruction.
+func (c *CPU) rounding(i uint64) (uint64, error) {
+	rm := InstructionPart(i, 12, 14)
+	if rm == RoundingDYN {
+		rm = c.GetCSR().Get(CSRfrm)
+	}
+	if rm > RoundingRMM {
+		return 0, ErrAbnormalInstruction
+	}
+	return rm, nil
+}
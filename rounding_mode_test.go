@@ -0,0 +1,29 @@
+package rv64
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCPURoundingMode(t *testing.T) {
+	c := testCPU(map[uint64]uint32{
+		0x1000: 0x02b53553, // fadd.d fa0, fa0, fa1, rup
+		0x1004: 0x02b57553, // fadd.d fa0, fa0, fa1, dyn
+		0x1008: 0x02b55553, // fadd.d fa0, fa0, fa1 with the reserved rm 5
+		0x2000: 0x34202573, // csrr a0, mcause
+		0x2004: 0x05d00893, // li a7, 93
+		0x2008: 0x00000073, // ecall
+	})
+	c.GetCSR().Set(CSRmtvec, 0x2000)
+	c.GetCSR().Set(CSRfrm, RoundingRDN)
+	c.SetRegisterFloatAsFloat64(Rfa0, 1)
+	c.SetRegisterFloatAsFloat64(Rfa1, math.Ldexp(1, -60))
+	c.SetPC(0x1000)
+	if r := c.Run(); uint64(r) != ExceptionIllegalInstruction || c.GetCSR().Get(CSRmepc) != 0x1008 {
+		t.Fatal(r, c.GetCSR().Get(CSRmepc))
+	}
+	// Rounded up to the next double, then down to it again.
+	if r := c.GetRegisterFloatAsFloat64(Rfa0); r != 1+math.Ldexp(1, -52) {
+		t.Fatal(r)
+	}
+}
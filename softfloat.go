@@ -0,0 +1,580 @@
+package rv64
+
+import (
+	"math/bits"
+)
+
+// SoftFloat is an IEEE 754 binary floating-point format implemented with integer arithmetic, with the semantics of
+// Berkeley SoftFloat specialized for RISC-V. Numbers are bit patterns held in the low bits of an uint64.
+//
+// Operations are correctly rounded with any of the five rounding modes and return the exception flags they raise, a
+// combination of FFlagsNV, FFlagsDZ, FFlagsOF, FFlagsUF and FFlagsNX. Tininess is detected after rounding. A NaN result
+// is always the canonical NaN, the payloads of NaN operands are not propagated.
+type SoftFloat struct {
+	w uint // Width
+	p uint // Precision, with the hidden bit
+}
+
+// Formats of the F and D extensions.
+var (
+	SoftFloat32 = SoftFloat{w: 32, p: 24}
+	SoftFloat64 = SoftFloat{w: 64, p: 53}
+)
+
+func (f SoftFloat) bias() int {
+	return 1<<(f.w-f.p-1) - 1
+}
+
+func (f SoftFloat) inf() uint64 {
+	return (1<<(f.w-f.p) - 1) << (f.p - 1)
+}
+
+func (f SoftFloat) sign(a uint64) bool {
+	return a>>(f.w-1) != 0
+}
+
+func (f SoftFloat) signed(sign bool, a uint64) uint64 {
+	if sign {
+		return a | 1<<(f.w-1)
+	}
+	return a
+}
+
+func (f SoftFloat) neg(a uint64) uint64 {
+	return a ^ 1<<(f.w-1)
+}
+
+func (f SoftFloat) isInf(a uint64) bool {
+	return a&^(1<<(f.w-1)) == f.inf()
+}
+
+func (f SoftFloat) isZero(a uint64) bool {
+	return a&^(1<<(f.w-1)) == 0
+}
+
+// NaN returns the canonical NaN.
+func (f SoftFloat) NaN() uint64 {
+	return f.inf() | 1<<(f.p-2)
+}
+
+// IsNaN reports whether a is a NaN.
+func (f SoftFloat) IsNaN(a uint64) bool {
+	return a&^(1<<(f.w-1)) > f.inf()
+}
+
+// IsSNaN reports whether a is a signaling NaN.
+func (f SoftFloat) IsSNaN(a uint64) bool {
+	return f.IsNaN(a) && a&(1<<(f.p-2)) == 0
+}
+
+// nan returns the canonical NaN, with NV if one of the operands is a signaling NaN.
+func (f SoftFloat) nan(a ...uint64) (uint64, uint64) {
+	for _, e := range a {
+		if f.IsSNaN(e) {
+			return f.NaN(), FFlagsNV
+		}
+	}
+	return f.NaN(), 0
+}
+
+// unpack returns the sign, exponent and significand of the finite nonzero number a. Its magnitude is
+// sig × 2^(exp-63), the highest bit of sig is set.
+func (f SoftFloat) unpack(a uint64) (bool, int, uint64) {
+	e := int(a &^ (1 << (f.w - 1)) >> (f.p - 1))
+	sig := a & (1<<(f.p-1) - 1)
+	if e == 0 {
+		e = 1
+	} else {
+		sig |= 1 << (f.p - 1)
+	}
+	n := bits.LeadingZeros64(sig)
+	return f.sign(a), e - f.bias() + 64 - int(f.p) - n, sig << n
+}
+
+// softRound drops the lowest shift bits of sig, rounding the result with the rounding mode rm. It reports whether
+// bits that were not zero were dropped.
+func softRound(sign bool, sig uint64, shift uint, rm uint64) (uint64, bool) {
+	var m, rest, half uint64
+	switch {
+	case shift == 0:
+		return sig, false
+	case shift < 64:
+		m, rest, half = sig>>shift, sig&(1<<shift-1), 1<<(shift-1)
+	case shift == 64:
+		rest, half = sig, 1<<63
+	default:
+		// Less than half of the last bit, but not zero.
+		rest, half = 1, 2
+	}
+	if rest == 0 {
+		return m, false
+	}
+	switch rm {
+	case RoundingRNE:
+		if rest > half || rest == half && m&1 != 0 {
+			m++
+		}
+	case RoundingRMM:
+		if rest >= half {
+			m++
+		}
+	case RoundingRDN:
+		if sign {
+			m++
+		}
+	case RoundingRUP:
+		if !sign {
+			m++
+		}
+	}
+	return m, true
+}
+
+// overflow returns the result of a number too large for the format: infinity, or the largest finite number when the
+// rounding mode goes towards zero.
+func (f SoftFloat) overflow(sign bool, rm uint64) (uint64, uint64) {
+	r := f.inf()
+	if rm == RoundingRTZ || rm == RoundingRDN && !sign || rm == RoundingRUP && sign {
+		r--
+	}
+	return f.signed(sign, r), FFlagsOF | FFlagsNX
+}
+
+// round returns the number of magnitude sig × 2^(exp-63) rounded to the format with the rounding mode rm. The highest
+// bit of sig is set, and its lowest bit must be set if bits that were not zero were dropped below it.
+func (f SoftFloat) round(sign bool, exp int, sig uint64, rm uint64) (uint64, uint64) {
+	if exp > f.bias() {
+		return f.overflow(sign, rm)
+	}
+	emin := 1 - f.bias()
+	shift := 64 - f.p
+	tiny := false
+	if exp < emin {
+		// A number just below the smallest normal one is not tiny if rounding it to the full precision, with an
+		// unbounded exponent, reaches the smallest normal one.
+		m, _ := softRound(sign, sig, shift, rm)
+		tiny = exp < emin-1 || m>>f.p == 0
+		shift += uint(emin - exp)
+	}
+	m, inexact := softRound(sign, sig, shift, rm)
+	// The hidden bit of m adds one to the exponent field, and a carry out of the significand one more.
+	var r uint64
+	if exp >= emin {
+		r = uint64(exp+f.bias()-1) << (f.p - 1)
+	}
+	r += m
+	if r >= f.inf() {
+		return f.overflow(sign, rm)
+	}
+	var flags uint64
+	if inexact {
+		flags = FFlagsNX
+		if tiny {
+			flags |= FFlagsUF
+		}
+	}
+	return f.signed(sign, r), flags
+}
+
+// zero returns the zero sum of numbers of signs sa and sb, negative if both are, or with RDN if they differ.
+func (f SoftFloat) zero(sa bool, sb bool, rm uint64) uint64 {
+	return f.signed(sa && sb || sa != sb && rm == RoundingRDN, 0)
+}
+
+// sum returns x × 2^(ex-126) + y × 2^(ey-126) rounded, both x and y having their highest bit at 126. The exponent of
+// y is ignored if it is zero.
+func (f SoftFloat) sum(sx bool, ex int, x u128, sy bool, ey int, y u128, rm uint64) (uint64, uint64) {
+	if y.zero() {
+		ey = ex
+	}
+	if ex < ey || ex == ey && x.less(y) {
+		sx, ex, x, sy, ey, y = sy, ey, y, sx, ex, x
+	}
+	y = y.jam(uint(ex - ey))
+	var s u128
+	if sx == sy {
+		s = x.add(y)
+	} else {
+		s = x.sub(y)
+	}
+	if s.zero() {
+		return f.zero(sx, sy, rm), 0
+	}
+	n := s.lz()
+	s = s.shl(n)
+	sig := s.hi
+	if s.lo != 0 {
+		sig |= 1
+	}
+	return f.round(sx, ex+1-int(n), sig, rm)
+}
+
+// Add returns a + b.
+func (f SoftFloat) Add(a uint64, b uint64, rm uint64) (uint64, uint64) {
+	switch {
+	case f.IsNaN(a) || f.IsNaN(b):
+		return f.nan(a, b)
+	case f.isInf(a) && f.isInf(b) && f.sign(a) != f.sign(b):
+		return f.NaN(), FFlagsNV
+	case f.isInf(a):
+		return a, 0
+	case f.isInf(b):
+		return b, 0
+	case f.isZero(a) && f.isZero(b):
+		return f.zero(f.sign(a), f.sign(b), rm), 0
+	case f.isZero(a):
+		return b, 0
+	case f.isZero(b):
+		return a, 0
+	}
+	sa, ea, ma := f.unpack(a)
+	sb, eb, mb := f.unpack(b)
+	return f.sum(sa, ea, u128{ma >> 1, ma << 63}, sb, eb, u128{mb >> 1, mb << 63}, rm)
+}
+
+// Sub returns a - b.
+func (f SoftFloat) Sub(a uint64, b uint64, rm uint64) (uint64, uint64) {
+	return f.Add(a, f.neg(b), rm)
+}
+
+// Mul returns a × b.
+func (f SoftFloat) Mul(a uint64, b uint64, rm uint64) (uint64, uint64) {
+	sign := f.sign(a) != f.sign(b)
+	switch {
+	case f.IsNaN(a) || f.IsNaN(b):
+		return f.nan(a, b)
+	case f.isInf(a) || f.isInf(b):
+		if f.isZero(a) || f.isZero(b) {
+			return f.NaN(), FFlagsNV
+		}
+		return f.signed(sign, f.inf()), 0
+	case f.isZero(a) || f.isZero(b):
+		return f.signed(sign, 0), 0
+	}
+	_, ea, ma := f.unpack(a)
+	_, eb, mb := f.unpack(b)
+	hi, lo := bits.Mul64(ma, mb)
+	e := ea + eb + 1
+	if hi>>63 == 0 {
+		hi, lo = hi<<1|lo>>63, lo<<1
+		e--
+	}
+	if lo != 0 {
+		hi |= 1
+	}
+	return f.round(sign, e, hi, rm)
+}
+
+// MulAdd returns a × b + c with a single rounding. The product of zero and infinity raises NV even if c is a quiet
+// NaN.
+func (f SoftFloat) MulAdd(a uint64, b uint64, c uint64, rm uint64) (uint64, uint64) {
+	sign := f.sign(a) != f.sign(b)
+	switch {
+	case f.IsNaN(a) || f.IsNaN(b):
+		return f.nan(a, b, c)
+	case (f.isInf(a) || f.isInf(b)) && (f.isZero(a) || f.isZero(b)):
+		return f.NaN(), FFlagsNV
+	case f.IsNaN(c):
+		return f.nan(c)
+	case f.isInf(a) || f.isInf(b):
+		if f.isInf(c) && f.sign(c) != sign {
+			return f.NaN(), FFlagsNV
+		}
+		return f.signed(sign, f.inf()), 0
+	case f.isInf(c):
+		return c, 0
+	case f.isZero(a) || f.isZero(b):
+		if f.isZero(c) {
+			return f.zero(sign, f.sign(c), rm), 0
+		}
+		return c, 0
+	}
+	_, ea, ma := f.unpack(a)
+	_, eb, mb := f.unpack(b)
+	hi, lo := bits.Mul64(ma, mb)
+	// The product has at most 106 bits, none is lost by moving its highest bit to 126.
+	p, e := u128{hi, lo}, ea+eb
+	if hi>>63 != 0 {
+		p, e = p.shr(1), e+1
+	}
+	if f.isZero(c) {
+		return f.sum(sign, e, p, sign, e, u128{}, rm)
+	}
+	sc, ec, mc := f.unpack(c)
+	return f.sum(sign, e, p, sc, ec, u128{mc >> 1, mc << 63}, rm)
+}
+
+// Div returns a / b.
+func (f SoftFloat) Div(a uint64, b uint64, rm uint64) (uint64, uint64) {
+	sign := f.sign(a) != f.sign(b)
+	switch {
+	case f.IsNaN(a) || f.IsNaN(b):
+		return f.nan(a, b)
+	case f.isInf(a):
+		if f.isInf(b) {
+			return f.NaN(), FFlagsNV
+		}
+		return f.signed(sign, f.inf()), 0
+	case f.isInf(b):
+		return f.signed(sign, 0), 0
+	case f.isZero(b):
+		if f.isZero(a) {
+			return f.NaN(), FFlagsNV
+		}
+		return f.signed(sign, f.inf()), FFlagsDZ
+	case f.isZero(a):
+		return f.signed(sign, 0), 0
+	}
+	_, ea, ma := f.unpack(a)
+	_, eb, mb := f.unpack(b)
+	// The quotient of ma × 2^63 by mb is at least 2^62.
+	q, r := bits.Div64(ma>>1, ma<<63, mb)
+	n := bits.LeadingZeros64(q)
+	q <<= n
+	if r != 0 {
+		q |= 1
+	}
+	return f.round(sign, ea-eb-n, q, rm)
+}
+
+// Sqrt returns the square root of a.
+func (f SoftFloat) Sqrt(a uint64, rm uint64) (uint64, uint64) {
+	switch {
+	case f.IsNaN(a):
+		return f.nan(a)
+	case f.isZero(a):
+		return a, 0
+	case f.sign(a):
+		return f.NaN(), FFlagsNV
+	case f.isInf(a):
+		return a, 0
+	}
+	_, e, m := f.unpack(a)
+	// The exponent is made even, the square root of the 128-bit significand has its highest bit at 63.
+	x := u128{m >> 1, m << 63}
+	if e&1 != 0 {
+		x, e = u128{m, 0}, e-1
+	}
+	s, exact := x.sqrt()
+	if !exact {
+		s |= 1
+	}
+	return f.round(false, e/2, s, rm)
+}
+
+// Eq reports whether a equals b. The comparison is quiet, only signaling NaNs raise NV.
+func (f SoftFloat) Eq(a uint64, b uint64) (bool, uint64) {
+	if f.IsNaN(a) || f.IsNaN(b) {
+		_, flags := f.nan(a, b)
+		return false, flags
+	}
+	return a == b || f.isZero(a|b), 0
+}
+
+// Lt reports whether a is less than b. The comparison is signaling, NaNs raise NV.
+func (f SoftFloat) Lt(a uint64, b uint64) (bool, uint64) {
+	if f.IsNaN(a) || f.IsNaN(b) {
+		return false, FFlagsNV
+	}
+	return f.less(a, b) && !f.isZero(a|b), 0
+}
+
+// Le reports whether a is less than or equal to b. The comparison is signaling, NaNs raise NV.
+func (f SoftFloat) Le(a uint64, b uint64) (bool, uint64) {
+	if f.IsNaN(a) || f.IsNaN(b) {
+		return false, FFlagsNV
+	}
+	return !f.less(b, a) || f.isZero(a|b), 0
+}
+
+// less reports whether a is less than b, which are not NaNs. -0 is less than +0.
+func (f SoftFloat) less(a uint64, b uint64) bool {
+	if f.sign(a) != f.sign(b) {
+		return f.sign(a)
+	}
+	if f.sign(a) {
+		return a > b
+	}
+	return a < b
+}
+
+// Min returns the smaller of a and b, -0 being smaller than +0. If one of them is a NaN, the result is the other one,
+// or the canonical NaN if both are. Signaling NaNs raise NV.
+func (f SoftFloat) Min(a uint64, b uint64) (uint64, uint64) {
+	return f.minMax(a, b, false)
+}
+
+// Max returns the larger of a and b, see Min.
+func (f SoftFloat) Max(a uint64, b uint64) (uint64, uint64) {
+	return f.minMax(a, b, true)
+}
+
+func (f SoftFloat) minMax(a uint64, b uint64, max bool) (uint64, uint64) {
+	_, flags := f.nan(a, b)
+	switch {
+	case f.IsNaN(a) && f.IsNaN(b):
+		return f.NaN(), flags
+	case f.IsNaN(a):
+		return b, flags
+	case f.IsNaN(b):
+		return a, flags
+	case f.less(a, b) != max:
+		return a, 0
+	}
+	return b, 0
+}
+
+// Convert returns a converted to the format to.
+func (f SoftFloat) Convert(a uint64, to SoftFloat, rm uint64) (uint64, uint64) {
+	switch {
+	case f.IsNaN(a):
+		_, flags := f.nan(a)
+		return to.NaN(), flags
+	case f.isInf(a):
+		return to.signed(f.sign(a), to.inf()), 0
+	case f.isZero(a):
+		return to.signed(f.sign(a), 0), 0
+	}
+	s, e, m := f.unpack(a)
+	return to.round(s, e, m, rm)
+}
+
+// FromInt returns the integer v, signed or not, converted to the format.
+func (f SoftFloat) FromInt(v uint64, signed bool, rm uint64) (uint64, uint64) {
+	sign := signed && int64(v) < 0
+	if sign {
+		v = -v
+	}
+	if v == 0 {
+		return 0, 0
+	}
+	n := bits.LeadingZeros64(v)
+	return f.round(sign, 63-n, v<<n, rm)
+}
+
+// ToInt returns a rounded to an integer of n bits, signed or not, sign extended to 64 bits if it is signed. A NaN or a
+// number out of range raises NV alone, the result is then the largest integer, or the smallest one if a is negative.
+func (f SoftFloat) ToInt(a uint64, n uint, signed bool, rm uint64) (uint64, uint64) {
+	max := uint64(1)<<n - 1
+	if signed {
+		max >>= 1
+	}
+	sign := f.sign(a)
+	var (
+		m       uint64
+		inexact bool
+		large   bool
+	)
+	switch {
+	case f.IsNaN(a):
+		return max, FFlagsNV
+	case f.isInf(a):
+		large = true
+	case !f.isZero(a):
+		_, e, sig := f.unpack(a)
+		if e > 63 {
+			large = true
+		} else {
+			m, inexact = softRound(sign, sig, uint(63-e), rm)
+		}
+	}
+	switch {
+	case signed && (large || !sign && m > max || sign && m > max+1):
+		if sign {
+			return -(max + 1), FFlagsNV
+		}
+		return max, FFlagsNV
+	case !signed && (large || m > max || sign && m != 0):
+		if sign {
+			return 0, FFlagsNV
+		}
+		return max, FFlagsNV
+	}
+	var flags uint64
+	if inexact {
+		flags = FFlagsNX
+	}
+	if sign {
+		m = -m
+	}
+	return m, flags
+}
+
+// u128 is an unsigned integer of 128 bits.
+type u128 struct {
+	hi uint64
+	lo uint64
+}
+
+func (x u128) zero() bool {
+	return x.hi|x.lo == 0
+}
+
+func (x u128) less(y u128) bool {
+	return x.hi < y.hi || x.hi == y.hi && x.lo < y.lo
+}
+
+func (x u128) add(y u128) u128 {
+	lo, carry := bits.Add64(x.lo, y.lo, 0)
+	hi, _ := bits.Add64(x.hi, y.hi, carry)
+	return u128{hi, lo}
+}
+
+func (x u128) sub(y u128) u128 {
+	lo, borrow := bits.Sub64(x.lo, y.lo, 0)
+	hi, _ := bits.Sub64(x.hi, y.hi, borrow)
+	return u128{hi, lo}
+}
+
+// shl shifts x left by n bits, n less than 128.
+func (x u128) shl(n uint) u128 {
+	if n >= 64 {
+		return u128{x.lo << (n - 64), 0}
+	}
+	return u128{x.hi<<n | x.lo>>(64-n), x.lo << n}
+}
+
+// shr shifts x right by n bits, n less than 128.
+func (x u128) shr(n uint) u128 {
+	if n >= 64 {
+		return u128{0, x.hi >> (n - 64)}
+	}
+	return u128{x.hi >> n, x.lo>>n | x.hi<<(64-n)}
+}
+
+// jam shifts x right by n bits, and sets the lowest bit of the result if bits that were not zero were dropped.
+func (x u128) jam(n uint) u128 {
+	if n >= 128 {
+		if x.zero() {
+			return x
+		}
+		return u128{0, 1}
+	}
+	r := x.shr(n)
+	if r.shl(n) != x {
+		r.lo |= 1
+	}
+	return r
+}
+
+// lz returns the number of leading zero bits of x.
+func (x u128) lz() uint {
+	if x.hi != 0 {
+		return uint(bits.LeadingZeros64(x.hi))
+	}
+	return 64 + uint(bits.LeadingZeros64(x.lo))
+}
+
+// sqrt returns the integer square root of x, and whether it is exact. It computes one bit of the root at a time.
+func (x u128) sqrt() (uint64, bool) {
+	var r u128
+	for one := (u128{1 << 62, 0}); !one.zero(); one = one.shr(2) {
+		t := r.add(one)
+		r = r.shr(1)
+		if !x.less(t) {
+			x = x.sub(t)
+			r = r.add(one)
+		}
+	}
+	return r.lo, x.zero()
+}
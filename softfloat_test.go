@@ -0,0 +1,123 @@
+package rv64
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// softFloatOps are the operations of the test vectors, see testdata/softfloat/gen.c.
+func softFloatOps() map[string]func(rm uint64, x []uint64) (uint64, uint64) {
+	b2u := func(b bool, flags uint64) (uint64, uint64) {
+		if b {
+			return 1, flags
+		}
+		return 0, flags
+	}
+	ops := map[string]func(rm uint64, x []uint64) (uint64, uint64){}
+	for _, f := range []SoftFloat{SoftFloat32, SoftFloat64} {
+		f := f
+		p := fmt.Sprintf("f%d_", f.w)
+		ops[p+"add"] = func(rm uint64, x []uint64) (uint64, uint64) { return f.Add(x[0], x[1], rm) }
+		ops[p+"sub"] = func(rm uint64, x []uint64) (uint64, uint64) { return f.Sub(x[0], x[1], rm) }
+		ops[p+"mul"] = func(rm uint64, x []uint64) (uint64, uint64) { return f.Mul(x[0], x[1], rm) }
+		ops[p+"div"] = func(rm uint64, x []uint64) (uint64, uint64) { return f.Div(x[0], x[1], rm) }
+		ops[p+"sqrt"] = func(rm uint64, x []uint64) (uint64, uint64) { return f.Sqrt(x[0], rm) }
+		ops[p+"mulAdd"] = func(rm uint64, x []uint64) (uint64, uint64) { return f.MulAdd(x[0], x[1], x[2], rm) }
+		ops[p+"eq"] = func(rm uint64, x []uint64) (uint64, uint64) { return b2u(f.Eq(x[0], x[1])) }
+		ops[p+"lt"] = func(rm uint64, x []uint64) (uint64, uint64) { return b2u(f.Lt(x[0], x[1])) }
+		ops[p+"le"] = func(rm uint64, x []uint64) (uint64, uint64) { return b2u(f.Le(x[0], x[1])) }
+		ops[p+"min"] = func(rm uint64, x []uint64) (uint64, uint64) { return f.Min(x[0], x[1]) }
+		ops[p+"max"] = func(rm uint64, x []uint64) (uint64, uint64) { return f.Max(x[0], x[1]) }
+		for _, n := range []uint{32, 64} {
+			n := n
+			mask := uint64(1)<<n - 1
+			ops[fmt.Sprintf("%sto_i%d", p, n)] = func(rm uint64, x []uint64) (uint64, uint64) {
+				r, flags := f.ToInt(x[0], n, true, rm)
+				return r & mask, flags
+			}
+			ops[fmt.Sprintf("%sto_ui%d", p, n)] = func(rm uint64, x []uint64) (uint64, uint64) {
+				return f.ToInt(x[0], n, false, rm)
+			}
+			ops[fmt.Sprintf("i%d_to_f%d", n, f.w)] = func(rm uint64, x []uint64) (uint64, uint64) {
+				return f.FromInt(SignExtend(x[0], uint64(n-1)), true, rm)
+			}
+			ops[fmt.Sprintf("ui%d_to_f%d", n, f.w)] = func(rm uint64, x []uint64) (uint64, uint64) {
+				return f.FromInt(x[0], false, rm)
+			}
+		}
+	}
+	ops["f32_to_f64"] = func(rm uint64, x []uint64) (uint64, uint64) { return SoftFloat32.Convert(x[0], SoftFloat64, rm) }
+	ops["f64_to_f32"] = func(rm uint64, x []uint64) (uint64, uint64) { return SoftFloat64.Convert(x[0], SoftFloat32, rm) }
+	return ops
+}
+
+func TestSoftFloatVectors(t *testing.T) {
+	file, err := os.Open("testdata/softfloat/vectors.txt.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	r, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ops := softFloatOps()
+	s := bufio.NewScanner(r)
+	n, fail := 0, 0
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		op, ok := ops[fields[0]]
+		if !ok {
+			t.Fatal("unknown operation", fields[0])
+		}
+		v := make([]uint64, len(fields)-1)
+		for i, e := range fields[1:] {
+			if v[i], err = strconv.ParseUint(e, 16, 64); err != nil {
+				t.Fatal(err)
+			}
+		}
+		rm, x, want, wantFlags := v[0], v[1:len(v)-2], v[len(v)-2], v[len(v)-1]
+		n++
+		if got, flags := op(rm, x); got != want || flags != wantFlags {
+			t.Errorf("%s: got %x %02x", s.Text(), got, flags)
+			if fail++; fail >= 20 {
+				t.FailNow()
+			}
+		}
+	}
+	if err := s.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if n == 0 {
+		t.Fatal("no vectors")
+	}
+}
+
+func TestCPUFloatFlags(t *testing.T) {
+	c := testCPU(map[uint64]uint32{
+		0x1000: 0x1ab57753, // fdiv.d fa4, fa0, fa1
+		0x1004: 0x02d677d3, // fadd.d fa5, fa2, fa3
+		0x1008: 0xc2081553, // fcvt.w.d a0, fa6, rtz
+		0x100c: 0x00102573, // frflags a0
+		0x1010: 0x05d00893, // li a7, 93
+		0x1014: 0x00000073, // ecall
+	})
+	c.SetRegisterFloatAsFloat64(Rfa0, 1)
+	c.SetRegisterFloatAsFloat64(Rfa2, 1)
+	c.SetRegisterFloatAsFloat64(Rfa3, math.Ldexp(1, -60))
+	c.SetRegisterFloat(Rfa6, NaN64)
+	c.SetPC(0x1000)
+	// The flags of the three instructions accrue.
+	if r := c.Run(); uint64(r) != FFlagsDZ|FFlagsNX|FFlagsNV {
+		t.Fatalf("%#x", r)
+	}
+	if r := c.GetRegisterFloatAsFloat64(Rfa4); !math.IsInf(r, 1) {
+		t.Fatal(r)
+	}
+}
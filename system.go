@@ -1,32 +1,923 @@
 package rv64
 
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"syscall"
+	"time"
+)
+
 type System interface {
 	HandleCall(*CPU) (uint64, error)
 	Code() uint8
 }
 
+// File is the interface that a guest file descriptor refers to. *os.File satisfies it. Name returns the guest path of
+// the file.
+type File interface {
+	io.Reader
+	io.Writer
+	io.Seeker
+	io.Closer
+	Name() string
+	Stat() (os.FileInfo, error)
+	ReadDir(int) ([]fs.DirEntry, error)
+}
+
+// SystemStandard implements a subset of the Linux RV64 syscall interface. Guest file descriptors are mapped onto files
+// of FS, descriptors 0, 1 and 2 are the standard streams of the emulator. Relative paths are resolved against Cwd.
+// Heap must be set before the guest calls brk or mmap. getrandom reads from Random.
 type SystemStandard struct {
 	ExitCode uint8
+	Files    []File
+	FS       VFS
+	Cwd      string
+	Heap     *Heap
+	Random   io.Reader
+	dirents  map[uint64]*dirents
+}
+
+// systemRWMax is the most bytes a read or write transfers, MAX_RW_COUNT of Linux. systemChunk is the size of the
+// host buffer they are split into.
+const (
+	systemRWMax uint64 = 0x7ffff000
+	systemChunk uint64 = 64 * 1024
+)
+
+// dirents holds the entries of a directory being read by getdents64.
+type dirents struct {
+	list []fs.DirEntry
+	next int
 }
 
 func (s *SystemStandard) HandleCall(c *CPU) (uint64, error) {
-	code := c.GetRegister(Ra7)
-	switch code {
-	case 0x005d:
+	var (
+		r   uint64
+		err error
+	)
+	switch c.GetRegister(Ra7) {
+	case SyscallMkdirat:
+		r, err = s.mkdirat(c)
+	case SyscallUnlinkat:
+		r, err = s.unlinkat(c)
+	case SyscallFaccessat:
+		r, err = s.faccessat(c)
+	case SyscallOpenat:
+		r, err = s.openat(c)
+	case SyscallClose:
+		r, err = s.close(c)
+	case SyscallGetdents64:
+		r, err = s.getdents64(c)
+	case SyscallLseek:
+		r, err = s.lseek(c)
+	case SyscallRead:
+		r, err = s.read(c)
+	case SyscallWrite:
+		r, err = s.write(c)
+	case SyscallWritev:
+		r, err = s.writev(c)
+	case SyscallPread64:
+		r, err = s.pread64(c)
+	case SyscallReadlinkat:
+		r, err = s.readlinkat(c)
+	case SyscallNewfstatat:
+		r, err = s.newfstatat(c)
+	case SyscallFstat:
+		r, err = s.fstat(c)
+	case SyscallClockGet:
+		r, err = s.clockGettime(c)
+	case SyscallClockRes:
+		r, err = s.clockGetres(c)
+	case SyscallTimeOfDay:
+		r, err = s.gettimeofday(c)
+	case SyscallTime:
+		r, err = s.time(c)
+	case SyscallBrk:
+		r, err = s.brk(c)
+	case SyscallMunmap:
+		r, err = s.munmap(c)
+	case SyscallMmap:
+		r, err = s.mmap(c)
+	case SyscallMprotect:
+		r, err = s.mprotect(c)
+	case SyscallSetTidAddress, SyscallGetpid, SyscallGettid:
+		r, err = systemPid, nil
+	case SyscallGetppid, SyscallGetuid, SyscallGeteuid, SyscallGetgid, SyscallGetegid:
+		r, err = 0, nil
+	case SyscallSetRobustList:
+		r, err = 0, nil
+	case SyscallRtSigaction:
+		r, err = s.rtSigaction(c)
+	case SyscallRtSigprocmask:
+		r, err = s.rtSigprocmask(c)
+	case SyscallUname:
+		r, err = s.uname(c)
+	case SyscallPrlimit64:
+		r, err = s.prlimit64(c)
+	case SyscallGetrandom:
+		r, err = s.getrandom(c)
+	case SyscallExit, SyscallExitGroup:
 		s.ExitCode = uint8(c.GetRegister(Ra0))
 		c.SetStatus(1)
 		c.SetPC(c.GetPC() + 4)
 		return 1, nil
+	default:
+		return 0, ErrAbnormalEcall
 	}
-	return 0, ErrAbnormalEcall
+	if err != nil {
+		return 0, err
+	}
+	c.SetRegister(Ra0, r)
+	c.SetPC(c.GetPC() + 4)
+	return 1, nil
 }
 
 func (s *SystemStandard) Code() uint8 {
 	return s.ExitCode
 }
 
+// File returns the file referred by the guest file descriptor fd, or nil if fd is not open.
+func (s *SystemStandard) File(fd uint64) File {
+	if fd >= uint64(len(s.Files)) {
+		return nil
+	}
+	return s.Files[fd]
+}
+
+// Open installs f at the lowest unused file descriptor and returns the descriptor.
+func (s *SystemStandard) Open(f File) uint64 {
+	for i, e := range s.Files {
+		if e == nil {
+			s.Files[i] = f
+			return uint64(i)
+		}
+	}
+	s.Files = append(s.Files, f)
+	return uint64(len(s.Files) - 1)
+}
+
+// path reads a guest path from memory at a and makes it absolute. Relative paths are resolved against the directory
+// referred by dirfd.
+func (s *SystemStandard) path(c *CPU, dirfd uint64, a uint64) (string, uint64) {
+	p, err := c.GetMemory().GetString(a)
+	if err != nil {
+		return "", ErrnoEFAULT
+	}
+	if p == "" {
+		return "", ErrnoENOENT
+	}
+	if path.IsAbs(p) {
+		return path.Clean(p), 0
+	}
+	if dirfd == LinuxATFdcwd {
+		return path.Join(s.Cwd, p), 0
+	}
+	f := s.File(dirfd)
+	if f == nil {
+		return "", ErrnoEBADF
+	}
+	return path.Join(f.Name(), p), 0
+}
+
+func (s *SystemStandard) mkdirat(c *CPU) (uint64, error) {
+	p, e := s.path(c, c.GetRegister(Ra0), c.GetRegister(Ra1))
+	if e != 0 {
+		return SyscallError(e), nil
+	}
+	if err := s.FS.Mkdir(p, os.FileMode(c.GetRegister(Ra2)&0o777)); err != nil {
+		return SyscallError(hostErrno(err)), nil
+	}
+	return 0, nil
+}
+
+func (s *SystemStandard) unlinkat(c *CPU) (uint64, error) {
+	p, e := s.path(c, c.GetRegister(Ra0), c.GetRegister(Ra1))
+	if e != 0 {
+		return SyscallError(e), nil
+	}
+	info, err := s.FS.Lstat(p)
+	if err != nil {
+		return SyscallError(hostErrno(err)), nil
+	}
+	if c.GetRegister(Ra2)&LinuxATRemovedir != 0 {
+		if !info.IsDir() {
+			return SyscallError(ErrnoENOTDIR), nil
+		}
+	} else if info.IsDir() {
+		return SyscallError(ErrnoEISDIR), nil
+	}
+	if err := s.FS.Remove(p); err != nil {
+		return SyscallError(hostErrno(err)), nil
+	}
+	return 0, nil
+}
+
+func (s *SystemStandard) faccessat(c *CPU) (uint64, error) {
+	p, e := s.path(c, c.GetRegister(Ra0), c.GetRegister(Ra1))
+	if e != 0 {
+		return SyscallError(e), nil
+	}
+	// The guest runs as root, it may access any file that exists.
+	if _, err := s.FS.Stat(p); err != nil {
+		return SyscallError(hostErrno(err)), nil
+	}
+	return 0, nil
+}
+
+func (s *SystemStandard) openat(c *CPU) (uint64, error) {
+	p, e := s.path(c, c.GetRegister(Ra0), c.GetRegister(Ra1))
+	if e != 0 {
+		return SyscallError(e), nil
+	}
+	flag := c.GetRegister(Ra2)
+	mode := os.FileMode(c.GetRegister(Ra3) & 0o777)
+	f, err := s.FS.Open(p, hostOpenFlag(flag), mode)
+	if err != nil {
+		return SyscallError(hostErrno(err)), nil
+	}
+	if flag&LinuxODirectory != 0 {
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return SyscallError(hostErrno(err)), nil
+		}
+		if !info.IsDir() {
+			f.Close()
+			return SyscallError(ErrnoENOTDIR), nil
+		}
+	}
+	return s.Open(f), nil
+}
+
+func (s *SystemStandard) close(c *CPU) (uint64, error) {
+	fd := c.GetRegister(Ra0)
+	f := s.File(fd)
+	if f == nil {
+		return SyscallError(ErrnoEBADF), nil
+	}
+	s.Files[fd] = nil
+	delete(s.dirents, fd)
+	// The standard streams are shared with the emulator and are never closed on the host.
+	if fd <= 2 {
+		return 0, nil
+	}
+	if err := f.Close(); err != nil {
+		return SyscallError(hostErrno(err)), nil
+	}
+	return 0, nil
+}
+
+func (s *SystemStandard) getdents64(c *CPU) (uint64, error) {
+	fd := c.GetRegister(Ra0)
+	f := s.File(fd)
+	if f == nil {
+		return SyscallError(ErrnoEBADF), nil
+	}
+	if s.dirents == nil {
+		s.dirents = map[uint64]*dirents{}
+	}
+	d, ok := s.dirents[fd]
+	if !ok {
+		r, err := f.ReadDir(-1)
+		if err != nil {
+			return SyscallError(hostErrno(err)), nil
+		}
+		d = &dirents{list: append([]fs.DirEntry{vfsEntry{vfsDot{"."}}, vfsEntry{vfsDot{".."}}}, r...)}
+		s.dirents[fd] = d
+	}
+	// struct linux_dirent64 { u64 d_ino; s64 d_off; u16 d_reclen; u8 d_type; char d_name[]; }
+	b := []byte{}
+	next := d.next
+	for ; next < len(d.list); next++ {
+		name := d.list[next].Name()
+		n := (19 + len(name) + 1 + 7) &^ 7
+		if uint64(len(b)+n) > c.GetRegister(Ra2) {
+			break
+		}
+		e := make([]byte, n)
+		binary.LittleEndian.PutUint64(e[0:], uint64(next+1))
+		binary.LittleEndian.PutUint64(e[8:], uint64(next+1))
+		binary.LittleEndian.PutUint16(e[16:], uint16(n))
+		e[18] = byte(linuxDirentType(d.list[next].Type()))
+		copy(e[19:], name)
+		b = append(b, e...)
+	}
+	if len(b) == 0 && next < len(d.list) {
+		return SyscallError(ErrnoEINVAL), nil
+	}
+	if err := c.GetMemory().SetByte(c.GetRegister(Ra1), b); err != nil {
+		return SyscallError(ErrnoEFAULT), nil
+	}
+	d.next = next
+	return uint64(len(b)), nil
+}
+
+func (s *SystemStandard) lseek(c *CPU) (uint64, error) {
+	f := s.File(c.GetRegister(Ra0))
+	if f == nil {
+		return SyscallError(ErrnoEBADF), nil
+	}
+	whence := c.GetRegister(Ra2)
+	if whence > io.SeekEnd {
+		return SyscallError(ErrnoEINVAL), nil
+	}
+	r, err := f.Seek(int64(c.GetRegister(Ra1)), int(whence))
+	if err != nil {
+		return SyscallError(hostErrno(err)), nil
+	}
+	return uint64(r), nil
+}
+
+func (s *SystemStandard) read(c *CPU) (uint64, error) {
+	f := s.File(c.GetRegister(Ra0))
+	if f == nil {
+		return SyscallError(ErrnoEBADF), nil
+	}
+	return systemChunks(c.GetRegister(Ra1), c.GetRegister(Ra2), func(a uint64, b []byte) (int, uint64) {
+		n, err := f.Read(b)
+		if err != nil && err != io.EOF {
+			return 0, hostErrno(err)
+		}
+		if err := c.GetMemory().SetByte(a, b[:n]); err != nil {
+			return 0, ErrnoEFAULT
+		}
+		return n, 0
+	}), nil
+}
+
+func (s *SystemStandard) pread64(c *CPU) (uint64, error) {
+	f := s.File(c.GetRegister(Ra0))
+	if f == nil {
+		return SyscallError(ErrnoEBADF), nil
+	}
+	if int64(c.GetRegister(Ra3)) < 0 {
+		return SyscallError(ErrnoEINVAL), nil
+	}
+	buf := c.GetRegister(Ra1)
+	return systemChunks(buf, c.GetRegister(Ra2), func(a uint64, b []byte) (int, uint64) {
+		n, err := readAt(f, b, int64(c.GetRegister(Ra3)+a-buf))
+		if err != nil {
+			return 0, hostErrno(err)
+		}
+		if err := c.GetMemory().SetByte(a, b[:n]); err != nil {
+			return 0, ErrnoEFAULT
+		}
+		return n, 0
+	}), nil
+}
+
+func (s *SystemStandard) write(c *CPU) (uint64, error) {
+	f := s.File(c.GetRegister(Ra0))
+	if f == nil {
+		return SyscallError(ErrnoEBADF), nil
+	}
+	return systemChunks(c.GetRegister(Ra1), c.GetRegister(Ra2), s.writer(c, f)), nil
+}
+
+// writer returns the chunk function of systemChunks that writes guest memory to f.
+func (s *SystemStandard) writer(c *CPU, f File) func(a uint64, b []byte) (int, uint64) {
+	return func(a uint64, b []byte) (int, uint64) {
+		if err := c.GetMemory().get(a, b); err != nil {
+			return 0, ErrnoEFAULT
+		}
+		n, err := f.Write(b)
+		if err != nil {
+			return n, hostErrno(err)
+		}
+		return n, 0
+	}
+}
+
+func (s *SystemStandard) writev(c *CPU) (uint64, error) {
+	f := s.File(c.GetRegister(Ra0))
+	if f == nil {
+		return SyscallError(ErrnoEBADF), nil
+	}
+	// struct iovec { void *iov_base; size_t iov_len; }
+	iov := c.GetRegister(Ra1)
+	var r uint64
+	for i := uint64(0); i < c.GetRegister(Ra2); i++ {
+		base, err := c.GetMemory().GetUint64(iov + i*16)
+		if err != nil {
+			return SyscallError(ErrnoEFAULT), nil
+		}
+		size, err := c.GetMemory().GetUint64(iov + i*16 + 8)
+		if err != nil {
+			return SyscallError(ErrnoEFAULT), nil
+		}
+		n := systemChunks(base, size, s.writer(c, f))
+		if int64(n) < 0 {
+			if r != 0 {
+				return r, nil
+			}
+			return n, nil
+		}
+		r += n
+		if n < size {
+			break
+		}
+	}
+	return r, nil
+}
+
+func (s *SystemStandard) readlinkat(c *CPU) (uint64, error) {
+	p, e := s.path(c, c.GetRegister(Ra0), c.GetRegister(Ra1))
+	if e != 0 {
+		return SyscallError(e), nil
+	}
+	l, err := s.FS.Readlink(p)
+	if err != nil {
+		return SyscallError(hostErrno(err)), nil
+	}
+	b := []byte(l)
+	if uint64(len(b)) > c.GetRegister(Ra3) {
+		b = b[:c.GetRegister(Ra3)]
+	}
+	if err := c.GetMemory().SetByte(c.GetRegister(Ra2), b); err != nil {
+		return SyscallError(ErrnoEFAULT), nil
+	}
+	return uint64(len(b)), nil
+}
+
+func (s *SystemStandard) newfstatat(c *CPU) (uint64, error) {
+	p, err := c.GetMemory().GetString(c.GetRegister(Ra1))
+	if err != nil {
+		return SyscallError(ErrnoEFAULT), nil
+	}
+	flag := c.GetRegister(Ra3)
+	var info os.FileInfo
+	if p == "" && flag&LinuxATEmptyPath != 0 {
+		f := s.File(c.GetRegister(Ra0))
+		if f == nil {
+			return SyscallError(ErrnoEBADF), nil
+		}
+		info, err = f.Stat()
+	} else {
+		p, e := s.path(c, c.GetRegister(Ra0), c.GetRegister(Ra1))
+		if e != 0 {
+			return SyscallError(e), nil
+		}
+		if flag&LinuxATSymlinkNofollow != 0 {
+			info, err = s.FS.Lstat(p)
+		} else {
+			info, err = s.FS.Stat(p)
+		}
+	}
+	if err != nil {
+		return SyscallError(hostErrno(err)), nil
+	}
+	if err := setStat(c, c.GetRegister(Ra2), info); err != nil {
+		return SyscallError(ErrnoEFAULT), nil
+	}
+	return 0, nil
+}
+
+func (s *SystemStandard) fstat(c *CPU) (uint64, error) {
+	f := s.File(c.GetRegister(Ra0))
+	if f == nil {
+		return SyscallError(ErrnoEBADF), nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return SyscallError(hostErrno(err)), nil
+	}
+	if err := setStat(c, c.GetRegister(Ra1), info); err != nil {
+		return SyscallError(ErrnoEFAULT), nil
+	}
+	return 0, nil
+}
+
+// clock returns the time of the clock id, or false if the id is unknown.
+func (s *SystemStandard) clock(c *CPU, id uint64) (time.Duration, bool) {
+	switch id {
+	case LinuxClockRealtime, LinuxClockRealtimeCoarse:
+		return time.Duration(c.GetClock().Now(c).UnixNano()), true
+	case LinuxClockMonotonic, LinuxClockMonotonicRaw, LinuxClockMonotonicCoarse, LinuxClockBoottime:
+		return c.GetClock().Since(c), true
+	case LinuxClockProcessCputime, LinuxClockThreadCputime:
+		// The guest is the only process on the hart, it has been running all the time.
+		return c.GetClock().Since(c), true
+	}
+	return 0, false
+}
+
+func (s *SystemStandard) clockGettime(c *CPU) (uint64, error) {
+	d, ok := s.clock(c, c.GetRegister(Ra0))
+	if !ok {
+		return SyscallError(ErrnoEINVAL), nil
+	}
+	if err := setTimespec(c, c.GetRegister(Ra1), d, time.Nanosecond); err != nil {
+		return SyscallError(ErrnoEFAULT), nil
+	}
+	return 0, nil
+}
+
+func (s *SystemStandard) clockGetres(c *CPU) (uint64, error) {
+	if _, ok := s.clock(c, c.GetRegister(Ra0)); !ok {
+		return SyscallError(ErrnoEINVAL), nil
+	}
+	if c.GetRegister(Ra1) == 0 {
+		return 0, nil
+	}
+	if err := setTimespec(c, c.GetRegister(Ra1), time.Second/time.Duration(ClockFrequency), time.Nanosecond); err != nil {
+		return SyscallError(ErrnoEFAULT), nil
+	}
+	return 0, nil
+}
+
+func (s *SystemStandard) gettimeofday(c *CPU) (uint64, error) {
+	d := time.Duration(c.GetClock().Now(c).UnixNano())
+	if a := c.GetRegister(Ra0); a != 0 {
+		if err := setTimespec(c, a, d, time.Microsecond); err != nil {
+			return SyscallError(ErrnoEFAULT), nil
+		}
+	}
+	// The guest is always in UTC. struct timezone is two ints.
+	if a := c.GetRegister(Ra1); a != 0 {
+		if err := c.GetMemory().SetUint64(a, 0); err != nil {
+			return SyscallError(ErrnoEFAULT), nil
+		}
+	}
+	return 0, nil
+}
+
+func (s *SystemStandard) time(c *CPU) (uint64, error) {
+	r := uint64(c.GetClock().Now(c).Unix())
+	if a := c.GetRegister(Ra0); a != 0 {
+		if err := c.GetMemory().SetUint64(a, r); err != nil {
+			return SyscallError(ErrnoEFAULT), nil
+		}
+	}
+	return r, nil
+}
+
+func (s *SystemStandard) brk(c *CPU) (uint64, error) {
+	if s.Heap == nil {
+		return 0, nil
+	}
+	return s.Heap.SetBrk(c.GetMemory(), c.GetRegister(Ra0)), nil
+}
+
+func (s *SystemStandard) munmap(c *CPU) (uint64, error) {
+	if s.Heap == nil {
+		return SyscallError(ErrnoEINVAL), nil
+	}
+	if e := s.Heap.Munmap(c.GetRegister(Ra0), c.GetRegister(Ra1)); e != 0 {
+		return SyscallError(e), nil
+	}
+	if err := c.GetMemory().Protect(c.GetRegister(Ra0), PageAlignUp(c.GetRegister(Ra1)), 0); err != nil {
+		return SyscallError(ErrnoEINVAL), nil
+	}
+	c.GetMemory().Release(c.GetRegister(Ra0), PageAlignUp(c.GetRegister(Ra1)))
+	return 0, nil
+}
+
+func (s *SystemStandard) mmap(c *CPU) (uint64, error) {
+	if s.Heap == nil {
+		return SyscallError(ErrnoENOMEM), nil
+	}
+	size := c.GetRegister(Ra1)
+	prot := c.GetRegister(Ra2)
+	flag := c.GetRegister(Ra3)
+	var f File
+	if flag&LinuxMapAnonymous == 0 {
+		f = s.File(c.GetRegister(Ra4))
+		if f == nil {
+			return SyscallError(ErrnoEBADF), nil
+		}
+		if c.GetRegister(Ra5) != PageAlignDown(c.GetRegister(Ra5)) {
+			return SyscallError(ErrnoEINVAL), nil
+		}
+		// A mapping is a private copy of the file. Shared mappings are fine as long as the guest can not write to them.
+		if flag&LinuxMapShared != 0 && prot&LinuxProtWrite != 0 {
+			return SyscallError(ErrnoENODEV), nil
+		}
+	} else if flag&LinuxMapShared != 0 {
+		return SyscallError(ErrnoEINVAL), nil
+	}
+	r, e := s.Heap.Mmap(c.GetMemory(), c.GetRegister(Ra0), size, flag&LinuxMapFixed != 0)
+	if e != 0 {
+		return SyscallError(e), nil
+	}
+	if f != nil {
+		// The file is copied in chunks up to its end, the rest of the mapping stays zero.
+		n := systemChunks(r, size, func(a uint64, b []byte) (int, uint64) {
+			n, err := readAt(f, b, int64(c.GetRegister(Ra5)+a-r))
+			if err == nil {
+				err = c.GetMemory().SetByte(a, b[:n])
+			}
+			if err != nil {
+				return 0, hostErrno(err)
+			}
+			return n, 0
+		})
+		if int64(n) < 0 {
+			s.Heap.Munmap(r, size)
+			c.GetMemory().Protect(r, PageAlignUp(size), 0)
+			return n, nil
+		}
+	}
+	if err := c.GetMemory().Protect(r, PageAlignUp(size), linuxPerm(prot)); err != nil {
+		s.Heap.Munmap(r, size)
+		c.GetMemory().Protect(r, PageAlignUp(size), 0)
+		return SyscallError(ErrnoENOMEM), nil
+	}
+	return r, nil
+}
+
+func (s *SystemStandard) mprotect(c *CPU) (uint64, error) {
+	a := c.GetRegister(Ra0)
+	size := PageAlignUp(c.GetRegister(Ra1))
+	if a != PageAlignDown(a) {
+		return SyscallError(ErrnoEINVAL), nil
+	}
+	if c.GetRegister(Ra1) == 0 {
+		return 0, nil
+	}
+	if a+size <= a || !s.mapped(c, a, size) {
+		return SyscallError(ErrnoENOMEM), nil
+	}
+	if err := c.GetMemory().Protect(a, size, linuxPerm(c.GetRegister(Ra2))); err != nil {
+		return SyscallError(ErrnoENOMEM), nil
+	}
+	return 0, nil
+}
+
+// mapped reports whether [a, a+size) is mapped: every byte of it is accessible, or is part of the program break or of
+// a mapping of Heap, which may have been made inaccessible by mprotect. Memory without permissions is all mapped.
+func (s *SystemStandard) mapped(c *CPU, a uint64, size uint64) bool {
+	r, ok := c.GetMemory().Regions()
+	if !ok {
+		return true
+	}
+	if s.Heap != nil {
+		r = append(r, Region{Addr: s.Heap.Base, Size: PageAlignUp(s.Heap.Brk) - s.Heap.Base})
+		for _, e := range s.Heap.Maps {
+			r = append(r, Region{Addr: e.Addr, Size: e.Size})
+		}
+	}
+	for end := a + size; a < end; {
+		next := a
+		for _, e := range r {
+			if a >= e.Addr && a-e.Addr < e.Size && e.Addr+e.Size > next {
+				next = e.Addr + e.Size
+			}
+		}
+		if next == a {
+			return false
+		}
+		a = next
+	}
+	return true
+}
+
+// NewSystemStandard returns a system which lets the guest read the whole host file system. Its changes stay in memory,
+// the host file system is never modified. Set FS to give the guest more, or less.
 func NewSystemStandard() *SystemStandard {
+	cwd, err := os.Getwd()
+	if err != nil {
+		cwd = "/"
+	}
 	return &SystemStandard{
 		ExitCode: 0,
+		Files:    []File{os.Stdin, os.Stdout, os.Stderr},
+		FS:       NewVFSOverlay(&VFSHost{Root: "/", ReadOnly: true}, nil),
+		Cwd:      cwd,
+		Random:   rand.Reader,
+		dirents:  map[uint64]*dirents{},
+	}
+}
+
+// systemChunks transfers up to n bytes between guest memory at a and the host, and returns the value of a0. The host
+// buffer is bounded: the transfer is done in chunks by f, which returns the number of bytes it moved or an errno. The
+// transfer stops at the first short chunk, the bytes moved before an error are reported rather than the error. Like
+// Linux, a single transfer moves at most MAX_RW_COUNT bytes.
+func systemChunks(a uint64, n uint64, f func(a uint64, b []byte) (int, uint64)) uint64 {
+	if n > systemRWMax {
+		n = systemRWMax
+	}
+	var b []byte
+	if n < systemChunk {
+		b = make([]byte, n)
+	} else {
+		b = make([]byte, systemChunk)
+	}
+	var r uint64
+	for r < n {
+		m := n - r
+		if m > uint64(len(b)) {
+			m = uint64(len(b))
+		}
+		k, e := f(a+r, b[:m])
+		r += uint64(k)
+		if e != 0 {
+			if r != 0 {
+				return r
+			}
+			return SyscallError(e)
+		}
+		if uint64(k) < m {
+			break
+		}
+	}
+	return r
+}
+
+// setStat writes info to guest memory as a struct stat of the asm-generic layout, which is 128 bytes long:
+//
+// | Offset | Field      | Offset | Field         |
+// | ------ | ---------- | ------ | ------------- |
+// | 0x00   | st_dev     | 0x38   | st_blksize    |
+// | 0x08   | st_ino     | 0x40   | st_blocks     |
+// | 0x10   | st_mode    | 0x48   | st_atime      |
+// | 0x14   | st_nlink   | 0x50   | st_atime_nsec |
+// | 0x18   | st_uid     | 0x58   | st_mtime      |
+// | 0x1c   | st_gid     | 0x60   | st_mtime_nsec |
+// | 0x20   | st_rdev    | 0x68   | st_ctime      |
+// | 0x30   | st_size    | 0x70   | st_ctime_nsec |
+func setStat(c *CPU, a uint64, info os.FileInfo) error {
+	b := make([]byte, 128)
+	size := uint64(info.Size())
+	t := info.ModTime()
+	binary.LittleEndian.PutUint32(b[0x10:], uint32(linuxMode(info.Mode())))
+	binary.LittleEndian.PutUint32(b[0x14:], 1)
+	binary.LittleEndian.PutUint64(b[0x30:], size)
+	binary.LittleEndian.PutUint32(b[0x38:], 4096)
+	binary.LittleEndian.PutUint64(b[0x40:], (size+511)/512)
+	for _, e := range []int{0x48, 0x58, 0x68} {
+		binary.LittleEndian.PutUint64(b[e:], uint64(t.Unix()))
+		binary.LittleEndian.PutUint64(b[e+8:], uint64(t.Nanosecond()))
+	}
+	return c.GetMemory().SetByte(a, b)
+}
+
+// setTimespec writes d to guest memory as a struct timespec, or as a struct timeval if unit is time.Microsecond. Both
+// are two 64-bit integers: seconds and the remaining nanoseconds or microseconds.
+func setTimespec(c *CPU, a uint64, d time.Duration, unit time.Duration) error {
+	b := make([]byte, 16)
+	binary.LittleEndian.PutUint64(b[0:], uint64(d/time.Second))
+	binary.LittleEndian.PutUint64(b[8:], uint64(d%time.Second/unit))
+	return c.GetMemory().SetByte(a, b)
+}
+
+// readAt reads len(b) bytes of f at offset off without moving the file offset, like pread(2). Reading past the end of
+// the file is not an error, it returns the number of bytes read.
+func readAt(f File, b []byte, off int64) (int, error) {
+	if r, ok := f.(io.ReaderAt); ok {
+		n, err := r.ReadAt(b, off)
+		if err == io.EOF {
+			err = nil
+		}
+		return n, err
+	}
+	cur, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		return 0, err
+	}
+	n, err := io.ReadFull(f, b)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		err = nil
+	}
+	if _, err := f.Seek(cur, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return n, err
+}
+
+// linuxPerm converts the prot argument of mmap(2) to memory permissions. RISC-V has no write-only pages, write
+// permission implies read permission.
+func linuxPerm(prot uint64) uint8 {
+	var r uint8
+	if prot&(LinuxProtRead|LinuxProtWrite) != 0 {
+		r |= PermR
+	}
+	if prot&LinuxProtWrite != 0 {
+		r |= PermW
+	}
+	if prot&LinuxProtExec != 0 {
+		r |= PermX
+	}
+	return r
+}
+
+// linuxMode converts a Go file mode to the st_mode of Linux.
+func linuxMode(m os.FileMode) uint64 {
+	r := uint64(m.Perm())
+	switch {
+	case m&os.ModeDir != 0:
+		r |= LinuxSIfdir
+	case m&os.ModeSymlink != 0:
+		r |= LinuxSIflnk
+	case m&os.ModeNamedPipe != 0:
+		r |= LinuxSIfifo
+	case m&os.ModeSocket != 0:
+		r |= LinuxSIfsock
+	case m&os.ModeCharDevice != 0:
+		r |= LinuxSIfchr
+	case m&os.ModeDevice != 0:
+		r |= LinuxSIfblk
+	default:
+		r |= LinuxSIfreg
+	}
+	if m&os.ModeSetuid != 0 {
+		r |= 0o4000
+	}
+	if m&os.ModeSetgid != 0 {
+		r |= 0o2000
+	}
+	if m&os.ModeSticky != 0 {
+		r |= 0o1000
+	}
+	return r
+}
+
+// hostOpenFlag converts the flags of openat(2) to the flags of os.OpenFile.
+func hostOpenFlag(flag uint64) int {
+	var r int
+	switch flag & LinuxOAccmode {
+	case LinuxORdonly:
+		r = os.O_RDONLY
+	case LinuxOWronly:
+		r = os.O_WRONLY
+	default:
+		r = os.O_RDWR
+	}
+	if flag&LinuxOCreat != 0 {
+		r |= os.O_CREATE
+	}
+	if flag&LinuxOExcl != 0 {
+		r |= os.O_EXCL
+	}
+	if flag&LinuxOTrunc != 0 {
+		r |= os.O_TRUNC
+	}
+	if flag&LinuxOAppend != 0 {
+		r |= os.O_APPEND
+	}
+	return r
+}
+
+// linuxDirentType converts the type bits of a Go file mode to the d_type of Linux.
+func linuxDirentType(m os.FileMode) uint64 {
+	switch {
+	case m&os.ModeDir != 0:
+		return LinuxDTDir
+	case m&os.ModeSymlink != 0:
+		return LinuxDTLnk
+	case m&os.ModeNamedPipe != 0:
+		return LinuxDTFifo
+	case m&os.ModeSocket != 0:
+		return LinuxDTSock
+	case m&os.ModeCharDevice != 0:
+		return LinuxDTChr
+	case m&os.ModeDevice != 0:
+		return LinuxDTBlk
+	case m&os.ModeType == 0:
+		return LinuxDTReg
+	}
+	return LinuxDTUnknown
+}
+
+// hostErrno converts an error returned by the host or a VFS to a Linux error number.
+func hostErrno(err error) uint64 {
+	var e syscall.Errno
+	if errors.As(err, &e) {
+		switch e {
+		case syscall.EBADF:
+			return ErrnoEBADF
+		case syscall.EBUSY:
+			return ErrnoEBUSY
+		case syscall.ENOTDIR:
+			return ErrnoENOTDIR
+		case syscall.EISDIR:
+			return ErrnoEISDIR
+		case syscall.EINVAL:
+			return ErrnoEINVAL
+		case syscall.ESPIPE:
+			return ErrnoESPIPE
+		case syscall.EROFS:
+			return ErrnoEROFS
+		case syscall.ENOTEMPTY:
+			return ErrnoENOTEMPTY
+		case syscall.ELOOP:
+			return ErrnoELOOP
+		}
+	}
+	switch {
+	case os.IsNotExist(err):
+		return ErrnoENOENT
+	case os.IsExist(err):
+		return ErrnoEEXIST
+	case os.IsPermission(err):
+		return ErrnoEACCES
 	}
+	return ErrnoEIO
 }
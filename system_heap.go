@@ -0,0 +1,211 @@
+package rv64
+
+// PageSize is the size of a guest page. Memory mappings and the stack are aligned to it.
+const PageSize uint64 = 4096
+
+// PageAlignDown rounds a down to a multiple of PageSize.
+func PageAlignDown(a uint64) uint64 {
+	return a &^ (PageSize - 1)
+}
+
+// PageAlignUp rounds a up to a multiple of PageSize.
+func PageAlignUp(a uint64) uint64 {
+	return (a + PageSize - 1) &^ (PageSize - 1)
+}
+
+// Heap manages the dynamic part of a guest address space: the program break and the anonymous memory mappings.
+//
+// The layout follows Linux. The break starts right after the highest PT_LOAD segment and grows upwards, mappings are
+// placed top-down below the stack. Neither of them may cross Limit, the lowest address reserved for the stack.
+//
+// | stack       | Limit
+// | mmap        |
+// | ...         |
+// | brk         |
+// | .data .bss  | Base
+// | .text       |
+type Heap struct {
+	Base  uint64
+	Brk   uint64
+	Limit uint64
+	Maps  []HeapMap
+}
+
+// HeapMap is a page aligned memory mapping. Maps are kept sorted by address and never overlap.
+type HeapMap struct {
+	Addr uint64
+	Size uint64
+}
+
+// SetBrk moves the program break to a, and returns the new break. If the request can not be satisfied the current
+// break is returned unchanged, this is how Linux reports ENOMEM for brk(2). The break can not grow into a mapping or
+// the stack. Only the pages added or removed change their permissions, the guest may have protected the others.
+func (h *Heap) SetBrk(m *Memory, a uint64) uint64 {
+	if a < h.Base || a > h.Limit {
+		return h.Brk
+	}
+	lo := PageAlignUp(h.Brk)
+	hi := PageAlignUp(a)
+	if a > h.Brk {
+		if h.overlaps(lo, hi-lo) {
+			return h.Brk
+		}
+		if err := m.Protect(lo, hi-lo, PermR|PermW); err != nil {
+			return h.Brk
+		}
+		if err := heapZero(m, h.Brk, a-h.Brk); err != nil {
+			m.Protect(lo, hi-lo, 0)
+			return h.Brk
+		}
+	} else if err := m.Protect(hi, lo-hi, 0); err != nil {
+		return h.Brk
+	}
+	h.Brk = a
+	return h.Brk
+}
+
+// Mmap creates an anonymous mapping of size bytes. If fixed is set the mapping is placed exactly at addr and replaces
+// any mapping already there, even below the break, otherwise addr is only a hint. It returns the address of the
+// mapping, or an errno.
+func (h *Heap) Mmap(m *Memory, addr uint64, size uint64, fixed bool) (uint64, uint64) {
+	if size == 0 {
+		return 0, ErrnoEINVAL
+	}
+	size = PageAlignUp(size)
+	if size == 0 {
+		return 0, ErrnoENOMEM
+	}
+	switch {
+	case fixed:
+		if addr != PageAlignDown(addr) {
+			return 0, ErrnoEINVAL
+		}
+		if addr+size < addr || addr+size > h.Limit {
+			return 0, ErrnoENOMEM
+		}
+		h.Munmap(addr, size)
+	case addr != 0 && h.free(PageAlignDown(addr), size):
+		addr = PageAlignDown(addr)
+	default:
+		a, ok := h.find(size)
+		if !ok {
+			return 0, ErrnoENOMEM
+		}
+		addr = a
+	}
+	// Anonymous mappings are initialized to zero. They are readable and writable until the caller says otherwise.
+	if err := m.Protect(addr, size, PermR|PermW); err != nil {
+		return 0, ErrnoENOMEM
+	}
+	if err := heapZero(m, addr, size); err != nil {
+		m.Protect(addr, size, 0)
+		return 0, ErrnoENOMEM
+	}
+	h.insert(HeapMap{Addr: addr, Size: size})
+	return addr, 0
+}
+
+// Munmap removes the mappings in the range [addr, addr+size). Parts of a mapping outside of the range are kept.
+func (h *Heap) Munmap(addr uint64, size uint64) uint64 {
+	if addr != PageAlignDown(addr) || size == 0 {
+		return ErrnoEINVAL
+	}
+	end := addr + PageAlignUp(size)
+	maps := []HeapMap{}
+	for _, e := range h.Maps {
+		if e.Addr+e.Size <= addr || e.Addr >= end {
+			maps = append(maps, e)
+			continue
+		}
+		if e.Addr < addr {
+			maps = append(maps, HeapMap{Addr: e.Addr, Size: addr - e.Addr})
+		}
+		if e.Addr+e.Size > end {
+			maps = append(maps, HeapMap{Addr: end, Size: e.Addr + e.Size - end})
+		}
+	}
+	h.Maps = maps
+	return 0
+}
+
+// free reports whether [addr, addr+size) lies between the break and the stack and does not overlap any mapping.
+func (h *Heap) free(addr uint64, size uint64) bool {
+	if addr < PageAlignUp(h.Brk) || addr+size < addr || addr+size > h.Limit {
+		return false
+	}
+	return !h.overlaps(addr, size)
+}
+
+// overlaps reports whether [addr, addr+size) overlaps a mapping.
+func (h *Heap) overlaps(addr uint64, size uint64) bool {
+	for _, e := range h.Maps {
+		if addr < e.Addr+e.Size && e.Addr < addr+size {
+			return true
+		}
+	}
+	return false
+}
+
+// find looks for the highest free range of size bytes between the break and the stack.
+func (h *Heap) find(size uint64) (uint64, bool) {
+	top := h.Limit
+	for i := len(h.Maps) - 1; i >= -1; i-- {
+		bottom := PageAlignUp(h.Brk)
+		if i >= 0 && h.Maps[i].Addr+h.Maps[i].Size > bottom {
+			bottom = h.Maps[i].Addr + h.Maps[i].Size
+		}
+		if top >= bottom && top-bottom >= size {
+			return top - size, true
+		}
+		if i >= 0 {
+			top = h.Maps[i].Addr
+		}
+	}
+	return 0, false
+}
+
+func (h *Heap) insert(e HeapMap) {
+	i := 0
+	for i < len(h.Maps) && h.Maps[i].Addr < e.Addr {
+		i++
+	}
+	h.Maps = append(h.Maps, HeapMap{})
+	copy(h.Maps[i+1:], h.Maps[i:])
+	h.Maps[i] = e
+}
+
+// heapZero clears [a, a+size). Whole pages are released rather than written if the memory supports it, which is the
+// same thing but costs neither time nor resident memory. Otherwise the range is written page by page.
+func heapZero(m *Memory, a uint64, size uint64) error {
+	lo := PageAlignUp(a)
+	hi := PageAlignDown(a + size)
+	if lo < hi && m.Release(lo, hi-lo) {
+		if err := m.SetByte(a, make([]byte, lo-a)); err != nil {
+			return err
+		}
+		return m.SetByte(hi, make([]byte, a+size-hi))
+	}
+	b := make([]byte, PageSize)
+	for size != 0 {
+		n := PageSize - a&(PageSize-1)
+		if n > size {
+			n = size
+		}
+		if err := m.SetByte(a, b[:n]); err != nil {
+			return err
+		}
+		a += n
+		size -= n
+	}
+	return nil
+}
+
+// NewHeap returns a heap whose break starts at the page aligned base and whose mappings stay below limit.
+func NewHeap(base uint64, limit uint64) *Heap {
+	base = PageAlignUp(base)
+	return &Heap{
+		Base:  base,
+		Brk:   base,
+		Limit: PageAlignDown(limit),
+	}
+}
@@ -0,0 +1,124 @@
+package rv64
+
+import (
+	"testing"
+)
+
+func TestHeapBrk(t *testing.T) {
+	m := &Memory{Fasten: NewProtected(NewPaged(0))}
+	h := NewHeap(0x10010, 0x40000)
+	if h.Base != 0x11000 || h.Brk != 0x11000 {
+		t.Fatalf("%#x %#x", h.Base, h.Brk)
+	}
+	if r := h.SetBrk(m, 0x12800); r != 0x12800 {
+		t.Fatalf("%#x", r)
+	}
+	if err := m.SetUint8(0x127ff, 1); err != nil {
+		t.Fatal(err)
+	}
+	// The memory given back is inaccessible, and zero when it is taken again.
+	h.SetBrk(m, 0x11000)
+	if err := m.SetUint8(0x127ff, 1); err == nil {
+		t.Fatal("write above the break")
+	}
+	h.SetBrk(m, 0x12800)
+	if v, err := m.GetUint8(0x127ff); err != nil || v != 0 {
+		t.Fatal(v, err)
+	}
+	// The break grows neither below its base, nor into a mapping or the stack.
+	for _, e := range []uint64{0x10000, 0x40001} {
+		if r := h.SetBrk(m, e); r != 0x12800 {
+			t.Fatalf("%#x", r)
+		}
+	}
+	if _, e := h.Mmap(m, 0x20000, 0x1000, true); e != 0 {
+		t.Fatal(e)
+	}
+	if r := h.SetBrk(m, 0x20001); r != 0x12800 {
+		t.Fatalf("%#x", r)
+	}
+	if r := h.SetBrk(m, 0x20000); r != 0x20000 {
+		t.Fatalf("%#x", r)
+	}
+}
+
+func TestHeapBrkProtect(t *testing.T) {
+	m := &Memory{Fasten: NewProtected(NewLinear(0x20000))}
+	h := NewHeap(0x10000, 0x40000)
+	h.SetBrk(m, 0x12000)
+	// A guard page set up by the guest survives the growth of the break.
+	m.Protect(0x10000, 0x1000, PermR)
+	if r := h.SetBrk(m, 0x14000); r != 0x14000 {
+		t.Fatalf("%#x", r)
+	}
+	if err := m.SetUint8(0x10000, 1); err == nil {
+		t.Fatal("write to a guard page")
+	}
+	if err := m.SetUint8(0x13fff, 1); err != nil {
+		t.Fatal(err)
+	}
+	// The break does not grow past the end of memory.
+	if r := h.SetBrk(m, 0x21000); r != 0x14000 {
+		t.Fatalf("%#x", r)
+	}
+	if err := m.SetUint8(0x14000, 1); err == nil {
+		t.Fatal("write above the break")
+	}
+}
+
+func TestHeapMmap(t *testing.T) {
+	m := &Memory{Fasten: NewProtected(NewPaged(0))}
+	h := NewHeap(0x10000, 0x40000)
+	// Mappings are placed top-down below the stack.
+	a, e := h.Mmap(m, 0, 0x1800, false)
+	if e != 0 || a != 0x3e000 {
+		t.Fatalf("%#x %d", a, e)
+	}
+	b, e := h.Mmap(m, 0, 0x1000, false)
+	if e != 0 || b != 0x3d000 {
+		t.Fatalf("%#x %d", b, e)
+	}
+	// A free hint is taken, a used one is not.
+	if r, e := h.Mmap(m, 0x30000, 0x1000, false); e != 0 || r != 0x30000 {
+		t.Fatalf("%#x %d", r, e)
+	}
+	if r, e := h.Mmap(m, 0x3d000, 0x1000, false); e != 0 || r != 0x3c000 {
+		t.Fatalf("%#x %d", r, e)
+	}
+	// A fixed mapping may replace part of another one, or memory below the break like the segments of the program.
+	if r, e := h.Mmap(m, 0x3f000, 0x1000, true); e != 0 || r != 0x3f000 {
+		t.Fatalf("%#x %d", r, e)
+	}
+	if r, e := h.Mmap(m, 0x8000, 0x1000, true); e != 0 || r != 0x8000 {
+		t.Fatalf("%#x %d", r, e)
+	}
+	want := []HeapMap{{0x8000, 0x1000}, {0x30000, 0x1000}, {0x3c000, 0x1000}, {0x3d000, 0x1000}, {0x3e000, 0x1000}, {0x3f000, 0x1000}}
+	if len(h.Maps) != len(want) {
+		t.Fatal(h.Maps)
+	}
+	for i := range want {
+		if h.Maps[i] != want[i] {
+			t.Fatal(h.Maps)
+		}
+	}
+	// Unmapping splits a mapping, and the hole can be used again.
+	if e := h.Munmap(0x3c000, 0x2000); e != 0 || len(h.Maps) != 4 {
+		t.Fatal(e, h.Maps)
+	}
+	if e := h.Munmap(0x3c001, 0x1000); e != ErrnoEINVAL {
+		t.Fatal(e)
+	}
+	if r, e := h.Mmap(m, 0, 0x2000, false); e != 0 || r != 0x3c000 {
+		t.Fatalf("%#x %d", r, e)
+	}
+	// There is no room left between the break and the stack.
+	if _, e := h.Mmap(m, 0, 0x30000, false); e != ErrnoENOMEM {
+		t.Fatal(e)
+	}
+	if _, e := h.Mmap(m, 0x3f000, 0x2000, true); e != ErrnoENOMEM {
+		t.Fatal(e)
+	}
+	if _, e := h.Mmap(m, 0, 0, false); e != ErrnoEINVAL {
+		t.Fatal(e)
+	}
+}
@@ -0,0 +1,149 @@
+package rv64
+
+// RISC-V Linux syscall numbers, taken from the asm-generic unistd.h which is shared by rv64.
+//
+// The syscall number is passed in a7, arguments in a0-a5 and the return value comes back in a0. On failure the
+// kernel returns -errno in a0.
+const (
+	SyscallMkdirat       = 34
+	SyscallUnlinkat      = 35
+	SyscallFaccessat     = 48
+	SyscallOpenat        = 56
+	SyscallClose         = 57
+	SyscallGetdents64    = 61
+	SyscallLseek         = 62
+	SyscallRead          = 63
+	SyscallWrite         = 64
+	SyscallWritev        = 66
+	SyscallPread64       = 67
+	SyscallReadlinkat    = 78
+	SyscallNewfstatat    = 79
+	SyscallFstat         = 80
+	SyscallExit          = 93
+	SyscallExitGroup     = 94
+	SyscallSetTidAddress = 96
+	SyscallSetRobustList = 99
+	SyscallClockGet      = 113
+	SyscallClockRes      = 114
+	SyscallRtSigaction   = 134
+	SyscallRtSigprocmask = 135
+	SyscallUname         = 160
+	SyscallTimeOfDay     = 169
+	SyscallGetpid        = 172
+	SyscallGetppid       = 173
+	SyscallGetuid        = 174
+	SyscallGeteuid       = 175
+	SyscallGetgid        = 176
+	SyscallGetegid       = 177
+	SyscallGettid        = 178
+	SyscallBrk           = 214
+	SyscallMunmap        = 215
+	SyscallMmap          = 222
+	SyscallMprotect      = 226
+	SyscallPrlimit64     = 261
+	SyscallGetrandom     = 278
+	// SyscallTime is not part of the Linux ABI. It was used by the riscv-pk proxy kernel and older newlib ports.
+	SyscallTime = 1062
+)
+
+// Linux error numbers. A syscall that fails returns the negated value in a0.
+const (
+	ErrnoEPERM     uint64 = 1
+	ErrnoENOENT    uint64 = 2
+	ErrnoESRCH     uint64 = 3
+	ErrnoEIO       uint64 = 5
+	ErrnoEBADF     uint64 = 9
+	ErrnoENOMEM    uint64 = 12
+	ErrnoEACCES    uint64 = 13
+	ErrnoEFAULT    uint64 = 14
+	ErrnoEBUSY     uint64 = 16
+	ErrnoEEXIST    uint64 = 17
+	ErrnoENODEV    uint64 = 19
+	ErrnoENOTDIR   uint64 = 20
+	ErrnoEISDIR    uint64 = 21
+	ErrnoEINVAL    uint64 = 22
+	ErrnoEMFILE    uint64 = 24
+	ErrnoESPIPE    uint64 = 29
+	ErrnoEROFS     uint64 = 30
+	ErrnoERANGE    uint64 = 34
+	ErrnoENOSYS    uint64 = 38
+	ErrnoENOTEMPTY uint64 = 39
+	ErrnoELOOP     uint64 = 40
+)
+
+// Flags of openat(2). The values are the asm-generic ones used by rv64.
+const (
+	LinuxORdonly    uint64 = 0o0
+	LinuxOWronly    uint64 = 0o1
+	LinuxORdwr      uint64 = 0o2
+	LinuxOAccmode   uint64 = 0o3
+	LinuxOCreat     uint64 = 0o100
+	LinuxOExcl      uint64 = 0o200
+	LinuxOTrunc     uint64 = 0o1000
+	LinuxOAppend    uint64 = 0o2000
+	LinuxODirectory uint64 = 0o200000
+)
+
+// Special values for the dirfd and flags arguments of the *at syscalls.
+const (
+	LinuxATFdcwd           uint64 = 0xffffffffffffff9c // -100
+	LinuxATSymlinkNofollow uint64 = 0x100
+	LinuxATRemovedir       uint64 = 0x200
+	LinuxATEmptyPath       uint64 = 0x1000
+)
+
+// Flags of mmap(2) and mprotect(2).
+const (
+	LinuxProtRead     uint64 = 0x01
+	LinuxProtWrite    uint64 = 0x02
+	LinuxProtExec     uint64 = 0x04
+	LinuxMapShared    uint64 = 0x01
+	LinuxMapPrivate   uint64 = 0x02
+	LinuxMapFixed     uint64 = 0x10
+	LinuxMapAnonymous uint64 = 0x20
+)
+
+// Values of d_type in struct linux_dirent64.
+const (
+	LinuxDTUnknown uint64 = 0
+	LinuxDTFifo    uint64 = 1
+	LinuxDTChr     uint64 = 2
+	LinuxDTDir     uint64 = 4
+	LinuxDTBlk     uint64 = 6
+	LinuxDTReg     uint64 = 8
+	LinuxDTLnk     uint64 = 10
+	LinuxDTSock    uint64 = 12
+)
+
+// File type bits of st_mode.
+const (
+	LinuxSIfmt   uint64 = 0o170000
+	LinuxSIfsock uint64 = 0o140000
+	LinuxSIflnk  uint64 = 0o120000
+	LinuxSIfreg  uint64 = 0o100000
+	LinuxSIfblk  uint64 = 0o060000
+	LinuxSIfdir  uint64 = 0o040000
+	LinuxSIfchr  uint64 = 0o020000
+	LinuxSIfifo  uint64 = 0o010000
+)
+
+// Clock ids of clock_gettime(2).
+const (
+	LinuxClockRealtime        uint64 = 0
+	LinuxClockMonotonic       uint64 = 1
+	LinuxClockProcessCputime  uint64 = 2
+	LinuxClockThreadCputime   uint64 = 3
+	LinuxClockMonotonicRaw    uint64 = 4
+	LinuxClockRealtimeCoarse  uint64 = 5
+	LinuxClockMonotonicCoarse uint64 = 6
+	LinuxClockBoottime        uint64 = 7
+)
+
+// Signals that kill the guest, see CPU.Halt.
+const (
+	LinuxSigill  uint64 = 4
+	LinuxSigtrap uint64 = 5
+	LinuxSigbus  uint64 = 7
+	LinuxSigsegv uint64 = 11
+	LinuxSigsys  uint64 = 31
+)
@@ -0,0 +1,94 @@
+package rv64
+
+// SyscallHandler handles one syscall. It returns the value written back to a0, or an error that aborts the guest.
+// Failures the guest should see are reported as SyscallError(errno) with a nil error.
+type SyscallHandler func(c *CPU) (uint64, error)
+
+// SystemRegistry dispatches syscalls to handlers registered by number. Syscalls without a handler fall through to
+// Default, which is usually a SystemStandard, so an embedder can expose Go functions to the guest and keep the Linux
+// syscalls. A handler ends the guest with Exit. The zero value, or a literal with Default set, is ready to use:
+//
+//	r := rv64.NewSystemRegistry(rv64.NewSystemStandard())
+//	r.Register(0x1000, func(c *rv64.CPU) (uint64, error) {
+//		s, err := c.GetMemory().GetString(rv64.SyscallArg(c, 0))
+//		if err != nil {
+//			return rv64.SyscallError(rv64.ErrnoEFAULT), nil
+//		}
+//		log.Println(s)
+//		return 0, nil
+//	})
+//	cpu.SetSystem(r)
+type SystemRegistry struct {
+	Default  System
+	handlers map[uint64]SyscallHandler
+	exited   bool
+	code     uint8
+}
+
+// Register sets the handler of syscall n, replacing any previous one. A nil handler removes it.
+func (r *SystemRegistry) Register(n uint64, h SyscallHandler) {
+	if h == nil {
+		delete(r.handlers, n)
+		return
+	}
+	if r.handlers == nil {
+		r.handlers = map[uint64]SyscallHandler{}
+	}
+	r.handlers[n] = h
+}
+
+// Handler returns the handler of syscall n, or nil.
+func (r *SystemRegistry) Handler(n uint64) SyscallHandler {
+	return r.handlers[n]
+}
+
+func (r *SystemRegistry) HandleCall(c *CPU) (uint64, error) {
+	h, ok := r.handlers[c.GetRegister(Ra7)]
+	if !ok {
+		if r.Default == nil {
+			return 0, ErrAbnormalEcall
+		}
+		return r.Default.HandleCall(c)
+	}
+	a, err := h(c)
+	if err != nil {
+		return 0, err
+	}
+	c.SetRegister(Ra0, a)
+	c.SetPC(c.GetPC() + 4)
+	return 1, nil
+}
+
+// Exit stops the guest with the exit status code, which Code reports from then on. It is how a handler replaces exit
+// or exit_group.
+func (r *SystemRegistry) Exit(c *CPU, code uint8) {
+	r.exited = true
+	r.code = code
+	c.SetStatus(1)
+}
+
+func (r *SystemRegistry) Code() uint8 {
+	if r.exited || r.Default == nil {
+		return r.code
+	}
+	return r.Default.Code()
+}
+
+// NewSystemRegistry returns a registry without handlers which falls through to def. def may be nil, then unknown
+// syscalls abort the guest with ErrAbnormalEcall.
+func NewSystemRegistry(def System) *SystemRegistry {
+	return &SystemRegistry{
+		Default:  def,
+		handlers: map[uint64]SyscallHandler{},
+	}
+}
+
+// SyscallArg returns the i-th argument of the syscall being handled, i is between 0 and 5.
+func SyscallArg(c *CPU, i int) uint64 {
+	return c.GetRegister(Ra0 + uint64(i))
+}
+
+// SyscallError returns the value of a0 that reports the Linux error number e to the guest.
+func SyscallError(e uint64) uint64 {
+	return -e
+}
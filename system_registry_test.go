@@ -0,0 +1,59 @@
+package rv64
+
+import (
+	"testing"
+)
+
+func TestSystemRegistry(t *testing.T) {
+	c := testCPU(nil)
+	r := &SystemRegistry{Default: NewSystemStandard()}
+	r.Register(0x1000, func(c *CPU) (uint64, error) {
+		return SyscallArg(c, 1) + 1, nil
+	})
+	c.SetPC(0x1000)
+	if a := testSyscall(t, c, r, 0x1000, 0, 41); a != 42 || c.GetPC() != 0x1004 {
+		t.Fatal(a, c.GetPC())
+	}
+	// Syscalls without a handler fall through to the default system.
+	if a := testSyscall(t, c, r, SyscallGetpid); a != systemPid {
+		t.Fatal(a)
+	}
+	r.Register(SyscallGetpid, func(c *CPU) (uint64, error) {
+		return 7, nil
+	})
+	if a := testSyscall(t, c, r, SyscallGetpid); a != 7 || r.Handler(SyscallGetpid) == nil {
+		t.Fatal(a)
+	}
+	// A nil handler removes the previous one.
+	r.Register(SyscallGetpid, nil)
+	if a := testSyscall(t, c, r, SyscallGetpid); a != systemPid || r.Handler(SyscallGetpid) != nil {
+		t.Fatal(a)
+	}
+	r.Register(0x1000, nil)
+	c.SetRegister(Ra7, 0x1000)
+	if _, err := r.HandleCall(c); err != ErrAbnormalEcall {
+		t.Fatal(err)
+	}
+	r = &SystemRegistry{}
+	if _, err := r.HandleCall(c); err != ErrAbnormalEcall || r.Code() != 0 {
+		t.Fatal(err)
+	}
+}
+
+func TestSystemRegistryExit(t *testing.T) {
+	c := testCPU(map[uint64]uint32{
+		0x1000: 0x02a00513, // li a0, 42
+		0x1004: 0x05d00893, // li a7, 93
+		0x1008: 0x00000073, // ecall
+	})
+	r := NewSystemRegistry(NewSystemStandard())
+	r.Register(SyscallExit, func(c *CPU) (uint64, error) {
+		r.Exit(c, uint8(SyscallArg(c, 0)+1))
+		return 0, nil
+	})
+	c.SetSystem(r)
+	c.SetPC(0x1000)
+	if a := c.Run(); a != 43 || r.Code() != 43 || c.GetPC() != 0x100c {
+		t.Fatal(a, r.Code(), c.GetPC())
+	}
+}
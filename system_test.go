@@ -0,0 +1,187 @@
+package rv64
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testSyscall calls the syscall n of s with the arguments args and returns a0.
+func testSyscall(t *testing.T, c *CPU, s System, n uint64, args ...uint64) uint64 {
+	c.SetRegister(Ra7, n)
+	for i, e := range args {
+		c.SetRegister(Ra0+uint64(i), e)
+	}
+	if _, err := s.HandleCall(c); err != nil {
+		t.Fatal(err)
+	}
+	return c.GetRegister(Ra0)
+}
+
+// testString writes the string p to guest memory at a and returns a.
+func testString(c *CPU, a uint64, p string) uint64 {
+	c.GetMemory().SetByte(a, append([]byte(p), 0))
+	return a
+}
+
+func TestSystemStandardFile(t *testing.T) {
+	c := testCPU(nil)
+	s := NewSystemStandard()
+	m := NewVFSMemory()
+	m.WriteFile("/etc/motd", []byte("hello"), 0o644)
+	m.Symlink("/etc/motd", "/motd")
+	s.FS = m
+	s.Cwd = "/etc"
+
+	fd := testSyscall(t, c, s, SyscallOpenat, LinuxATFdcwd, testString(c, 0x100, "motd"), LinuxORdonly)
+	if fd != 3 {
+		t.Fatal(int64(fd))
+	}
+	if r := testSyscall(t, c, s, SyscallRead, fd, 0x1000, 3); r != 3 {
+		t.Fatal(int64(r))
+	}
+	// A count larger than the memory is not allocated on the host, the read stops at the end of the file.
+	if r := testSyscall(t, c, s, SyscallRead, fd, 0x1003, ^uint64(0)); r != 2 {
+		t.Fatal(int64(r))
+	}
+	if b, _ := c.GetMemory().GetByte(0x1000, 5); string(b) != "hello" {
+		t.Fatal(string(b))
+	}
+	if r := testSyscall(t, c, s, SyscallPread64, fd, 0x1000, 2, 1); r != 2 {
+		t.Fatal(int64(r))
+	}
+	if b, _ := c.GetMemory().GetByte(0x1000, 2); string(b) != "el" {
+		t.Fatal(string(b))
+	}
+	if r := testSyscall(t, c, s, SyscallLseek, fd, 0, 2); r != 5 {
+		t.Fatal(int64(r))
+	}
+	if r := testSyscall(t, c, s, SyscallFstat, fd, 0x2000); r != 0 {
+		t.Fatal(int64(r))
+	}
+	if v, _ := c.GetMemory().GetUint64(0x2030); v != 5 {
+		t.Fatal(v)
+	}
+	if v, _ := c.GetMemory().GetUint32(0x2010); uint64(v)&LinuxSIfmt != LinuxSIfreg {
+		t.Fatalf("%o", v)
+	}
+	if r := testSyscall(t, c, s, SyscallReadlinkat, LinuxATFdcwd, testString(c, 0x100, "/motd"), 0x1000, 4); r != 4 {
+		t.Fatal(int64(r))
+	}
+	if b, _ := c.GetMemory().GetByte(0x1000, 4); string(b) != "/etc" {
+		t.Fatal(string(b))
+	}
+	if r := testSyscall(t, c, s, SyscallClose, fd); r != 0 {
+		t.Fatal(int64(r))
+	}
+	if r := testSyscall(t, c, s, SyscallRead, fd, 0x1000, 1); r != SyscallError(ErrnoEBADF) {
+		t.Fatal(int64(r))
+	}
+	if r := testSyscall(t, c, s, SyscallOpenat, LinuxATFdcwd, testString(c, 0x100, "none"), LinuxORdonly); r != SyscallError(ErrnoENOENT) {
+		t.Fatal(int64(r))
+	}
+}
+
+func TestSystemStandardWrite(t *testing.T) {
+	c := testCPU(nil)
+	s := NewSystemStandard()
+	m := NewVFSMemory()
+	s.FS = m
+	fd := testSyscall(t, c, s, SyscallOpenat, LinuxATFdcwd, testString(c, 0x100, "/out"), LinuxOWronly|LinuxOCreat, 0o644)
+	if fd != 3 {
+		t.Fatal(int64(fd))
+	}
+	c.GetMemory().SetByte(0x1000, []byte("abcdef"))
+	if r := testSyscall(t, c, s, SyscallWrite, fd, 0x1000, 2); r != 2 {
+		t.Fatal(int64(r))
+	}
+	// struct iovec { void *iov_base; size_t iov_len; }
+	iov := make([]byte, 32)
+	binary.LittleEndian.PutUint64(iov[0:], 0x1002)
+	binary.LittleEndian.PutUint64(iov[8:], 1)
+	binary.LittleEndian.PutUint64(iov[16:], 0x1003)
+	binary.LittleEndian.PutUint64(iov[24:], 3)
+	c.GetMemory().SetByte(0x2000, iov)
+	if r := testSyscall(t, c, s, SyscallWritev, fd, 0x2000, 2); r != 4 {
+		t.Fatal(int64(r))
+	}
+	if b, _ := m.ReadFile("/out"); string(b) != "abcdef" {
+		t.Fatal(string(b))
+	}
+	// A count larger than the memory of the guest is a fault, not an allocation on the host.
+	if r := testSyscall(t, c, s, SyscallWrite, fd, 0x3000, ^uint64(0)); r != SyscallError(ErrnoEFAULT) {
+		t.Fatal(int64(r))
+	}
+	s.Random = bytes.NewReader(make([]byte, 1<<20))
+	if r := testSyscall(t, c, s, SyscallGetrandom, 0x3000, ^uint64(0), 0); r != SyscallError(ErrnoEFAULT) {
+		t.Fatal(int64(r))
+	}
+	if r := testSyscall(t, c, s, SyscallGetrandom, 0x3000, 16, 0); r != 16 {
+		t.Fatal(int64(r))
+	}
+	if r := testSyscall(t, c, s, SyscallWrite, 42, 0x1000, 1); r != SyscallError(ErrnoEBADF) {
+		t.Fatal(int64(r))
+	}
+}
+
+func TestSystemStandardMprotect(t *testing.T) {
+	c := NewCPU()
+	c.SetCSR(NewCSRStandard())
+	p := NewProtected(NewPaged(0))
+	p.Protect(0x1000, 0x1000, PermR|PermW)
+	c.SetFasten(p)
+	s := NewSystemStandard()
+	s.Heap = NewHeap(0x10000, 0x100000)
+	if r := testSyscall(t, c, s, SyscallMprotect, 0x1000, 0x1000, LinuxProtRead); r != 0 {
+		t.Fatal(int64(r))
+	}
+	// Unmapped memory does not become accessible.
+	for _, e := range [][2]uint64{{0x3000, 0x1000}, {0x1000, 0x2000}, {0x1000, ^uint64(0)}} {
+		if r := testSyscall(t, c, s, SyscallMprotect, e[0], e[1], LinuxProtRead); r != SyscallError(ErrnoENOMEM) {
+			t.Fatal(e, int64(r))
+		}
+	}
+	if r := p.Regions(); len(r) != 1 || r[0] != (Region{Addr: 0x1000, Size: 0x1000, Perm: PermR}) {
+		t.Fatal(r)
+	}
+	// A mapping stays mapped while it is inaccessible.
+	a := testSyscall(t, c, s, SyscallMmap, 0, 0x2000, LinuxProtRead|LinuxProtWrite, LinuxMapPrivate|LinuxMapAnonymous, ^uint64(0), 0)
+	if r := testSyscall(t, c, s, SyscallMprotect, a, 0x2000, 0); r != 0 {
+		t.Fatal(int64(r))
+	}
+	if r := testSyscall(t, c, s, SyscallMprotect, a+0x1000, 0x1000, LinuxProtRead); r != 0 {
+		t.Fatal(int64(r))
+	}
+	if _, err := c.GetMemory().GetUint8(a + 0x1000); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSystemStandardHost(t *testing.T) {
+	c := testCPU(nil)
+	s := NewSystemStandard()
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "a"), []byte("a"), 0o644)
+	// The guest reads the host file system by default, its changes are kept in memory.
+	fd := testSyscall(t, c, s, SyscallOpenat, LinuxATFdcwd, testString(c, 0x100, root+"/b"), LinuxOWronly|LinuxOCreat, 0o644)
+	if int64(fd) < 0 {
+		t.Fatal(int64(fd))
+	}
+	if r := testSyscall(t, c, s, SyscallWrite, fd, testString(c, 0x1000, "b"), 1); r != 1 {
+		t.Fatal(int64(r))
+	}
+	if r := testSyscall(t, c, s, SyscallUnlinkat, LinuxATFdcwd, testString(c, 0x100, root+"/a"), 0); r != 0 {
+		t.Fatal(int64(r))
+	}
+	if r := testSyscall(t, c, s, SyscallOpenat, LinuxATFdcwd, testString(c, 0x100, root+"/a"), LinuxORdonly); r != SyscallError(ErrnoENOENT) {
+		t.Fatal(int64(r))
+	}
+	if _, err := os.Stat(filepath.Join(root, "a")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "b")); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,373 @@
+package rv64
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Kinds of syscall arguments, they decide how SystemTrace prints an argument.
+const (
+	traceHex      = iota // An address or an opaque value
+	traceInt             // A signed integer
+	traceFd              // A file descriptor, or AT_FDCWD
+	traceStr             // A NUL-terminated string
+	traceBuf             // A buffer whose length is the next argument
+	traceBufOut          // A buffer filled by the syscall, its length is the return value
+	traceOct             // A file mode
+	traceOpenFlag        // Flags of openat
+	traceAtFlag          // Flags of the *at syscalls
+	traceMmapProt        // Protection of mmap
+	traceMmapFlag        // Flags of mmap
+	traceClock           // A clock id
+	traceWhence          // Whence of lseek
+)
+
+// traceRetHex marks syscalls that return an address rather than a number.
+const traceRetHex = -1
+
+// traceCall describes a syscall. Syscalls which are not implemented by SystemStandard are listed too, so that a
+// trace tells which syscall made the guest abort.
+type traceCall struct {
+	name string
+	args []int
+	ret  int
+}
+
+var traceCalls = map[uint64]traceCall{
+	17:                   {"getcwd", []int{traceHex, traceInt}, 0},
+	23:                   {"dup", []int{traceFd}, 0},
+	24:                   {"dup3", []int{traceFd, traceFd, traceHex}, 0},
+	25:                   {"fcntl", []int{traceFd, traceInt, traceHex}, 0},
+	29:                   {"ioctl", []int{traceFd, traceHex, traceHex}, 0},
+	SyscallMkdirat:       {"mkdirat", []int{traceFd, traceStr, traceOct}, 0},
+	SyscallUnlinkat:      {"unlinkat", []int{traceFd, traceStr, traceAtFlag}, 0},
+	38:                   {"renameat", []int{traceFd, traceStr, traceFd, traceStr}, 0},
+	SyscallFaccessat:     {"faccessat", []int{traceFd, traceStr, traceOct, traceAtFlag}, 0},
+	49:                   {"chdir", []int{traceStr}, 0},
+	SyscallOpenat:        {"openat", []int{traceFd, traceStr, traceOpenFlag, traceOct}, 0},
+	SyscallClose:         {"close", []int{traceFd}, 0},
+	59:                   {"pipe2", []int{traceHex, traceHex}, 0},
+	SyscallGetdents64:    {"getdents64", []int{traceFd, traceHex, traceInt}, 0},
+	SyscallLseek:         {"lseek", []int{traceFd, traceInt, traceWhence}, 0},
+	SyscallRead:          {"read", []int{traceFd, traceBufOut, traceInt}, 0},
+	SyscallWrite:         {"write", []int{traceFd, traceBuf, traceInt}, 0},
+	65:                   {"readv", []int{traceFd, traceHex, traceInt}, 0},
+	SyscallWritev:        {"writev", []int{traceFd, traceHex, traceInt}, 0},
+	SyscallPread64:       {"pread64", []int{traceFd, traceBufOut, traceInt, traceInt}, 0},
+	68:                   {"pwrite64", []int{traceFd, traceBuf, traceInt, traceInt}, 0},
+	SyscallReadlinkat:    {"readlinkat", []int{traceFd, traceStr, traceHex, traceInt}, 0},
+	SyscallNewfstatat:    {"newfstatat", []int{traceFd, traceStr, traceHex, traceAtFlag}, 0},
+	SyscallFstat:         {"fstat", []int{traceFd, traceHex}, 0},
+	SyscallExit:          {"exit", []int{traceInt}, 0},
+	SyscallExitGroup:     {"exit_group", []int{traceInt}, 0},
+	SyscallSetTidAddress: {"set_tid_address", []int{traceHex}, 0},
+	98:                   {"futex", []int{traceHex, traceInt, traceInt, traceHex, traceHex, traceInt}, 0},
+	SyscallSetRobustList: {"set_robust_list", []int{traceHex, traceInt}, 0},
+	SyscallClockGet:      {"clock_gettime", []int{traceClock, traceHex}, 0},
+	SyscallClockRes:      {"clock_getres", []int{traceClock, traceHex}, 0},
+	124:                  {"sched_yield", []int{}, 0},
+	129:                  {"kill", []int{traceInt, traceInt}, 0},
+	131:                  {"tgkill", []int{traceInt, traceInt, traceInt}, 0},
+	SyscallRtSigaction:   {"rt_sigaction", []int{traceInt, traceHex, traceHex, traceInt}, 0},
+	SyscallRtSigprocmask: {"rt_sigprocmask", []int{traceInt, traceHex, traceHex, traceInt}, 0},
+	SyscallUname:         {"uname", []int{traceHex}, 0},
+	163:                  {"getrlimit", []int{traceInt, traceHex}, 0},
+	SyscallTimeOfDay:     {"gettimeofday", []int{traceHex, traceHex}, 0},
+	SyscallGetpid:        {"getpid", []int{}, 0},
+	SyscallGetppid:       {"getppid", []int{}, 0},
+	SyscallGetuid:        {"getuid", []int{}, 0},
+	SyscallGeteuid:       {"geteuid", []int{}, 0},
+	SyscallGetgid:        {"getgid", []int{}, 0},
+	SyscallGetegid:       {"getegid", []int{}, 0},
+	SyscallGettid:        {"gettid", []int{}, 0},
+	SyscallBrk:           {"brk", []int{traceHex}, traceRetHex},
+	SyscallMunmap:        {"munmap", []int{traceHex, traceInt}, 0},
+	SyscallMmap:          {"mmap", []int{traceHex, traceInt, traceMmapProt, traceMmapFlag, traceFd, traceInt}, traceRetHex},
+	SyscallMprotect:      {"mprotect", []int{traceHex, traceInt, traceMmapProt}, 0},
+	SyscallPrlimit64:     {"prlimit64", []int{traceInt, traceInt, traceHex, traceHex}, 0},
+	SyscallGetrandom:     {"getrandom", []int{traceHex, traceInt, traceHex}, 0},
+	SyscallTime:          {"time", []int{traceHex}, 0},
+}
+
+var traceErrnos = map[uint64]string{
+	ErrnoEPERM:     "EPERM",
+	ErrnoENOENT:    "ENOENT",
+	ErrnoESRCH:     "ESRCH",
+	ErrnoEIO:       "EIO",
+	ErrnoEBADF:     "EBADF",
+	ErrnoENOMEM:    "ENOMEM",
+	ErrnoEACCES:    "EACCES",
+	ErrnoEFAULT:    "EFAULT",
+	ErrnoEBUSY:     "EBUSY",
+	ErrnoEEXIST:    "EEXIST",
+	ErrnoENODEV:    "ENODEV",
+	ErrnoENOTDIR:   "ENOTDIR",
+	ErrnoEISDIR:    "EISDIR",
+	ErrnoEINVAL:    "EINVAL",
+	ErrnoEMFILE:    "EMFILE",
+	ErrnoESPIPE:    "ESPIPE",
+	ErrnoEROFS:     "EROFS",
+	ErrnoERANGE:    "ERANGE",
+	ErrnoENOSYS:    "ENOSYS",
+	ErrnoENOTEMPTY: "ENOTEMPTY",
+	ErrnoELOOP:     "ELOOP",
+}
+
+// traceFlag is a named bit of a flags argument.
+type traceFlag struct {
+	bits uint64
+	name string
+}
+
+var (
+	traceOpenFlags = []traceFlag{
+		{LinuxOCreat, "O_CREAT"},
+		{LinuxOExcl, "O_EXCL"},
+		{LinuxOTrunc, "O_TRUNC"},
+		{LinuxOAppend, "O_APPEND"},
+		{LinuxODirectory, "O_DIRECTORY"},
+		{0o4000, "O_NONBLOCK"},
+		{0o400000, "O_NOFOLLOW"},
+		{0o2000000, "O_CLOEXEC"},
+	}
+	traceAtFlags = []traceFlag{
+		{LinuxATSymlinkNofollow, "AT_SYMLINK_NOFOLLOW"},
+		{LinuxATRemovedir, "AT_REMOVEDIR"},
+		{LinuxATEmptyPath, "AT_EMPTY_PATH"},
+	}
+	traceMmapProts = []traceFlag{
+		{LinuxProtRead, "PROT_READ"},
+		{LinuxProtWrite, "PROT_WRITE"},
+		{LinuxProtExec, "PROT_EXEC"},
+	}
+	traceMmapFlags = []traceFlag{
+		{LinuxMapShared, "MAP_SHARED"},
+		{LinuxMapPrivate, "MAP_PRIVATE"},
+		{LinuxMapFixed, "MAP_FIXED"},
+		{LinuxMapAnonymous, "MAP_ANONYMOUS"},
+		{0x4000, "MAP_NORESERVE"},
+		{0x100000, "MAP_FIXED_NOREPLACE"},
+	}
+	traceClocks = []string{
+		"CLOCK_REALTIME",
+		"CLOCK_MONOTONIC",
+		"CLOCK_PROCESS_CPUTIME_ID",
+		"CLOCK_THREAD_CPUTIME_ID",
+		"CLOCK_MONOTONIC_RAW",
+		"CLOCK_REALTIME_COARSE",
+		"CLOCK_MONOTONIC_COARSE",
+		"CLOCK_BOOTTIME",
+	}
+	traceWhences = []string{"SEEK_SET", "SEEK_CUR", "SEEK_END"}
+)
+
+// Strings and buffers longer than this are truncated in a trace.
+const traceStrMax = 32
+
+// SystemTrace wraps a System and writes every syscall made by the guest to Writer, in the manner of strace(1):
+//
+//	openat(AT_FDCWD, "/etc/passwd", O_RDONLY, 0) = -1 ENOENT
+//
+// If JSON is set each syscall is written as a JSON object on its own line instead, see TraceRecord.
+type SystemTrace struct {
+	System System
+	Writer io.Writer
+	JSON   bool
+}
+
+// TraceRecord is a syscall as written by SystemTrace in JSON mode. Args holds the raw values of a0-a5 and Text their
+// decoded form. Ret is the raw value of a0 after the call, Errno the name of the error if the call failed. Error is
+// set if the System refused the call, in which case Ret is meaningless.
+type TraceRecord struct {
+	PC    uint64    `json:"pc"`
+	Nr    uint64    `json:"nr"`
+	Name  string    `json:"name"`
+	Args  [6]uint64 `json:"args"`
+	Text  []string  `json:"text"`
+	Ret   uint64    `json:"ret"`
+	Errno string    `json:"errno,omitempty"`
+	Error string    `json:"error,omitempty"`
+}
+
+func (t *SystemTrace) HandleCall(c *CPU) (uint64, error) {
+	r := TraceRecord{PC: c.GetPC(), Nr: c.GetRegister(Ra7)}
+	for i := range r.Args {
+		r.Args[i] = c.GetRegister(Ra0 + uint64(i))
+	}
+	call, ok := traceCalls[r.Nr]
+	if !ok {
+		call = traceCall{name: fmt.Sprintf("syscall_%d", r.Nr), args: []int{traceHex, traceHex, traceHex, traceHex, traceHex, traceHex}}
+	}
+	r.Name = call.name
+	// Arguments are decoded before the call, the syscall may change the memory they point to.
+	r.Text = make([]string, len(call.args))
+	for i, k := range call.args {
+		r.Text[i] = traceArg(c, k, r.Args, i)
+	}
+	n, err := t.System.HandleCall(c)
+	if err != nil {
+		r.Error = err.Error()
+	} else {
+		r.Ret = c.GetRegister(Ra0)
+		if int64(r.Ret) < 0 && int64(r.Ret) >= -4095 {
+			r.Errno = traceErrno(-r.Ret)
+		}
+		for i, k := range call.args {
+			if k == traceBufOut && r.Errno == "" {
+				r.Text[i] = traceBuffer(c, r.Args[i], r.Ret)
+			}
+		}
+	}
+	if t.JSON {
+		b, _ := json.Marshal(r)
+		fmt.Fprintf(t.Writer, "%s\n", b)
+		return n, err
+	}
+	var ret string
+	switch {
+	case r.Error != "":
+		ret = "? (" + r.Error + ")"
+	case r.Nr == SyscallExit || r.Nr == SyscallExitGroup:
+		ret = "?"
+	case r.Errno != "":
+		ret = "-1 " + r.Errno
+	case call.ret == traceRetHex:
+		ret = fmt.Sprintf("%#x", r.Ret)
+	default:
+		ret = strconv.FormatInt(int64(r.Ret), 10)
+	}
+	fmt.Fprintf(t.Writer, "%s(%s) = %s\n", r.Name, strings.Join(r.Text, ", "), ret)
+	return n, err
+}
+
+func (t *SystemTrace) Code() uint8 {
+	return t.System.Code()
+}
+
+// NewSystemTrace returns a tracer of s which writes plain text to w.
+func NewSystemTrace(s System, w io.Writer) *SystemTrace {
+	return &SystemTrace{System: s, Writer: w}
+}
+
+// traceArg decodes the i-th argument of a syscall.
+func traceArg(c *CPU, kind int, args [6]uint64, i int) string {
+	a := args[i]
+	switch kind {
+	case traceInt:
+		return strconv.FormatInt(int64(a), 10)
+	case traceFd:
+		if a == LinuxATFdcwd {
+			return "AT_FDCWD"
+		}
+		return strconv.FormatInt(int64(a), 10)
+	case traceStr:
+		s, err := c.GetMemory().GetString(a)
+		if err != nil {
+			return fmt.Sprintf("%#x", a)
+		}
+		return traceQuote([]byte(s))
+	case traceBuf:
+		return traceBuffer(c, a, args[i+1])
+	case traceOct:
+		return fmt.Sprintf("%#o", a)
+	case traceOpenFlag:
+		r := []string{"O_RDONLY", "O_WRONLY", "O_RDWR", "O_ACCMODE"}[a&LinuxOAccmode]
+		if s := traceFlags(a&^LinuxOAccmode, traceOpenFlags); s != "0" {
+			r += "|" + s
+		}
+		return r
+	case traceAtFlag:
+		return traceFlags(a, traceAtFlags)
+	case traceMmapProt:
+		if a == 0 {
+			return "PROT_NONE"
+		}
+		return traceFlags(a, traceMmapProts)
+	case traceMmapFlag:
+		return traceFlags(a, traceMmapFlags)
+	case traceClock:
+		if a < uint64(len(traceClocks)) {
+			return traceClocks[a]
+		}
+		return strconv.FormatUint(a, 10)
+	case traceWhence:
+		if a < uint64(len(traceWhences)) {
+			return traceWhences[a]
+		}
+		return strconv.FormatUint(a, 10)
+	}
+	return fmt.Sprintf("%#x", a)
+}
+
+// traceBuffer reads size bytes at a and quotes them.
+func traceBuffer(c *CPU, a uint64, size uint64) string {
+	n := size
+	if n > traceStrMax {
+		n = traceStrMax
+	}
+	b, err := c.GetMemory().GetByte(a, n)
+	if err != nil {
+		return fmt.Sprintf("%#x", a)
+	}
+	s := traceQuote(b)
+	if size > n {
+		s += "..."
+	}
+	return s
+}
+
+// traceQuote quotes b like a C string, truncated to traceStrMax bytes.
+func traceQuote(b []byte) string {
+	tail := ""
+	if len(b) > traceStrMax {
+		b = b[:traceStrMax]
+		tail = "..."
+	}
+	s := &strings.Builder{}
+	s.WriteByte('"')
+	for _, e := range b {
+		switch {
+		case e == '"' || e == '\\':
+			s.WriteByte('\\')
+			s.WriteByte(e)
+		case e == '\n':
+			s.WriteString("\\n")
+		case e == '\t':
+			s.WriteString("\\t")
+		case e < 0x20 || e >= 0x7f:
+			fmt.Fprintf(s, "\\x%02x", e)
+		default:
+			s.WriteByte(e)
+		}
+	}
+	s.WriteByte('"')
+	return s.String() + tail
+}
+
+// traceFlags joins the names of the bits set in a. Unknown bits are printed in hex.
+func traceFlags(a uint64, names []traceFlag) string {
+	r := []string{}
+	for _, e := range names {
+		if a&e.bits == e.bits && e.bits != 0 {
+			r = append(r, e.name)
+			a &^= e.bits
+		}
+	}
+	if a != 0 {
+		r = append(r, fmt.Sprintf("%#x", a))
+	}
+	if len(r) == 0 {
+		return "0"
+	}
+	return strings.Join(r, "|")
+}
+
+func traceErrno(e uint64) string {
+	if s, ok := traceErrnos[e]; ok {
+		return s
+	}
+	return "E" + strconv.FormatUint(e, 10)
+}
@@ -0,0 +1,69 @@
+package rv64
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testTrace makes three syscalls through a tracer and returns the trace: a write of a string, an openat which fails
+// and an unknown syscall.
+func testTrace(t *testing.T, json bool) string {
+	c := testCPU(nil)
+	s := NewSystemStandard()
+	m := NewVFSMemory()
+	s.FS = m
+	f, err := m.Open("/out", os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Files[1] = f
+	w := &bytes.Buffer{}
+	r := &SystemTrace{System: s, Writer: w, JSON: json}
+	testSyscall(t, c, r, SyscallWrite, 1, testString(c, 0x100, "hi\n"), 3)
+	testSyscall(t, c, r, SyscallOpenat, LinuxATFdcwd, testString(c, 0x100, "/none"), LinuxORdonly|LinuxOAppend, 0)
+	for i := uint64(0); i < 6; i++ {
+		c.SetRegister(Ra0+i, i)
+	}
+	c.SetRegister(Ra7, 999)
+	if _, err := r.HandleCall(c); err != ErrAbnormalEcall {
+		t.Fatal(err)
+	}
+	return w.String()
+}
+
+func TestSystemTrace(t *testing.T) {
+	want := strings.Join([]string{
+		`write(1, "hi\n", 3) = 3`,
+		`openat(AT_FDCWD, "/none", O_RDONLY|O_APPEND, 0) = -1 ENOENT`,
+		`syscall_999(0x0, 0x1, 0x2, 0x3, 0x4, 0x5) = ? (Abnormal ecall)`,
+		``,
+	}, "\n")
+	if s := testTrace(t, false); s != want {
+		t.Fatal(s)
+	}
+}
+
+func TestSystemTraceJSON(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(testTrace(t, true)), "\n")
+	if len(lines) != 3 {
+		t.Fatal(lines)
+	}
+	r := make([]TraceRecord, len(lines))
+	for i, e := range lines {
+		if err := json.Unmarshal([]byte(e), &r[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if r[0].Name != "write" || r[0].Nr != SyscallWrite || r[0].Text[1] != `"hi\n"` || r[0].Ret != 3 || r[0].Errno != "" {
+		t.Fatal(lines[0])
+	}
+	if r[1].Name != "openat" || r[1].Ret != SyscallError(ErrnoENOENT) || r[1].Errno != "ENOENT" || r[1].Args[0] != LinuxATFdcwd {
+		t.Fatal(lines[1])
+	}
+	if r[2].Name != "syscall_999" || r[2].Error != ErrAbnormalEcall.Error() || r[2].Args[5] != 5 {
+		t.Fatal(lines[2])
+	}
+}
@@ -0,0 +1,367 @@
+// gen.c writes the test vectors of softfloat_test.go. It runs on an x86-64 host with FMA:
+//
+//	gcc -O2 -mfma -frounding-math -fsignaling-nans -o gen gen.c -lm && ./gen | gzip -9n > vectors.txt.gz
+//
+// Results and flags come from the SSE unit of the host, which detects tininess after rounding like RISC-V. Where x86
+// and RISC-V differ the result is fixed here: NaN results are canonical, the product of zero and infinity raises NV
+// even when the addend is a quiet NaN, conversions to integers saturate, and min and max follow the F extension. x86
+// has no RMM: its result is the one of RNE, unless the exact result, computed in extended precision, is a tie that
+// RNE rounds towards zero.
+//
+// Each line holds an operation, a rounding mode, the operands, the result and the flags, in hexadecimal. Integers of
+// 32 bits are written with 8 digits.
+#include <fenv.h>
+#include <float.h>
+#include <math.h>
+#include <stdint.h>
+#include <stdio.h>
+#include <string.h>
+
+enum { NV = 0x10, DZ = 0x08, OF = 0x04, UF = 0x02, NX = 0x01 };
+enum { ADD, SUB, MUL, DIV, SQRT, MULADD, EQ, LT, LE, MIN, MAX, TOI32, TOUI32, TOI64, TOUI64, FROMI32, FROMUI32, FROMI64, FROMUI64, CVT };
+
+static const int modes[] = {FE_TONEAREST, FE_TOWARDZERO, FE_DOWNWARD, FE_UPWARD};
+
+static int flags(void) {
+	int e = fetestexcept(FE_ALL_EXCEPT), r = 0;
+	if (e & FE_INVALID) r |= NV;
+	if (e & FE_DIVBYZERO) r |= DZ;
+	if (e & FE_OVERFLOW) r |= OF;
+	if (e & FE_UNDERFLOW) r |= UF;
+	if (e & FE_INEXACT) r |= NX;
+	return r;
+}
+
+static uint64_t rng = 0x9e3779b97f4a7c15;
+
+static uint64_t next(void) {
+	rng ^= rng << 13;
+	rng ^= rng >> 7;
+	rng ^= rng << 17;
+	return rng;
+}
+
+static float f32(uint64_t u) { uint32_t v = u; float f; memcpy(&f, &v, 4); return f; }
+static uint64_t u32(float f) { uint32_t v; memcpy(&v, &f, 4); return isnan(f) ? 0x7fc00000 : v; }
+static double f64(uint64_t u) { double f; memcpy(&f, &u, 8); return f; }
+static uint64_t u64(double f) { uint64_t v; memcpy(&v, &f, 8); return isnan(f) ? 0x7ff8000000000000 : v; }
+
+// A format: its width, precision, and the edge cases of its operands.
+struct format {
+	int w, p;
+	const uint64_t *edge;
+	int nedge;
+};
+
+static const uint64_t edge32[] = {
+	0x00000000, 0x00000001, 0x007fffff, 0x00800000, 0x00800001, 0x33800000, 0x3f7fffff, 0x3f800000, 0x3f800001,
+	0x3fc00000, 0x4f000000, 0x7effffff, 0x7f7fffff, 0x7f800000,
+};
+static const uint64_t edge64[] = {
+	0x0000000000000000, 0x0000000000000001, 0x000fffffffffffff, 0x0010000000000000, 0x0010000000000001,
+	0x3ca0000000000000, 0x3fefffffffffffff, 0x3ff0000000000000, 0x3ff0000000000001, 0x3ff8000000000000,
+	0x43e0000000000000, 0x7fefffffffffffff, 0x7ff0000000000000,
+};
+static const struct format fmt32 = {32, 24, edge32, sizeof(edge32) / 8};
+static const struct format fmt64 = {64, 53, edge64, sizeof(edge64) / 8};
+
+static uint64_t qnan(const struct format *f) { return f->w == 32 ? 0x7fc00000 : 0x7ff8000000000000; }
+static uint64_t snan(const struct format *f) { return f->w == 32 ? 0x7f800001 : 0x7ff0000000000001; }
+static uint64_t signbit64(const struct format *f) { return 1ull << (f->w - 1); }
+
+// random returns a number whose exponent is near emid, or anywhere with emid negative. Its significand has long runs
+// of ones and zeros.
+static uint64_t random(const struct format *f, int emid) {
+	int eb = f->w - f->p, emax = (1 << eb) - 1;
+	int e = emid < 0 ? next() % emax : emid + (int)(next() % 9) - 4;
+	if (e < 0) e = 0;
+	if (e >= emax) e = emax - 1;
+	uint64_t m = 0;
+	for (int i = 0; i < f->p - 1;) {
+		int run = 1 + next() % 12, bit = next() & 1;
+		for (; run > 0 && i < f->p - 1; run--, i++) m = m << 1 | bit;
+	}
+	if (next() % 4 == 0) m = next() & ((1ull << (f->p - 1)) - 1);
+	return (next() & 1) << (f->w - 1) | (uint64_t)e << (f->p - 1) | m;
+}
+
+static int exponent(const struct format *f, uint64_t a) {
+	return (a & ~signbit64(f)) >> (f->p - 1);
+}
+
+// hw computes the operation in the current rounding mode of the host.
+static uint64_t hw(const struct format *f, int op, uint64_t a, uint64_t b, uint64_t c) {
+	if (f->w == 32) {
+		volatile float x = f32(a), y = f32(b), z = f32(c);
+		switch (op) {
+		case ADD: return u32(x + y);
+		case SUB: return u32(x - y);
+		case MUL: return u32(x * y);
+		case DIV: return u32(x / y);
+		case SQRT: return u32(sqrtf(x));
+		case MULADD: return u32(fmaf(x, y, z));
+		case EQ: return x == y;
+		case LT: return x < y;
+		case LE: return x <= y;
+		case FROMI32: return u32((float)(int32_t)a);
+		case FROMUI32: return u32((float)(uint32_t)a);
+		case FROMI64: return u32((float)(int64_t)a);
+		case FROMUI64: return u32((float)(long double)a);
+		case CVT: return u64((double)x);
+		}
+	} else {
+		volatile double x = f64(a), y = f64(b), z = f64(c);
+		switch (op) {
+		case ADD: return u64(x + y);
+		case SUB: return u64(x - y);
+		case MUL: return u64(x * y);
+		case DIV: return u64(x / y);
+		case SQRT: return u64(sqrt(x));
+		case MULADD: return u64(fma(x, y, z));
+		case EQ: return x == y;
+		case LT: return x < y;
+		case LE: return x <= y;
+		case FROMI32: return u64((double)(int32_t)a);
+		case FROMUI32: return u64((double)(uint32_t)a);
+		case FROMI64: return u64((double)(int64_t)a);
+		case FROMUI64: return u64((double)(long double)a);
+		case CVT: return u32((float)x);
+		}
+	}
+	return 0;
+}
+
+static long double ld(const struct format *f, uint64_t a) {
+	return f->w == 32 ? f32(a) : f64(a);
+}
+
+// exact sets x to the exact result of the operation if it is finite and fits in extended precision.
+static int exact(const struct format *f, int op, uint64_t a, uint64_t b, uint64_t c, long double *x) {
+	volatile long double p = ld(f, a), q = ld(f, b), r = ld(f, c);
+	feclearexcept(FE_ALL_EXCEPT);
+	switch (op) {
+	case ADD: *x = p + q; break;
+	case SUB: *x = p - q; break;
+	case MUL: *x = p * q; break;
+	case DIV: *x = p / q; break;
+	case SQRT: *x = sqrtl(p); break;
+	case MULADD: *x = fmal(p, q, r); break;
+	case FROMI32: *x = (int32_t)a; break;
+	case FROMUI32: *x = (uint32_t)a; break;
+	case FROMI64: *x = (int64_t)a; break;
+	case FROMUI64: *x = a; break;
+	case CVT: *x = p; break;
+	default: return 0;
+	}
+	return !fetestexcept(FE_ALL_EXCEPT) && isfinite(*x) && *x != 0;
+}
+
+// rmm turns the RNE result r of the exact value x in the format f into the RMM result. Ties that RNE rounds towards
+// zero go away from zero instead, they are tiny if they are below the smallest normal number.
+static void rmm(const struct format *f, long double x, uint64_t *r, int *fl) {
+	uint64_t t, u;
+	long double lt, lu, min;
+	fesetround(FE_TOWARDZERO);
+	if (f->w == 32) {
+		volatile float v = x;
+		float w = nextafterf(v, x > 0 ? INFINITY : -INFINITY);
+		t = u32(v), u = u32(w), lt = v, lu = w, min = FLT_MIN;
+	} else {
+		volatile double v = x;
+		double w = nextafter(v, x > 0 ? INFINITY : -INFINITY);
+		t = u64(v), u = u64(w), lt = v, lu = w, min = DBL_MIN;
+	}
+	fesetround(FE_TONEAREST);
+	if (isinf(lu) || lt + lu != 2 * x || t != *r) return;
+	*r = u;
+	*fl = NX | (fabsl(x) < min ? UF : 0);
+}
+
+// toint converts the number x to an integer of n bits, saturating the ones out of range.
+static uint64_t toint(double x, int n, int sgn, int rm, int *fl) {
+	long double lo = sgn ? -ldexpl(1, n - 1) : 0, hi = ldexpl(1, sgn ? n - 1 : n);
+	uint64_t max = n == 64 ? ~0ull : (1ull << n) - 1, min = 0;
+	if (sgn) max >>= 1, min = n == 64 ? 1ull << 63 : 0x80000000;
+	if (isnan(x)) {
+		*fl = NV;
+		return max;
+	}
+	if (rm < 4) fesetround(modes[rm]);
+	volatile double r = rm == 4 ? round(x) : rint(x);
+	fesetround(FE_TONEAREST);
+	if (r < lo || r >= hi) {
+		*fl = NV;
+		return x < 0 ? min : max;
+	}
+	*fl = r != x ? NX : 0;
+	uint64_t v = sgn ? (uint64_t)(int64_t)r : (uint64_t)r;
+	return n == 32 ? v & 0xffffffff : v;
+}
+
+static const char *names[] = {
+	"add", "sub", "mul", "div", "sqrt", "mulAdd", "eq", "lt", "le", "min", "max",
+	"to_i32", "to_ui32", "to_i64", "to_ui64", "i32_to", "ui32_to", "i64_to", "ui64_to", "to",
+};
+
+static int arity(int op) {
+	switch (op) {
+	case SQRT: case TOI32: case TOUI32: case TOI64: case TOUI64: case FROMI32: case FROMUI32: case FROMI64:
+	case FROMUI64: case CVT:
+		return 1;
+	case MULADD:
+		return 3;
+	}
+	return 2;
+}
+
+static int rounds(int op) {
+	return op != EQ && op != LT && op != LE && op != MIN && op != MAX;
+}
+
+static void emit(const struct format *f, int op, uint64_t a, uint64_t b, uint64_t c) {
+	int w = f->w / 4;
+	for (int rm = 0; rm < (rounds(op) ? 5 : 1); rm++) {
+		uint64_t r;
+		int fl;
+		if (op >= TOI32 && op <= TOUI64) {
+			int n = op == TOI32 || op == TOUI32 ? 32 : 64;
+			r = toint(ld(f, a), n, op == TOI32 || op == TOI64, rm, &fl);
+		} else if (op == MIN || op == MAX) {
+			long double x = ld(f, a), y = ld(f, b);
+			int an = isnan(x), bn = isnan(y);
+			fl = (an && !(a >> (f->p - 2) & 1)) || (bn && !(b >> (f->p - 2) & 1)) ? NV : 0;
+			int less = signbit(x) != signbit(y) ? signbit(x) != 0 : x < y;
+			if (an && bn) r = qnan(f);
+			else if (an) r = b;
+			else if (bn) r = a;
+			else r = less != (op == MAX) ? a : b;
+		} else {
+			fesetround(modes[rm < 4 ? rm : 0]);
+			feclearexcept(FE_ALL_EXCEPT);
+			r = hw(f, op, a, b, c);
+			fl = flags();
+			fesetround(FE_TONEAREST);
+			long double x;
+			if (rm == 4 && exact(f, op, a, b, c, &x)) rmm(op == CVT ? (f->w == 32 ? &fmt64 : &fmt32) : f, x, &r, &fl);
+		}
+		if (op == MULADD && isnan(ld(f, c)) && ((isinf(ld(f, a)) && ld(f, b) == 0) || (ld(f, a) == 0 && isinf(ld(f, b)))))
+			fl |= NV;
+		if (op >= FROMI32 && op <= FROMUI64) {
+			const char *in = op == FROMI32 ? "i32" : op == FROMUI32 ? "ui32" : op == FROMI64 ? "i64" : "ui64";
+			int n = op == FROMI32 || op == FROMUI32 ? 8 : 16;
+			printf("%s_to_f%d %d %0*llx %0*llx %02x\n", in, f->w, rm, n, (unsigned long long)a, w, (unsigned long long)r, fl);
+			continue;
+		}
+		printf("f%d_%s", f->w, names[op]);
+		if (op == CVT) printf("_f%d", f->w == 32 ? 64 : 32);
+		printf(" %d", rm);
+		uint64_t in[] = {a, b, c};
+		for (int i = 0; i < arity(op); i++) printf(" %0*llx", w, (unsigned long long)in[i]);
+		int rw = op >= EQ && op <= LE ? 1 : op == TOI32 || op == TOUI32 ? 8 : op == TOI64 || op == TOUI64 ? 16 : op == CVT ? (f->w == 32 ? 16 : 8) : w;
+		printf(" %0*llx %02x\n", rw, (unsigned long long)r, fl);
+	}
+}
+
+// operands returns the edge cases of the format with both signs, and NaNs.
+static int operands(const struct format *f, uint64_t *v) {
+	int n = 0;
+	for (int i = 0; i < f->nedge; i++) {
+		v[n++] = f->edge[i];
+		v[n++] = f->edge[i] | signbit64(f);
+	}
+	v[n++] = qnan(f);
+	v[n++] = snan(f);
+	return n;
+}
+
+static void binary(const struct format *f, int op) {
+	uint64_t v[64];
+	int n = operands(f, v);
+	for (int i = 0; i < n; i++)
+		for (int j = 0; j < n; j++) emit(f, op, v[i], v[j], 0);
+	for (int i = 0; i < 300; i++) {
+		uint64_t a = random(f, -1);
+		emit(f, op, a, random(f, i % 2 ? exponent(f, a) : -1), 0);
+	}
+}
+
+static void unary(const struct format *f, int op) {
+	uint64_t v[64];
+	int n = operands(f, v);
+	for (int i = 0; i < n; i++) emit(f, op, v[i], 0, 0);
+	for (int i = 0; i < 200; i++) emit(f, op, random(f, -1), 0, 0);
+}
+
+static void muladd(const struct format *f) {
+	uint64_t v[64];
+	int n = operands(f, v);
+	// A third of the edge cases, with both signs, and the NaNs.
+	for (int i = 0; i < n; i += 6)
+		for (int j = 0; j < n; j += 4)
+			for (int k = 0; k < n; k += 3) emit(f, MULADD, v[i], v[j ^ 1], v[k]);
+	for (int i = 0; i < 300; i++) {
+		uint64_t a = random(f, -1), b = random(f, (1 << (f->w - f->p - 1)) - 1), c;
+		if (i % 2) {
+			// An addend close to the opposite of the product.
+			c = hw(f, MUL, a, b, 0) ^ signbit64(f);
+			c ^= next() % 8;
+		} else {
+			c = random(f, exponent(f, a));
+		}
+		emit(f, MULADD, a, b, c);
+	}
+}
+
+// toints converts the edge cases, numbers around the limits of the integers and halves.
+static void toints(const struct format *f, int op) {
+	uint64_t v[64];
+	int n = operands(f, v);
+	for (int i = 0; i < n; i++) emit(f, op, v[i], 0, 0);
+	static const double lim[] = {0.5, 1.5, 2.5, 0x1p31, 0x1p32, 0x1p63, 0x1p64};
+	for (int i = 0; i < 7; i++)
+		for (int d = -2; d <= 2; d++)
+			for (int s = 0; s < 2; s++) {
+				double x = s ? -lim[i] : lim[i];
+				uint64_t a = f->w == 32 ? u32((float)x) + d : u64(x) + d;
+				emit(f, op, a, 0, 0);
+			}
+	int bias = (1 << (f->w - f->p - 1)) - 1;
+	for (int i = 0; i < 100; i++) emit(f, op, random(f, bias + next() % 66 - 2), 0, 0);
+}
+
+static void fromints(const struct format *f, int op) {
+	static const uint64_t v[] = {
+		0, 1, 0xffffffff, 0x7fffffff, 0x80000000, 0x00ffffff, 0x01000001, 0x7fffffffffffffff,
+		0x8000000000000000, 0xffffffffffffffff, 0x001fffffffffffff, 0x0020000000000001, 0xfffffffffffff800,
+	};
+	int wide = op == FROMI64 || op == FROMUI64;
+	for (int i = 0; i < 13; i++) emit(f, op, wide ? v[i] : v[i] & 0xffffffff, 0, 0);
+	for (int i = 0; i < 100; i++) {
+		uint64_t a = next() >> (next() % 64);
+		emit(f, op, wide ? a : a & 0xffffffff, 0, 0);
+	}
+}
+
+static void all(const struct format *f) {
+	for (int op = ADD; op <= DIV; op++) binary(f, op);
+	unary(f, SQRT);
+	muladd(f);
+	for (int op = EQ; op <= MAX; op++) binary(f, op);
+	for (int op = TOI32; op <= TOUI64; op++) toints(f, op);
+	for (int op = FROMI32; op <= FROMUI64; op++) fromints(f, op);
+	unary(f, CVT);
+	if (f->w == 64) {
+		// Ties of singles: 1 + 2^-24, 1 + 3 × 2^-24, the largest single plus half its last bit and half the smallest.
+		static const uint64_t ties[] = {0x3ff0000010000000, 0x3ff0000030000000, 0x47effffff0000000, 0x3690000000000000};
+		for (int i = 0; i < 4; i++) {
+			emit(f, CVT, ties[i], 0, 0);
+			emit(f, CVT, ties[i] | signbit64(f), 0, 0);
+		}
+	}
+}
+
+int main(void) {
+	all(&fmt32);
+	all(&fmt64);
+	return 0;
+}
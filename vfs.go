@@ -0,0 +1,361 @@
+package rv64
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// VFS is the file system seen by the guest. Names passed to it are slash separated absolute guest paths. Errors are
+// reported as *fs.PathError so that the syscall layer can turn them into Linux error numbers.
+type VFS interface {
+	Open(name string, flag int, perm os.FileMode) (File, error)
+	Stat(name string) (os.FileInfo, error)
+	Lstat(name string) (os.FileInfo, error)
+	Readlink(name string) (string, error)
+	Mkdir(name string, perm os.FileMode) error
+	Remove(name string) error
+}
+
+// Symbolic links are followed at most this many times while resolving a path, like MAXSYMLINKS of Linux.
+const vfsMaxSymlinks = 40
+
+// vfsResolve expands the symbolic links in the absolute path name. The last element is only expanded if follow is
+// set. readlink returns the target of a symbolic link, or false if the path is not a symbolic link or does not exist.
+// The result never leaves the root: ".." at the root stays at the root.
+func vfsResolve(name string, follow bool, readlink func(string) (string, bool)) (string, error) {
+	todo := strings.Split(name, "/")
+	done := "/"
+	n := 0
+	for len(todo) != 0 {
+		e := todo[0]
+		todo = todo[1:]
+		switch e {
+		case "", ".":
+			continue
+		case "..":
+			done = path.Dir(done)
+			continue
+		}
+		next := path.Join(done, e)
+		if len(todo) == 0 && !follow {
+			done = next
+			break
+		}
+		l, ok := readlink(next)
+		if !ok {
+			done = next
+			continue
+		}
+		n++
+		if n > vfsMaxSymlinks {
+			return "", &fs.PathError{Op: "resolve", Path: name, Err: syscall.ELOOP}
+		}
+		if path.IsAbs(l) {
+			done = "/"
+		}
+		todo = append(strings.Split(l, "/"), todo...)
+	}
+	return done, nil
+}
+
+// vfsEntry adapts an os.FileInfo to fs.DirEntry.
+type vfsEntry struct {
+	os.FileInfo
+}
+
+func (e vfsEntry) Type() fs.FileMode          { return e.Mode().Type() }
+func (e vfsEntry) Info() (fs.FileInfo, error) { return e.FileInfo, nil }
+
+// vfsDot describes the "." and ".." entries of a directory.
+type vfsDot struct {
+	name string
+}
+
+func (d vfsDot) Name() string       { return d.name }
+func (d vfsDot) Size() int64        { return 0 }
+func (d vfsDot) Mode() os.FileMode  { return os.ModeDir | 0o755 }
+func (d vfsDot) ModTime() time.Time { return time.Time{} }
+func (d vfsDot) IsDir() bool        { return true }
+func (d vfsDot) Sys() interface{}   { return nil }
+
+// vfsDir is an open directory whose entries are known in advance.
+type vfsDir struct {
+	File
+	list []fs.DirEntry
+}
+
+func (d *vfsDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 {
+		r := d.list
+		d.list = nil
+		return r, nil
+	}
+	if len(d.list) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.list) {
+		n = len(d.list)
+	}
+	r := d.list[:n]
+	d.list = d.list[n:]
+	return r, nil
+}
+
+func newVFSDir(f File, list []fs.DirEntry) *vfsDir {
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return &vfsDir{File: f, list: list}
+}
+
+// VFSOverlay stacks a writable upper file system on a read-only lower one, like the overlay file system of Linux.
+// Files are looked up in the upper layer first. Writing a file of the lower layer copies it up, removing it leaves a
+// whiteout in the overlay. The lower layer is never modified.
+type VFSOverlay struct {
+	Lower    VFS
+	Upper    VFS
+	whiteout map[string]bool
+	opaque   map[string]bool
+}
+
+// hidden reports whether name or one of its parents has been removed from the lower layer.
+func (o *VFSOverlay) hidden(name string) bool {
+	for p := name; ; p = path.Dir(p) {
+		if o.whiteout[p] || o.opaque[p] && p != name {
+			return true
+		}
+		if p == "/" {
+			return false
+		}
+	}
+}
+
+func (o *VFSOverlay) inUpper(name string) bool {
+	_, err := o.Upper.Lstat(name)
+	return err == nil
+}
+
+func (o *VFSOverlay) inLower(name string) bool {
+	if o.hidden(name) {
+		return false
+	}
+	_, err := o.Lower.Lstat(name)
+	return err == nil
+}
+
+func (o *VFSOverlay) layer(op string, name string) (VFS, error) {
+	if o.inUpper(name) {
+		return o.Upper, nil
+	}
+	if o.inLower(name) {
+		return o.Lower, nil
+	}
+	return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+}
+
+// copyUpDir creates the directory name and its parents in the upper layer.
+func (o *VFSOverlay) copyUpDir(name string) error {
+	if name == "/" || o.inUpper(name) {
+		return nil
+	}
+	if err := o.copyUpDir(path.Dir(name)); err != nil {
+		return err
+	}
+	info, err := o.Lower.Stat(name)
+	if err != nil || o.hidden(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrNotExist}
+	}
+	if !info.IsDir() {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+	}
+	return o.Upper.Mkdir(name, info.Mode().Perm())
+}
+
+// copyUp copies the regular file name from the lower layer into the upper layer.
+func (o *VFSOverlay) copyUp(name string, data bool) error {
+	if err := o.copyUpDir(path.Dir(name)); err != nil {
+		return err
+	}
+	info, err := o.Lower.Stat(name)
+	if err != nil {
+		return err
+	}
+	src, err := o.Lower.Open(name, os.O_RDONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := o.Upper.Open(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+	if data {
+		if _, err := io.Copy(dst, src); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *VFSOverlay) list(name string) ([]fs.DirEntry, error) {
+	seen := map[string]bool{}
+	list := []fs.DirEntry{}
+	for _, l := range []VFS{o.Upper, o.Lower} {
+		if l == o.Upper && !o.inUpper(name) || l == o.Lower && (!o.inLower(name) || o.opaque[name]) {
+			continue
+		}
+		f, err := l.Open(name, os.O_RDONLY, 0)
+		if err != nil {
+			return nil, err
+		}
+		r, err := f.ReadDir(-1)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range r {
+			if seen[e.Name()] || l == o.Lower && o.hidden(path.Join(name, e.Name())) {
+				continue
+			}
+			seen[e.Name()] = true
+			list = append(list, e)
+		}
+	}
+	return list, nil
+}
+
+func (o *VFSOverlay) Open(name string, flag int, perm os.FileMode) (File, error) {
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_TRUNC|os.O_APPEND) != 0 && !o.inUpper(name) {
+		switch {
+		case o.inLower(name):
+			if flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0 {
+				return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+			}
+			info, err := o.Lower.Stat(name)
+			if err != nil {
+				return nil, err
+			}
+			if info.IsDir() {
+				return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+			}
+			if err := o.copyUp(name, flag&os.O_TRUNC == 0); err != nil {
+				return nil, err
+			}
+		case flag&os.O_CREATE != 0:
+			if err := o.copyUpDir(path.Dir(name)); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+		}
+		if o.whiteout[name] {
+			delete(o.whiteout, name)
+			o.opaque[name] = true
+		}
+		return o.Upper.Open(name, flag, perm)
+	}
+	l, err := o.layer("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := l.Open(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil || !info.IsDir() {
+		return f, err
+	}
+	list, err := o.list(name)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return newVFSDir(f, list), nil
+}
+
+func (o *VFSOverlay) Stat(name string) (os.FileInfo, error) {
+	l, err := o.layer("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return l.Stat(name)
+}
+
+func (o *VFSOverlay) Lstat(name string) (os.FileInfo, error) {
+	l, err := o.layer("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return l.Lstat(name)
+}
+
+func (o *VFSOverlay) Readlink(name string) (string, error) {
+	l, err := o.layer("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	return l.Readlink(name)
+}
+
+func (o *VFSOverlay) Mkdir(name string, perm os.FileMode) error {
+	if o.inUpper(name) || o.inLower(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+	if err := o.copyUpDir(path.Dir(name)); err != nil {
+		return err
+	}
+	if err := o.Upper.Mkdir(name, perm); err != nil {
+		return err
+	}
+	// A directory created on top of a removed one must not show the old content of the lower layer.
+	if o.whiteout[name] {
+		delete(o.whiteout, name)
+		o.opaque[name] = true
+	}
+	return nil
+}
+
+func (o *VFSOverlay) Remove(name string) error {
+	info, err := o.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		list, err := o.list(name)
+		if err != nil {
+			return err
+		}
+		if len(list) != 0 {
+			return &fs.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+		}
+	}
+	if o.inUpper(name) {
+		if err := o.Upper.Remove(name); err != nil {
+			return err
+		}
+	}
+	if o.inLower(name) {
+		o.whiteout[name] = true
+	}
+	delete(o.opaque, name)
+	return nil
+}
+
+// NewVFSOverlay returns an overlay of lower. If upper is nil the changes are kept in memory.
+func NewVFSOverlay(lower VFS, upper VFS) *VFSOverlay {
+	if upper == nil {
+		upper = NewVFSMemory()
+	}
+	return &VFSOverlay{
+		Lower:    lower,
+		Upper:    upper,
+		whiteout: map[string]bool{},
+		opaque:   map[string]bool{},
+	}
+}
@@ -0,0 +1,115 @@
+package rv64
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// VFSHost exposes a host directory as the root of the guest file system. Guest paths, including the targets of
+// symbolic links, are resolved inside Root so the guest can not escape from it. If ReadOnly is set, the guest can not
+// create, change or remove anything, these attempts fail with EROFS.
+type VFSHost struct {
+	Root     string
+	ReadOnly bool
+}
+
+// vfsHostWrite are the flags of open that may change the file system.
+const vfsHostWrite = os.O_WRONLY | os.O_RDWR | os.O_CREATE | os.O_TRUNC | os.O_APPEND
+
+// writable returns the error of an operation op on name that changes the file system, if it is read-only.
+func (h *VFSHost) writable(op string, name string) error {
+	if h.ReadOnly {
+		return &fs.PathError{Op: op, Path: name, Err: syscall.EROFS}
+	}
+	return nil
+}
+
+// vfsHostFile is a host file that reports its guest path as name.
+type vfsHostFile struct {
+	*os.File
+	name string
+}
+
+func (f *vfsHostFile) Name() string { return f.name }
+
+// host returns the host path of the guest path name.
+func (h *VFSHost) host(name string, follow bool) (string, error) {
+	p, err := vfsResolve(name, follow, func(p string) (string, bool) {
+		l, err := os.Readlink(filepath.Join(h.Root, filepath.FromSlash(p)))
+		return l, err == nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(h.Root, filepath.FromSlash(p)), nil
+}
+
+func (h *VFSHost) Open(name string, flag int, perm os.FileMode) (File, error) {
+	if flag&vfsHostWrite != 0 {
+		if err := h.writable("open", name); err != nil {
+			return nil, err
+		}
+	}
+	p, err := h.host(name, true)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(p, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &vfsHostFile{File: f, name: name}, nil
+}
+
+func (h *VFSHost) Stat(name string) (os.FileInfo, error) {
+	p, err := h.host(name, true)
+	if err != nil {
+		return nil, err
+	}
+	return os.Stat(p)
+}
+
+func (h *VFSHost) Lstat(name string) (os.FileInfo, error) {
+	p, err := h.host(name, false)
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(p)
+}
+
+func (h *VFSHost) Readlink(name string) (string, error) {
+	p, err := h.host(name, false)
+	if err != nil {
+		return "", err
+	}
+	return os.Readlink(p)
+}
+
+func (h *VFSHost) Mkdir(name string, perm os.FileMode) error {
+	if err := h.writable("mkdir", name); err != nil {
+		return err
+	}
+	p, err := h.host(name, false)
+	if err != nil {
+		return err
+	}
+	return os.Mkdir(p, perm)
+}
+
+func (h *VFSHost) Remove(name string) error {
+	if err := h.writable("remove", name); err != nil {
+		return err
+	}
+	p, err := h.host(name, false)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+// NewVFSHost returns a file system jailed in the host directory root.
+func NewVFSHost(root string) *VFSHost {
+	return &VFSHost{Root: root}
+}
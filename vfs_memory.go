@@ -0,0 +1,391 @@
+package rv64
+
+import (
+	"archive/tar"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// vfsNode is a file, directory or symbolic link of VFSMemory. The data of a symbolic link is its target.
+type vfsNode struct {
+	name string
+	mode os.FileMode
+	time time.Time
+	data []byte
+	kids map[string]*vfsNode
+}
+
+func (n *vfsNode) Name() string       { return n.name }
+func (n *vfsNode) Size() int64        { return int64(len(n.data)) }
+func (n *vfsNode) Mode() os.FileMode  { return n.mode }
+func (n *vfsNode) ModTime() time.Time { return n.time }
+func (n *vfsNode) IsDir() bool        { return n.mode.IsDir() }
+func (n *vfsNode) Sys() interface{}   { return nil }
+
+// VFSMemory is a file system that lives entirely in memory. It is intended to seed a guest with fixture files and to
+// inspect what the guest wrote afterwards.
+type VFSMemory struct {
+	root *vfsNode
+}
+
+// walk returns the node at the resolved path name, or nil if it does not exist.
+func (m *VFSMemory) walk(name string) (*vfsNode, error) {
+	n := m.root
+	for _, e := range splitPath(name) {
+		if !n.IsDir() {
+			return nil, syscall.ENOTDIR
+		}
+		n = n.kids[e]
+		if n == nil {
+			return nil, fs.ErrNotExist
+		}
+	}
+	return n, nil
+}
+
+func (m *VFSMemory) resolve(name string, follow bool) (string, error) {
+	return vfsResolve(name, follow, func(p string) (string, bool) {
+		n, err := m.walk(p)
+		if err != nil || n.mode&os.ModeSymlink == 0 {
+			return "", false
+		}
+		return string(n.data), true
+	})
+}
+
+func (m *VFSMemory) lookup(op string, name string, follow bool) (string, *vfsNode, error) {
+	p, err := m.resolve(name, follow)
+	if err != nil {
+		return "", nil, err
+	}
+	n, err := m.walk(p)
+	if err != nil {
+		return p, nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return p, n, nil
+}
+
+// create adds a node to the parent directory of the resolved path name.
+func (m *VFSMemory) create(op string, name string, mode os.FileMode) (*vfsNode, error) {
+	if name == "/" {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrExist}
+	}
+	d, err := m.walk(path.Dir(name))
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	if !d.IsDir() {
+		return nil, &fs.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+	}
+	if d.kids[path.Base(name)] != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrExist}
+	}
+	n := &vfsNode{name: path.Base(name), mode: mode, time: time.Now()}
+	if mode.IsDir() {
+		n.kids = map[string]*vfsNode{}
+	}
+	d.kids[n.name] = n
+	d.time = n.time
+	return n, nil
+}
+
+func (m *VFSMemory) Open(name string, flag int, perm os.FileMode) (File, error) {
+	p, n, err := m.lookup("open", name, true)
+	switch {
+	case err == nil && flag&os.O_CREATE != 0 && flag&os.O_EXCL != 0:
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrExist}
+	case err != nil && p != "" && flag&os.O_CREATE != 0 && os.IsNotExist(err):
+		n, err = m.create("open", p, perm.Perm())
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, err
+	}
+	f := &vfsMemoryFile{node: n, name: name, flag: flag}
+	if n.IsDir() {
+		if f.writable() {
+			return nil, &fs.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+		}
+		list := []fs.DirEntry{}
+		for _, e := range n.kids {
+			list = append(list, vfsEntry{e})
+		}
+		return newVFSDir(f, list), nil
+	}
+	if flag&os.O_TRUNC != 0 && f.writable() {
+		n.data = nil
+		n.time = time.Now()
+	}
+	return f, nil
+}
+
+func (m *VFSMemory) Stat(name string) (os.FileInfo, error) {
+	_, n, err := m.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (m *VFSMemory) Lstat(name string) (os.FileInfo, error) {
+	_, n, err := m.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+func (m *VFSMemory) Readlink(name string) (string, error) {
+	_, n, err := m.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if n.mode&os.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: syscall.EINVAL}
+	}
+	return string(n.data), nil
+}
+
+func (m *VFSMemory) Mkdir(name string, perm os.FileMode) error {
+	p, err := m.resolve(name, false)
+	if err != nil {
+		return err
+	}
+	_, err = m.create("mkdir", p, os.ModeDir|perm.Perm())
+	return err
+}
+
+func (m *VFSMemory) Remove(name string) error {
+	p, n, err := m.lookup("remove", name, false)
+	if err != nil {
+		return err
+	}
+	if p == "/" {
+		return &fs.PathError{Op: "remove", Path: name, Err: syscall.EBUSY}
+	}
+	if len(n.kids) != 0 {
+		return &fs.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+	}
+	d, _ := m.walk(path.Dir(p))
+	delete(d.kids, n.name)
+	d.time = time.Now()
+	return nil
+}
+
+// MkdirAll creates the directory name along with any necessary parents.
+func (m *VFSMemory) MkdirAll(name string, perm os.FileMode) error {
+	p := "/"
+	for _, e := range splitPath(name) {
+		p = path.Join(p, e)
+		info, err := m.Stat(p)
+		if err == nil && info.IsDir() {
+			continue
+		}
+		if err := m.Mkdir(p, perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadFile returns the content of the file name.
+func (m *VFSMemory) ReadFile(name string) ([]byte, error) {
+	_, n, err := m.lookup("read", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if n.IsDir() {
+		return nil, &fs.PathError{Op: "read", Path: name, Err: syscall.EISDIR}
+	}
+	return append([]byte{}, n.data...), nil
+}
+
+// WriteFile writes data to the file name, creating it and its parent directories if necessary.
+func (m *VFSMemory) WriteFile(name string, data []byte, perm os.FileMode) error {
+	if err := m.MkdirAll(path.Dir(path.Join("/", name)), 0o755); err != nil {
+		return err
+	}
+	f, err := m.Open(path.Join("/", name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(data)
+	return err
+}
+
+// Symlink creates name as a symbolic link to target.
+func (m *VFSMemory) Symlink(target string, name string) error {
+	if err := m.MkdirAll(path.Dir(path.Join("/", name)), 0o755); err != nil {
+		return err
+	}
+	n, err := m.create("symlink", path.Join("/", name), os.ModeSymlink|0o777)
+	if err != nil {
+		return err
+	}
+	n.data = []byte(target)
+	return nil
+}
+
+// vfsMemoryFile is an open file of VFSMemory.
+type vfsMemoryFile struct {
+	node *vfsNode
+	name string
+	flag int
+	off  int64
+}
+
+func (f *vfsMemoryFile) readable() bool { return f.flag&(os.O_WRONLY|os.O_RDWR) != os.O_WRONLY }
+func (f *vfsMemoryFile) writable() bool { return f.flag&(os.O_WRONLY|os.O_RDWR) != os.O_RDONLY }
+
+func (f *vfsMemoryFile) Read(b []byte) (int, error) {
+	if !f.readable() {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: syscall.EBADF}
+	}
+	if f.node.IsDir() {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: syscall.EISDIR}
+	}
+	if f.off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.node.data[f.off:])
+	f.off += int64(n)
+	return n, nil
+}
+
+func (f *vfsMemoryFile) ReadAt(b []byte, off int64) (int, error) {
+	if !f.readable() {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: syscall.EBADF}
+	}
+	if off >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(b, f.node.data[off:])
+	if n < len(b) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func (f *vfsMemoryFile) Write(b []byte) (int, error) {
+	if !f.writable() {
+		return 0, &fs.PathError{Op: "write", Path: f.name, Err: syscall.EBADF}
+	}
+	if f.flag&os.O_APPEND != 0 {
+		f.off = int64(len(f.node.data))
+	}
+	if end := f.off + int64(len(b)); end > int64(len(f.node.data)) {
+		f.node.data = append(f.node.data, make([]byte, end-int64(len(f.node.data)))...)
+	}
+	copy(f.node.data[f.off:], b)
+	f.off += int64(len(b))
+	f.node.time = time.Now()
+	return len(b), nil
+}
+
+func (f *vfsMemoryFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.off
+	case io.SeekEnd:
+		offset += int64(len(f.node.data))
+	}
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: syscall.EINVAL}
+	}
+	f.off = offset
+	return offset, nil
+}
+
+func (f *vfsMemoryFile) Close() error               { return nil }
+func (f *vfsMemoryFile) Name() string               { return f.name }
+func (f *vfsMemoryFile) Stat() (os.FileInfo, error) { return f.node, nil }
+
+func (f *vfsMemoryFile) ReadDir(int) ([]fs.DirEntry, error) {
+	return nil, &fs.PathError{Op: "readdir", Path: f.name, Err: syscall.ENOTDIR}
+}
+
+// NewVFSMemory returns an empty file system.
+func NewVFSMemory() *VFSMemory {
+	return &VFSMemory{
+		root: &vfsNode{name: "/", mode: os.ModeDir | 0o755, time: time.Now(), kids: map[string]*vfsNode{}},
+	}
+}
+
+// NewVFSMemoryFS returns a file system holding a copy of fsys.
+func NewVFSMemoryFS(fsys fs.FS) (*VFSMemory, error) {
+	m := NewVFSMemory()
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return m.MkdirAll(path.Join("/", p), info.Mode().Perm())
+		}
+		b, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		return m.WriteFile(p, b, info.Mode().Perm())
+	})
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewVFSMemoryTar returns a file system holding the content of a tar archive.
+func NewVFSMemoryTar(r io.Reader) (*VFSMemory, error) {
+	m := NewVFSMemory()
+	t := tar.NewReader(r)
+	for {
+		h, err := t.Next()
+		if err == io.EOF {
+			return m, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		perm := os.FileMode(h.Mode).Perm()
+		switch h.Typeflag {
+		case tar.TypeDir:
+			err = m.MkdirAll(h.Name, perm)
+		case tar.TypeReg:
+			var b []byte
+			b, err = io.ReadAll(t)
+			if err == nil {
+				err = m.WriteFile(h.Name, b, perm)
+			}
+		case tar.TypeSymlink:
+			err = m.Symlink(h.Linkname, h.Name)
+		case tar.TypeLink:
+			var b []byte
+			b, err = m.ReadFile(path.Join("/", h.Linkname))
+			if err == nil {
+				err = m.WriteFile(h.Name, b, perm)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// splitPath returns the elements of a slash separated path.
+func splitPath(name string) []string {
+	p := path.Clean("/" + name)
+	if p == "/" {
+		return nil
+	}
+	return strings.Split(p[1:], "/")
+}
@@ -0,0 +1,108 @@
+package rv64
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestVFSMemory(t *testing.T) {
+	m := NewVFSMemory()
+	if err := m.WriteFile("/etc/motd", []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Symlink("/etc", "/home/etc"); err != nil {
+		t.Fatal(err)
+	}
+	f, err := m.Open("/home/etc/../etc/motd", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte(" world"))
+	b, err := m.ReadFile("/etc/motd")
+	if err != nil || string(b) != "hello world" {
+		t.Fatal(string(b), err)
+	}
+	if err := m.Remove("/etc"); hostErrno(err) != ErrnoENOTEMPTY {
+		t.Fatal(err)
+	}
+	if _, err := m.Open("/etc", os.O_RDWR, 0); hostErrno(err) != ErrnoEISDIR {
+		t.Fatal(err)
+	}
+}
+
+func TestVFSOverlay(t *testing.T) {
+	lower := NewVFSMemory()
+	lower.WriteFile("/data/a", []byte("a"), 0o644)
+	lower.WriteFile("/data/b", []byte("b"), 0o644)
+	o := NewVFSOverlay(lower, nil)
+	f, err := o.Open("/data/a", os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("a"))
+	if err := o.Remove("/data/b"); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := lower.ReadFile("/data/a"); string(b) != "a" {
+		t.Fatal(string(b))
+	}
+	if b, _ := o.Upper.(*VFSMemory).ReadFile("/data/a"); string(b) != "aa" {
+		t.Fatal(string(b))
+	}
+	if _, err := o.Stat("/data/b"); !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	d, err := o.Open("/data", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, _ := d.ReadDir(-1)
+	if len(l) != 1 || l[0].Name() != "a" {
+		t.Fatal(l)
+	}
+}
+
+func TestVFSHost(t *testing.T) {
+	root := t.TempDir()
+	os.WriteFile(filepath.Join(root, "a"), []byte("a"), 0o644)
+	os.Symlink("/../../..", filepath.Join(root, "escape"))
+	h := NewVFSHost(root)
+	if _, err := h.Stat("/escape/a"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Stat("/../../a"); err != nil {
+		t.Fatal(err)
+	}
+	// A read-only host file system refuses every change.
+	h.ReadOnly = true
+	if f, err := h.Open("/a", os.O_RDONLY, 0); err != nil {
+		t.Fatal(err)
+	} else {
+		f.Close()
+	}
+	for _, err := range []error{
+		testVFSOpen(h, "/a", os.O_WRONLY),
+		testVFSOpen(h, "/b", os.O_RDONLY|os.O_CREATE),
+		h.Mkdir("/c", 0o755),
+		h.Remove("/a"),
+	} {
+		if !errors.Is(err, syscall.EROFS) {
+			t.Fatal(err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "a")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testVFSOpen opens and closes name, and returns the error of open.
+func testVFSOpen(v VFS, name string, flag int) error {
+	f, err := v.Open(name, flag, 0o644)
+	if err == nil {
+		f.Close()
+	}
+	return err
+}